@@ -0,0 +1,140 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// arrayValue converts between a slice field and a Postgres array column in its text
+// representation, such as {1,2,3} or {"a b",c}. It is used for fields tagged with the
+// "array" option, for example:
+//
+//	Scores []int64  `sql:"scores,array"`
+//	Labels []string `sql:"labels,array"`
+//
+// Only one-dimensional arrays are supported. The elements are converted like column
+// values, so slices of strings, integers, floats and bools may be used. NULL is stored
+// as a nil slice; NULL elements are not supported.
+type arrayValue struct {
+	v reflect.Value
+}
+
+func (a *arrayValue) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case nil:
+		a.v.Set(reflect.Zero(a.v.Type()))
+		return nil
+	case []byte:
+		s = string(src)
+	case string:
+		s = src
+	default:
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %s", src, a.v.Type())
+	}
+
+	elems, err := parseArray(s)
+	if err != nil {
+		return err
+	}
+	slice := reflect.MakeSlice(a.v.Type(), len(elems), len(elems))
+	for i, e := range elems {
+		if e == nil {
+			return fmt.Errorf("sqlstruct: cannot store NULL array element in %s", a.v.Type())
+		}
+		if err := assignValue(slice.Index(i), *e); err != nil {
+			return err
+		}
+	}
+	a.v.Set(slice)
+	return nil
+}
+
+// Value implements driver.Valuer, returning the array in its text representation.
+func (a *arrayValue) Value() (driver.Value, error) {
+	if a.v.IsNil() {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < a.v.Len(); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		e := a.v.Index(i)
+		if e.Kind() == reflect.String {
+			b.WriteByte('"')
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(e.String()))
+			b.WriteByte('"')
+		} else {
+			b.WriteString(asString(e.Interface()))
+		}
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+// parseArray splits the text representation of a one-dimensional Postgres array into
+// its elements. NULL elements are returned as nil.
+func parseArray(s string) ([]*string, error) {
+	if i := strings.Index(s, "={"); i != -1 && strings.HasPrefix(s, "[") {
+		s = s[i+1:] // skip dimension decoration such as [0:2]=
+	}
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("sqlstruct: invalid array %q", s)
+	}
+	s = s[1 : len(s)-1]
+
+	elems := []*string{}
+	if s == "" {
+		return elems, nil
+	}
+	for {
+		var elem strings.Builder
+		quoted := false
+		if s != "" && s[0] == '"' {
+			quoted = true
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				elem.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("sqlstruct: unterminated quoted array element")
+			}
+			s = s[i+1:]
+		} else {
+			i := strings.IndexByte(s, ',')
+			if i == -1 {
+				i = len(s)
+			}
+			if strings.ContainsAny(s[:i], "{}") {
+				return nil, errors.New("sqlstruct: multidimensional arrays are not supported")
+			}
+			elem.WriteString(strings.TrimSpace(s[:i]))
+			s = s[i:]
+		}
+
+		if e := elem.String(); !quoted && strings.EqualFold(e, "NULL") {
+			elems = append(elems, nil)
+		} else {
+			elems = append(elems, &e)
+		}
+		if s == "" {
+			return elems, nil
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("sqlstruct: unexpected %q in array", s[0])
+		}
+		s = s[1:]
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanArray(t *testing.T) {
+	type post struct {
+		Scores []int64   `sql:"scores,array"`
+		Labels []string  `sql:"labels,array"`
+		Ratios []float64 `sql:"ratios,array"`
+		Empty  []string  `sql:"empty,array"`
+		Null   []string  `sql:"null,array"`
+	}
+
+	rows := testRows{}
+	rows.addValue("scores", "{1,2,3}")
+	rows.addValue("labels", []byte(`{plain,"with space","quote \" and \\ comma,",NULLx}`))
+	rows.addValue("ratios", "[0:1]={0.5,1.5}")
+	rows.addValue("empty", "{}")
+	rows.addValue("null", nil)
+
+	var p post
+	if err := Scan(&p, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := post{
+		Scores: []int64{1, 2, 3},
+		Labels: []string{"plain", "with space", `quote " and \ comma,`, "NULLx"},
+		Ratios: []float64{0.5, 1.5},
+		Empty:  []string{},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %+v got %+v", expected, p)
+	}
+
+	v, err := (&arrayValue{reflect.ValueOf(&p.Labels).Elem()}).Value()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s := `{"plain","with space","quote \" and \\ comma,","NULLx"}`; v != s {
+		t.Errorf("expected %s got %v", s, v)
+	}
+	if v, _ := (&arrayValue{reflect.ValueOf(&p.Scores).Elem()}).Value(); v != "{1,2,3}" {
+		t.Errorf("expected {1,2,3} got %v", v)
+	}
+
+	rows = testRows{}
+	rows.addValue("scores", "{1,NULL}")
+	if err := Scan(&p, rows); err == nil {
+		t.Error("expected error for NULL element")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+/*
+Sqlstructgen generates methods implementing sqlstruct.ColumnMapper for struct types,
+so that sqlstruct.Scan can scan rows into them without reflection.
+
+It is meant to be run by go generate, from a directive in the file declaring the
+types:
+
+	//go:generate sqlstructgen -type User,Account
+
+The methods are written to a file named after the first type, such as
+user_sqlstruct.go, in the package directory. The column names are derived as by
+sqlstruct with the default NameMapper, or with sqlstruct.ToSnakeCase if the -snake
+flag is given. Only fields with no tag options, or with the pk, auto, omitempty,
+readonly and writeonly options, are supported; embedded fields and options which
+convert values are reported as errors.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kisielk/sqlstruct"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; must be set")
+	output    = flag.String("output", "", "output file name; default <type>_sqlstruct.go")
+	tagName   = flag.String("tag", "sql", "name of the struct tag holding column names")
+	snake     = flag.Bool("snake", false, "map names with sqlstruct.ToSnakeCase instead of strings.ToLower")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sqlstructgen: ")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+	out := *output
+	if out == "" {
+		out = strings.ToLower(types[0]) + "_sqlstruct.go"
+	}
+	out = filepath.Join(dir, out)
+
+	pkg, files, err := parseDir(dir, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g := &generator{tag: *tagName, mapper: strings.ToLower}
+	if *snake {
+		g.mapper = sqlstruct.ToSnakeCase
+	}
+	src, err := g.generate(pkg, files, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseDir parses the Go files of the package in dir, other than tests and the
+// output file skip.
+func parseDir(dir, skip string) (string, []*ast.File, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	var pkg string
+	var files []*ast.File
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") || name == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		pkg = f.Name.Name
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return pkg, files, nil
+}
+
+// generator generates the ColumnMapper methods of struct types.
+type generator struct {
+	tag    string
+	mapper func(string) string
+}
+
+// column is a struct field mapped to a column.
+type column struct {
+	name      string
+	field     string
+	writeOnly bool
+}
+
+// generate returns the formatted source of a file in package pkg declaring the
+// methods of types, which are looked up in files.
+func (g *generator) generate(pkg string, files []*ast.File, types []string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by sqlstructgen; DO NOT EDIT.\n\npackage %s\n", pkg)
+	for _, name := range types {
+		st := findStruct(files, name)
+		if st == nil {
+			return nil, fmt.Errorf("struct type %s not found", name)
+		}
+		cols, err := g.columns(name, st)
+		if err != nil {
+			return nil, err
+		}
+		writeMethods(&buf, name, cols)
+	}
+	return format.Source(buf.Bytes())
+}
+
+// findStruct returns the declaration of the struct type name in files, or nil.
+func findStruct(files []*ast.File, name string) *ast.StructType {
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+					st, _ := ts.Type.(*ast.StructType)
+					return st
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// supported are the tag options which need no conversion of values.
+var supported = map[string]bool{
+	"pk":        true,
+	"auto":      true,
+	"omitempty": true,
+	"readonly":  true,
+	"writeonly": true,
+}
+
+// columns returns the columns of the struct type name, sorted by name.
+func (g *generator) columns(name string, st *ast.StructType) ([]column, error) {
+	var cols []column
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+		var tag string
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s).Get(g.tag)
+		}
+		colName, opts, _ := strings.Cut(tag, ",")
+		if colName == "-" {
+			continue
+		}
+		writeOnly := false
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "" {
+				continue
+			}
+			if !supported[opt] {
+				return nil, fmt.Errorf("%s: option %q is not supported", name, opt)
+			}
+			writeOnly = writeOnly || opt == "writeonly"
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			col := colName
+			if col == "" {
+				col = ident.Name
+			}
+			cols = append(cols, column{g.mapper(col), ident.Name, writeOnly})
+		}
+	}
+	sort.Slice(cols, func(i, j int) bool { return cols[i].name < cols[j].name })
+	return cols, nil
+}
+
+// writeMethods writes the method implementing sqlstruct.ColumnMapper for the type
+// name to buf.
+func writeMethods(buf *bytes.Buffer, name string, cols []column) {
+	fmt.Fprintf(buf, "\n// SQLDest implements sqlstruct.ColumnMapper.\n")
+	fmt.Fprintf(buf, "func (v *%s) SQLDest(column string) interface{} {\n\tswitch column {\n", name)
+	for _, c := range cols {
+		if !c.writeOnly {
+			fmt.Fprintf(buf, "\tcase %q:\n\t\treturn &v.%s\n", c.name, c.field)
+		}
+	}
+	fmt.Fprintf(buf, "\t}\n\treturn nil\n}\n")
+}
@@ -0,0 +1,70 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/kisielk/sqlstruct"
+)
+
+const testSource = `package models
+
+type User struct {
+	ID        int    ` + "`sql:\"id,pk\"`" + `
+	FullName  string
+	Password  string ` + "`sql:\"password,writeonly\"`" + `
+	Ignored   string ` + "`sql:\"-\"`" + `
+	unexported string
+}
+
+type Tagged struct {
+	Tags []string ` + "`sql:\"tags,split=;\"`" + `
+}
+`
+
+func parseTestSource(t *testing.T) []*ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "models.go", testSource, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return []*ast.File{f}
+}
+
+func TestGenerate(t *testing.T) {
+	g := &generator{tag: "sql", mapper: sqlstruct.ToSnakeCase}
+	src, err := g.generate("models", parseTestSource(t), []string{"User"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	out := string(src)
+	for _, expected := range []string{
+		"// Code generated by sqlstructgen; DO NOT EDIT.",
+		"case \"full_name\":\n\t\treturn &v.FullName",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+	for _, unexpected := range []string{"Password", "Ignored", "unexported"} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("expected output not to contain %q, got:\n%s", unexpected, out)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	g := &generator{tag: "sql", mapper: strings.ToLower}
+	files := parseTestSource(t)
+	if _, err := g.generate("models", files, []string{"Tagged"}); err == nil {
+		t.Error("expected error for unsupported option")
+	}
+	if _, err := g.generate("models", files, []string{"Missing"}); err == nil {
+		t.Error("expected error for missing type")
+	}
+}
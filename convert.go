@@ -0,0 +1,419 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// assign stores the driver value src in dest, which must be a pointer as accepted
+// by sql.Rows.Scan. It implements the common subset of the conversions performed by
+// database/sql for values that were read from the driver earlier.
+func assign(dest, src interface{}) error {
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(src)
+	case *interface{}:
+		if b, ok := src.([]byte); ok {
+			src = append([]byte(nil), b...)
+		}
+		*d = src
+		return nil
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination not a pointer: %T", dest)
+	}
+	return assignValue(dv.Elem(), src)
+}
+
+// assignValue stores the driver value src in the settable value dv.
+func assignValue(dv reflect.Value, src interface{}) error {
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", dv.Type())
+	}
+
+	if dv.Kind() == reflect.Ptr {
+		v := reflect.New(dv.Type().Elem())
+		if err := assignValue(v.Elem(), src); err != nil {
+			return err
+		}
+		dv.Set(v)
+		return nil
+	}
+	if dv.CanAddr() {
+		if s, ok := dv.Addr().Interface().(sql.Scanner); ok {
+			return s.Scan(src)
+		}
+	}
+
+	if b, ok := src.([]byte); ok {
+		switch {
+		case dv.Kind() == reflect.Slice && dv.Type().Elem().Kind() == reflect.Uint8:
+			dv.SetBytes(append([]byte(nil), b...))
+			return nil
+		case dv.Kind() == reflect.Interface && dv.NumMethod() == 0:
+			dv.Set(reflect.ValueOf(append([]byte(nil), b...)))
+			return nil
+		}
+		src = string(b)
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+
+	s := asString(src)
+	switch dv.Kind() {
+	case reflect.String:
+		dv.SetString(s)
+		return nil
+	case reflect.Slice:
+		if dv.Type().Elem().Kind() == reflect.Uint8 {
+			dv.SetBytes([]byte(s))
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting driver.Value type %T (%q) to a %s: %v", src, s, dv.Kind(), err)
+		}
+		dv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting driver.Value type %T (%q) to a %s: %v", src, s, dv.Kind(), err)
+		}
+		dv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("converting driver.Value type %T (%q) to a %s: %v", src, s, dv.Kind(), err)
+		}
+		dv.SetFloat(f)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("converting driver.Value type %T (%q) to a %s: %v", src, s, dv.Kind(), err)
+		}
+		dv.SetBool(b)
+		return nil
+	}
+
+	if sv.Type().ConvertibleTo(dv.Type()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %s", src, dv.Type())
+}
+
+// asString formats a driver value as a string in the same way as database/sql.
+func asString(src interface{}) string {
+	switch v := src.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%v", src)
+}
+
+// rawScanner is a sql.Scanner that stores a copy of the driver's bytes in a []byte
+// field, bypassing any sql.Scanner implemented by the field's type. It is used for
+// fields tagged with the "raw" option, for example:
+//
+//	Payload []byte `sql:"payload,raw"`
+//
+// Values that the driver does not return as bytes are formatted as strings first.
+// NULL is stored as a nil slice.
+type rawScanner struct {
+	v reflect.Value
+}
+
+func (s *rawScanner) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		b = append([]byte(nil), src...)
+	default:
+		b = []byte(asString(src))
+	}
+	s.v.SetBytes(b)
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeFormatValue converts between a time.Time or *time.Time field and its text
+// representation in the given layout. It is used for fields tagged with the
+// "timeformat" option for databases which store times as text, for example:
+//
+//	EventDate time.Time `sql:"event_date,timeformat=2006-01-02"`
+type timeFormatValue struct {
+	v      reflect.Value
+	layout string
+}
+
+func (t *timeFormatValue) Scan(src interface{}) error {
+	var tm time.Time
+	switch src := src.(type) {
+	case nil:
+		if t.v.Kind() == reflect.Ptr {
+			t.v.Set(reflect.Zero(t.v.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", t.v.Type())
+	case time.Time:
+		tm = src
+	case []byte, string:
+		var err error
+		if tm, err = time.Parse(t.layout, asString(src)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %s", src, t.v.Type())
+	}
+
+	if t.v.Kind() == reflect.Ptr {
+		t.v.Set(reflect.ValueOf(&tm))
+	} else {
+		t.v.Set(reflect.ValueOf(tm))
+	}
+	return nil
+}
+
+// Value implements driver.Valuer, formatting the time with the layout.
+func (t *timeFormatValue) Value() (driver.Value, error) {
+	v := t.v
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface().(time.Time).Format(t.layout), nil
+}
+
+// TimeLayouts are the layouts tried in turn to parse the text scanned into time.Time
+// and *time.Time fields, for drivers which return times as text, such as SQLite, or
+// MySQL without the parseTime parameter. For example:
+//
+//	sqlstruct.TimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339Nano}
+//	sqlstruct.TimeLocation = time.Local
+//
+// It is used by the Handles which do not set their own. When neither TimeLayouts nor
+// TimeLocation are set, time fields are scanned by database/sql, which fails for text.
+// Fields tagged with the "timeformat" option or of a type with a Converter are not
+// affected. Like Converters, both must be set before the types using them are first
+// scanned.
+var TimeLayouts []string
+
+// TimeLocation, if set, is the location of the times scanned into time.Time and
+// *time.Time fields. Text without a time zone is parsed in it and other times are
+// converted to it. If TimeLayouts is empty, the layouts in which SQLite and MySQL
+// return times are tried. It is used by the Handles which do not set their own.
+var TimeLocation *time.Location
+
+// defaultTimeLayouts are the layouts tried when only a location is set. They are those
+// used by SQLite for its date and time functions and by MySQL for DATETIME columns.
+var defaultTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// timeValue scans a time.Time or *time.Time field from a column which may hold its
+// text, trying each of layouts in turn. Times are converted to loc if it is set.
+type timeValue struct {
+	v       reflect.Value
+	layouts []string
+	loc     *time.Location
+}
+
+func (t *timeValue) Scan(src interface{}) error {
+	var tm time.Time
+	switch src := src.(type) {
+	case nil:
+		if t.v.Kind() == reflect.Ptr {
+			t.v.Set(reflect.Zero(t.v.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", t.v.Type())
+	case time.Time:
+		tm = src
+	case []byte, string:
+		s := asString(src)
+		loc := t.loc
+		if loc == nil {
+			loc = time.UTC
+		}
+		var err error
+		for _, layout := range t.layouts {
+			if tm, err = time.ParseInLocation(layout, s, loc); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("parsing time %q: no layout matches", s)
+		}
+	default:
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %s", src, t.v.Type())
+	}
+
+	if t.loc != nil {
+		tm = tm.In(t.loc)
+	}
+	if t.v.Kind() == reflect.Ptr {
+		t.v.Set(reflect.ValueOf(&tm))
+	} else {
+		t.v.Set(reflect.ValueOf(tm))
+	}
+	return nil
+}
+
+// splitValue converts between a []string field and a text column holding its
+// elements joined by a separator. It is used for fields tagged with the "split"
+// option, for example:
+//
+//	Tags []string `sql:"tags,split=,"`
+//
+// NULL is stored as a nil slice and the empty string as an empty slice.
+type splitValue struct {
+	v   reflect.Value
+	sep string
+}
+
+func (s *splitValue) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		s.v.Set(reflect.Zero(s.v.Type()))
+		return nil
+	case []byte, string:
+		str := asString(src)
+		parts := []string{}
+		if str != "" {
+			parts = strings.Split(str, s.sep)
+		}
+		s.v.Set(reflect.ValueOf(parts).Convert(s.v.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %s", src, s.v.Type())
+}
+
+// Value implements driver.Valuer, joining the elements with the separator.
+func (s *splitValue) Value() (driver.Value, error) {
+	if s.v.IsNil() {
+		return nil, nil
+	}
+	parts := make([]string, s.v.Len())
+	for i := range parts {
+		parts[i] = s.v.Index(i).String()
+	}
+	return strings.Join(parts, s.sep), nil
+}
+
+// nullValue converts between a pointer field and a column which may be NULL, using
+// the conversion of the field's element type for other values. NULL is stored as a
+// nil pointer. It lets fields such as *string with the "intern" option or *netip.Addr
+// hold NULL; pointers to types without conversions are handled by database/sql.
+type nullValue struct {
+	v    reflect.Value
+	elem func(reflect.Value) sql.Scanner
+}
+
+func (n *nullValue) Scan(src interface{}) error {
+	if src == nil {
+		n.v.Set(reflect.Zero(n.v.Type()))
+		return nil
+	}
+	p := reflect.New(n.v.Type().Elem())
+	if err := n.elem(p.Elem()).Scan(src); err != nil {
+		return err
+	}
+	n.v.Set(p)
+	return nil
+}
+
+// Value implements driver.Valuer, returning NULL for a nil pointer.
+func (n *nullValue) Value() (driver.Value, error) {
+	if n.v.IsNil() {
+		return nil, nil
+	}
+	if v, ok := n.elem(n.v.Elem()).(driver.Valuer); ok {
+		return v.Value()
+	}
+	return n.v.Elem().Interface(), nil
+}
+
+// defaultValue scans a column into a field tagged with the "default" option, or into
+// any field scanned with NullToZero, setting the default value of the field, or else
+// its zero value, for NULL.
+type defaultValue struct {
+	v    reflect.Value
+	f    *field
+	dest interface{} // the destination of other values
+}
+
+func (d *defaultValue) Scan(src interface{}) error {
+	switch {
+	case src == nil && d.f.def.IsValid():
+		d.f.setDefault(d.v)
+		return nil
+	case src == nil:
+		d.v.Set(reflect.Zero(d.v.Type()))
+		return nil
+	}
+	return assign(d.dest, src)
+}
+
+// valueRows is a Rows holding a single row of driver values that were read earlier.
+type valueRows struct {
+	columns []string
+	values  []interface{}
+}
+
+func (r valueRows) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+func (r valueRows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+	}
+	for i, v := range r.values {
+		if err := assign(dest[i], v); err != nil {
+			return fmt.Errorf("converting column %q: %w", r.columns[i], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"sync"
+)
+
+// Converter converts between driver values and the values of a type which does not
+// implement sql.Scanner and driver.Valuer itself, such as a UUID or decimal type from
+// a third-party package. See RegisterConverter.
+type Converter interface {
+	// Scan stores the driver value src in dest, which is a pointer to a value of the
+	// registered type. src is nil for NULL.
+	Scan(dest, src interface{}) error
+
+	// Value returns the driver value passed to the database for v, which is a value
+	// of the registered type.
+	Value(v interface{}) (driver.Value, error)
+}
+
+// converters holds the registered Converters, keyed by reflect.Type.
+var converters sync.Map
+
+// RegisterConverter registers conv for scanning into and binding fields of type typ,
+// and for binding query arguments of that type. Fields and arguments of type *typ are
+// handled too, with NULL stored as a nil pointer. Converters are consulted after the
+// conversions selected by tag options, and must be registered before the struct types
+// using them are first scanned or registered, for example:
+//
+//	func init() {
+//		sqlstruct.RegisterConverter(reflect.TypeOf(uuid.UUID{}), uuidConverter{})
+//	}
+func RegisterConverter(typ reflect.Type, conv Converter) {
+	converters.Store(typ, conv)
+}
+
+// converterFor returns the Converter registered for typ, if any.
+func converterFor(typ reflect.Type) Converter {
+	if conv, ok := converters.Load(typ); ok {
+		return conv.(Converter)
+	}
+	return nil
+}
+
+// convertArgs returns args with the values of types with a registered Converter, or
+// pointers to them, replaced by driver.Valuers using the Converter. Nil pointers are
+// replaced by nil. args is copied if any of them is replaced.
+func convertArgs(args []interface{}) []interface{} {
+	copied := false
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
+		v := reflect.ValueOf(arg)
+		conv, null := converterFor(v.Type()), false
+		if conv == nil && v.Kind() == reflect.Ptr {
+			conv, null = converterFor(v.Type().Elem()), v.IsNil()
+			v = reflect.Indirect(v)
+		}
+		if conv == nil {
+			continue
+		}
+		if !copied {
+			args = append([]interface{}(nil), args...)
+			copied = true
+		}
+		if null {
+			args[i] = nil
+		} else {
+			args[i] = &convertedValue{v, conv}
+		}
+	}
+	return args
+}
+
+// convertedValue converts between a field and a column with a registered Converter.
+type convertedValue struct {
+	v    reflect.Value
+	conv Converter
+}
+
+func (c *convertedValue) Scan(src interface{}) error {
+	return c.conv.Scan(c.v.Addr().Interface(), src)
+}
+
+// Value implements driver.Valuer using the Converter.
+func (c *convertedValue) Value() (driver.Value, error) {
+	return c.conv.Value(c.v.Interface())
+}
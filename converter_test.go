@@ -0,0 +1,81 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testCurrency struct {
+	code string
+}
+
+type testCurrencyConverter struct{}
+
+func (testCurrencyConverter) Scan(dest, src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into currency", src)
+	}
+	dest.(*testCurrency).code = strings.ToUpper(s)
+	return nil
+}
+
+func (testCurrencyConverter) Value(v interface{}) (driver.Value, error) {
+	return strings.ToLower(v.(testCurrency).code), nil
+}
+
+func TestRegisterConverter(t *testing.T) {
+	RegisterConverter(reflect.TypeOf(testCurrency{}), testCurrencyConverter{})
+	defer converters.Delete(reflect.TypeOf(testCurrency{}))
+
+	type price struct {
+		Id       int           `sql:"id,pk"`
+		Currency testCurrency  `sql:"currency"`
+		Original *testCurrency `sql:"original"`
+	}
+
+	rows := testRows{}
+	rows.addValue("currency", "eur")
+	rows.addValue("original", nil)
+
+	var p price
+	if err := Scan(&p, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Currency.code != "EUR" || p.Original != nil {
+		t.Errorf("unexpected result %+v", p)
+	}
+
+	fake := setFakeDatabase(t)
+	if _, err := Update(context.Background(), price{Id: 1, Currency: testCurrency{"USD"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if args := fake.args[0]; len(args) != 3 || args[0] != "usd" || args[1] != nil {
+		t.Errorf("expected args [usd <nil> 1] got %v", args)
+	}
+}
+
+func TestConverterArgs(t *testing.T) {
+	RegisterConverter(reflect.TypeOf(testCurrency{}), testCurrencyConverter{})
+	defer converters.Delete(reflect.TypeOf(testCurrency{}))
+
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"})
+	args := []interface{}{testCurrency{"USD"}, &testCurrency{"GBP"}, (*testCurrency)(nil), 1}
+	if _, err := Query[testUser]("SELECT * FROM prices WHERE a = ? AND b = ? AND c = ? AND d = ?", args...); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []interface{}{"usd", "gbp", nil, int64(1)}; !reflect.DeepEqual(fake.args[0], expected) {
+		t.Errorf("expected args %v got %v", expected, fake.args[0])
+	}
+	if _, ok := args[0].(testCurrency); !ok {
+		t.Errorf("expected the args of the caller to be left as they are, got %v", args)
+	}
+}
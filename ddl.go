@@ -0,0 +1,232 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CreateTableSQL returns a CREATE TABLE statement for T's table in the dialect d, with
+// a column for each mapped field. For example:
+//
+//	type User struct {
+//		Id      int64     `sql:"id,pk,auto"`
+//		Name    string    `sql:"name,size=100"`
+//		Email   *string   `sql:"email"`
+//		Created time.Time `sql:"created,default=CURRENT_TIMESTAMP"`
+//	}
+//
+//	ddl, err := sqlstruct.CreateTableSQL[User](sqlstruct.Postgres)
+//
+// This returns CREATE TABLE user (created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT
+// CURRENT_TIMESTAMP, email TEXT, id BIGSERIAL NOT NULL, name VARCHAR(100) NOT NULL,
+// PRIMARY KEY (id)).
+//
+// Column types are chosen for the booleans, integers, floats, strings, []byte and
+// time.Time, including pointers to them and the Null types of database/sql. Pointer
+// and Null fields are nullable and all others are NOT NULL. The options used are:
+//
+//   - pk: the field is part of the primary key.
+//   - auto: a primary key integer field is generated by the database, with the
+//     auto-increment or identity syntax of the dialect.
+//   - default=X: the column has the default value X, written as is. String defaults
+//     must be quoted, as in default='active', which Scan also unquotes.
+//   - size=N: a string column has the type VARCHAR(N) instead of a text type.
+//
+// Fields tagged with the "split" or "timeformat" options are stored as strings. An
+// error is returned for fields of other types.
+func CreateTableSQL[T any](d Dialect) (string, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := getFieldInfo(typ)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("sqlstruct: %s has no columns", typ)
+	}
+
+	var defs []string
+	inlinePK := false
+	for _, name := range fields.names() {
+		f := fields[name]
+		def, inline, err := columnDef(d, f, len(fields.primaryKeys()) == 1)
+		if err != nil {
+			return "", fmt.Errorf("sqlstruct: cannot create column %s of %s: %w", name, typ, err)
+		}
+		defs = append(defs, defaultHandle.quote(name)+" "+def)
+		inlinePK = inlinePK || inline
+	}
+	if pks := fields.primaryKeys(); len(pks) > 0 && !inlinePK {
+		cols := make([]string, len(pks))
+		for i, f := range pks {
+			cols[i] = defaultHandle.quote(f.name)
+		}
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(cols, ", ")+")")
+	}
+	return "CREATE TABLE " + defaultHandle.quotedTable(typ) + " (" + strings.Join(defs, ", ") + ")", nil
+}
+
+// columnDef returns the definition of the column of f in the dialect d, following its
+// name. inline reports whether the definition declares the column as the primary key,
+// which SQLite requires for auto-incremented keys. single is true if f is the only
+// primary key field.
+func columnDef(d Dialect, f *field, single bool) (def string, inline bool, err error) {
+	typ, nullable := columnGoType(f.typ)
+	if f.split != "" || f.timeFormat != "" {
+		typ = reflect.TypeOf("")
+	}
+	size, _ := f.opts.Value("size")
+	if size != "" {
+		if n, err := strconv.Atoi(size); err != nil || n <= 0 {
+			return "", false, fmt.Errorf("invalid size %q", size)
+		}
+	}
+
+	if f.auto && f.pk && isIntKind(typ.Kind()) {
+		switch d {
+		case Postgres:
+			if typ.Size() > 4 {
+				return "BIGSERIAL NOT NULL", false, nil
+			}
+			return "SERIAL NOT NULL", false, nil
+		case SQLite:
+			if !single {
+				return "", false, fmt.Errorf("SQLite only auto-increments a single primary key")
+			}
+			return "INTEGER PRIMARY KEY AUTOINCREMENT", true, nil
+		}
+		def, err := columnType(d, typ, "", true)
+		if err != nil {
+			return "", false, err
+		}
+		switch d {
+		case MySQL:
+			return def + " NOT NULL AUTO_INCREMENT", false, nil
+		case SQLServer:
+			return def + " IDENTITY(1,1) NOT NULL", false, nil
+		default:
+			return def + " GENERATED BY DEFAULT AS IDENTITY NOT NULL", false, nil
+		}
+	}
+
+	def, err = columnType(d, typ, size, f.pk)
+	if err != nil {
+		return "", false, err
+	}
+	if !nullable || f.pk {
+		def += " NOT NULL"
+	}
+	if value, ok := f.opts.Value("default"); ok {
+		def += " DEFAULT " + value
+	}
+	return def, false, nil
+}
+
+// columnGoType returns the type of the values stored by a field of type typ, and
+// whether the field may be NULL. Pointers and the Null types of database/sql, such as
+// sql.NullString or sql.Null[T], are nullable.
+func columnGoType(typ reflect.Type) (reflect.Type, bool) {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem(), true
+	}
+	if typ.Kind() == reflect.Struct && typ.PkgPath() == "database/sql" && typ.NumField() == 2 &&
+		typ.Field(1).Name == "Valid" && typ.Field(1).Type.Kind() == reflect.Bool {
+		return typ.Field(0).Type, true
+	}
+	return typ, false
+}
+
+// isIntKind reports whether k is a signed or unsigned integer kind.
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// columnType returns the column type in the dialect d storing values of typ. size is
+// the length of VARCHAR columns, if given. Text types which cannot be indexed by some
+// databases are avoided for primary keys if pk is true.
+func columnType(d Dialect, typ reflect.Type, size string, pk bool) (string, error) {
+	switch {
+	case typ == timeType:
+		switch d {
+		case Postgres:
+			return "TIMESTAMP WITH TIME ZONE", nil
+		case MySQL:
+			return "DATETIME", nil
+		case SQLServer:
+			return "DATETIME2", nil
+		}
+		return "TIMESTAMP", nil
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		switch d {
+		case Postgres:
+			return "BYTEA", nil
+		case SQLServer:
+			return "VARBINARY(MAX)", nil
+		}
+		return "BLOB", nil
+	case isIntKind(typ.Kind()):
+		wide := typ.Size() > 4 || typ.Kind() == reflect.Uint32
+		switch {
+		case d == SQLite:
+			return "INTEGER", nil
+		case d == Oracle && wide:
+			return "NUMBER(19)", nil
+		case d == Oracle:
+			return "NUMBER(10)", nil
+		case wide:
+			return "BIGINT", nil
+		}
+		return "INTEGER", nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		switch d {
+		case SQLServer:
+			return "BIT", nil
+		case Oracle:
+			return "NUMBER(1)", nil
+		}
+		return "BOOLEAN", nil
+	case reflect.Float32, reflect.Float64:
+		switch d {
+		case MySQL:
+			return "DOUBLE", nil
+		case SQLite:
+			return "REAL", nil
+		case SQLServer:
+			return "FLOAT", nil
+		case Oracle:
+			return "BINARY_DOUBLE", nil
+		}
+		return "DOUBLE PRECISION", nil
+	case reflect.String:
+		if size == "" && pk && (d == MySQL || d == SQLServer || d == Oracle) {
+			size = "255"
+		}
+		switch {
+		case size != "" && d == Oracle:
+			return "VARCHAR2(" + size + ")", nil
+		case size != "" && d == SQLServer:
+			return "NVARCHAR(" + size + ")", nil
+		case size != "":
+			return "VARCHAR(" + size + ")", nil
+		case d == SQLServer:
+			return "NVARCHAR(MAX)", nil
+		case d == Oracle:
+			return "CLOB", nil
+		}
+		return "TEXT", nil
+	}
+	return "", fmt.Errorf("no column type for %s", typ)
+}
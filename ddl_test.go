@@ -0,0 +1,50 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+type testAccount struct {
+	Id      int64          `sql:"id,pk,auto"`
+	Name    string         `sql:"name,size=100"`
+	Email   *string        `sql:"email"`
+	Score   sql.NullInt32  `sql:"score"`
+	Active  bool           `sql:"active,default=1"`
+	Avatar  []byte         `sql:"avatar"`
+	Created time.Time      `sql:"created,default=CURRENT_TIMESTAMP"`
+	Tags    []string       `sql:"tags,split=;"`
+	Note    sql.NullString `sql:"-"`
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{Postgres, "CREATE TABLE testaccount (active BOOLEAN NOT NULL DEFAULT 1, avatar BYTEA NOT NULL, created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, email TEXT, id BIGSERIAL NOT NULL, name VARCHAR(100) NOT NULL, score INTEGER, tags TEXT NOT NULL, PRIMARY KEY (id))"},
+		{SQLite, "CREATE TABLE testaccount (active BOOLEAN NOT NULL DEFAULT 1, avatar BLOB NOT NULL, created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, email TEXT, id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(100) NOT NULL, score INTEGER, tags TEXT NOT NULL)"},
+		{MySQL, "CREATE TABLE testaccount (active BOOLEAN NOT NULL DEFAULT 1, avatar BLOB NOT NULL, created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, email TEXT, id BIGINT NOT NULL AUTO_INCREMENT, name VARCHAR(100) NOT NULL, score INTEGER, tags TEXT NOT NULL, PRIMARY KEY (id))"},
+		{SQLServer, "CREATE TABLE testaccount (active BIT NOT NULL DEFAULT 1, avatar VARBINARY(MAX) NOT NULL, created DATETIME2 NOT NULL DEFAULT CURRENT_TIMESTAMP, email NVARCHAR(MAX), id BIGINT IDENTITY(1,1) NOT NULL, name NVARCHAR(100) NOT NULL, score INTEGER, tags NVARCHAR(MAX) NOT NULL, PRIMARY KEY (id))"},
+	}
+	for _, test := range tests {
+		ddl, err := CreateTableSQL[testAccount](test.dialect)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.dialect, err)
+		}
+		if ddl != test.expected {
+			t.Errorf("%s: expected %q got %q", test.dialect, test.expected, ddl)
+		}
+	}
+
+	type bad struct {
+		Data map[string]int `sql:"data"`
+	}
+	if _, err := CreateTableSQL[bad](Postgres); err == nil {
+		t.Error("expected error for field without a column type")
+	}
+}
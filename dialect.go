@@ -0,0 +1,156 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the flavour of SQL understood by a database. It is used where
+// generated SQL differs between databases.
+type Dialect int
+
+const (
+	// Generic is a dialect using only syntax common to most databases, as
+	// well as LIMIT clauses.
+	Generic Dialect = iota
+	MySQL
+	Postgres
+	SQLite
+	SQLServer
+	Oracle
+)
+
+// DefaultDialect is the dialect of the database set with SetDatabase.
+var DefaultDialect = Generic
+
+var dialectNames = [...]string{"Generic", "MySQL", "Postgres", "SQLite", "SQLServer", "Oracle"}
+
+func (d Dialect) String() string {
+	if d >= 0 && int(d) < len(dialectNames) {
+		return dialectNames[d]
+	}
+	return "Dialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+var (
+	hasLimit   = regexp.MustCompile(`(?i)\b(LIMIT|TOP|FETCH\s+(FIRST|NEXT))\b`)
+	hasLocking = regexp.MustCompile(`(?i)\b(FOR\s+(UPDATE|SHARE|NO\s+KEY\s+UPDATE|KEY\s+SHARE)|LOCK\s+IN\s+SHARE\s+MODE)\b`)
+	hasUnion   = regexp.MustCompile(`(?i)\b(UNION|INTERSECT|EXCEPT)\b`)
+	selectHead = regexp.MustCompile(`(?i)^\s*SELECT(\s+(DISTINCT|ALL))?\s`)
+)
+
+// limitOne restricts query to return at most one row. Queries which already limit
+// their results, or which cannot safely be rewritten, are returned unchanged.
+func (d Dialect) limitOne(query string) string {
+	head := selectHead.FindStringIndex(query)
+	if head == nil || hasLimit.MatchString(query) || hasLocking.MatchString(query) {
+		return query
+	}
+
+	switch d {
+	case SQLServer:
+		if hasUnion.MatchString(query) {
+			return query
+		}
+		return query[:head[1]] + "TOP 1 " + query[head[1]:]
+	case Oracle:
+		return strings.TrimRight(query, "; \t\r\n") + " FETCH FIRST 1 ROWS ONLY"
+	default:
+		return strings.TrimRight(query, "; \t\r\n") + " LIMIT 1"
+	}
+}
+
+// limit returns the clause appended to a query to return at most n rows after
+// skipping the first offset rows. SQLServer requires the query to have an ORDER BY
+// clause.
+func (d Dialect) limit(n, offset int) string {
+	switch d {
+	case SQLServer, Oracle:
+		return " OFFSET " + strconv.Itoa(offset) + " ROWS FETCH NEXT " + strconv.Itoa(n) + " ROWS ONLY"
+	default:
+		if offset == 0 {
+			return " LIMIT " + strconv.Itoa(n)
+		}
+		return " LIMIT " + strconv.Itoa(n) + " OFFSET " + strconv.Itoa(offset)
+	}
+}
+
+// Rebind converts the ? placeholders in query to the bind parameter syntax of
+// DefaultDialect. See Dialect.Rebind.
+func Rebind(query string) string {
+	return DefaultDialect.Rebind(query)
+}
+
+// Rebind converts the ? placeholders in query to the bind parameter syntax of d:
+// $1, $2, ... for Postgres, @p1, @p2, ... for SQLServer and :1, :2, ... for Oracle.
+// Other dialects use ? and get query unchanged. Question marks inside quoted strings
+// and identifiers are left alone. Queries run by this package are rebound
+// automatically, so they may always be written with ? placeholders.
+func (d Dialect) Rebind(query string) string {
+	var prefix string
+	switch d {
+	case Postgres:
+		prefix = "$"
+	case SQLServer:
+		prefix = "@p"
+	case Oracle:
+		prefix = ":"
+	default:
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(prefix)
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Quoter, if set, quotes the table and column names in the SQL generated by this
+// package, including the output of Columns and ColumnsAliased. This allows columns
+// named after reserved words such as order or group. For example:
+//
+//	sqlstruct.Quoter = sqlstruct.MySQL.Quote
+//
+// By default identifiers are not quoted.
+var Quoter func(string) string
+
+// Quote quotes the identifier ident for d: with backticks for MySQL, brackets for
+// SQLServer and double quotes otherwise. Each part of a qualified name such as
+// schema.table is quoted separately.
+func (d Dialect) Quote(ident string) string {
+	open, close := `"`, `"`
+	switch d {
+	case MySQL:
+		open, close = "`", "`"
+	case SQLServer:
+		open, close = "[", "]"
+	}
+	parts := strings.Split(ident, ".")
+	for i, p := range parts {
+		parts[i] = open + strings.ReplaceAll(p, close, close+close) + close
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,95 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLimitOne(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		query    string
+		expected string
+	}{
+		{Generic, "SELECT a FROM t;", "SELECT a FROM t LIMIT 1"},
+		{Postgres, "SELECT a FROM t LIMIT 5", "SELECT a FROM t LIMIT 5"},
+		{MySQL, "SELECT a FROM t FOR UPDATE", "SELECT a FROM t FOR UPDATE"},
+		{SQLServer, "SELECT DISTINCT a FROM t", "SELECT DISTINCT TOP 1 a FROM t"},
+		{SQLServer, "select top 3 a from t", "select top 3 a from t"},
+		{Oracle, "SELECT a FROM t", "SELECT a FROM t FETCH FIRST 1 ROWS ONLY"},
+		{Generic, "INSERT INTO t VALUES (1) RETURNING a", "INSERT INTO t VALUES (1) RETURNING a"},
+	}
+	for _, test := range tests {
+		if q := test.dialect.limitOne(test.query); q != test.expected {
+			t.Errorf("%s: expected %q got %q", test.dialect, test.expected, q)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT * FROM t WHERE a = ? AND b = '?' AND c IN (?, ?)"
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{Generic, query},
+		{MySQL, query},
+		{Postgres, "SELECT * FROM t WHERE a = $1 AND b = '?' AND c IN ($2, $3)"},
+		{SQLServer, "SELECT * FROM t WHERE a = @p1 AND b = '?' AND c IN (@p2, @p3)"},
+		{Oracle, "SELECT * FROM t WHERE a = :1 AND b = '?' AND c IN (:2, :3)"},
+	}
+	for _, test := range tests {
+		if q := test.dialect.Rebind(query); q != test.expected {
+			t.Errorf("%s: expected %q got %q", test.dialect, test.expected, q)
+		}
+	}
+
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"})
+	if _, err := Query[testUser]("SELECT * FROM users WHERE id = ?", 1, WithDialect(Postgres)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "SELECT id, name FROM users WHERE id = $1"; fake.queries[0] != expected {
+		t.Errorf("expected %q got %q", expected, fake.queries[0])
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		ident    string
+		expected string
+	}{
+		{MySQL, "order", "`order`"},
+		{Postgres, `a"b`, `"a""b"`},
+		{SQLite, "main.group", `"main"."group"`},
+		{SQLServer, "order", "[order]"},
+	}
+	for _, test := range tests {
+		if q := test.dialect.Quote(test.ident); q != test.expected {
+			t.Errorf("%s: expected %s got %s", test.dialect, test.expected, q)
+		}
+	}
+
+	h := &Handle{Quoter: MySQL.Quote}
+	type order struct {
+		Id    int `sql:"id,pk"`
+		Group string
+	}
+	if cols, expected := h.Columns(order{}), "`group`, `id`"; cols != expected {
+		t.Errorf("expected %s got %s", expected, cols)
+	}
+
+	fake := setFakeDatabase(t)
+	defer func() { Quoter = nil }()
+	Quoter = Postgres.Quote
+	if _, err := Update(context.Background(), order{1, "a"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := `UPDATE "order" SET "group" = ? WHERE "id" = ?`; fake.queries[0] != expected {
+		t.Errorf("expected %s got %s", expected, fake.queries[0])
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Rewriter, if set, is called with every statement executed by this package after
+// QueryReplace has been expanded and before the statement is sent to the database.
+// It may return a modified statement, for example to add a schema prefix, a comment
+// or tenant scoping, or an error to prevent the statement from running. It is used
+// for a Handle whose own Rewriter is nil.
+var Rewriter func(ctx context.Context, query string) (string, error)
+
+type rewriterKey struct{}
+
+// rewrite applies the Rewriter of the Handle running the statement, as recorded in
+// ctx, or the package-level Rewriter to query.
+func rewrite(ctx context.Context, query string) (string, error) {
+	rw, _ := ctx.Value(rewriterKey{}).(func(context.Context, string) (string, error))
+	if rw == nil {
+		rw = Rewriter
+	}
+	if rw == nil {
+		return query, nil
+	}
+	return rw(ctx, query)
+}
+
+// Statement is a SQL statement along with the arguments for its placeholders.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+type dryRunKey struct{}
+
+// ContextWithDryRun returns a copy of ctx which makes the functions of this package
+// append the statements they would execute to stmts instead of running them. Queries
+// run this way return no rows and statements report no rows affected. stmts must not
+// be used by multiple goroutines at once.
+func ContextWithDryRun(ctx context.Context, stmts *[]Statement) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, stmts)
+}
+
+// dryRun records query in the dry run log of ctx, if any, and reports whether it did so.
+func dryRun(ctx context.Context, query string, args []interface{}) bool {
+	stmts, ok := ctx.Value(dryRunKey{}).(*[]Statement)
+	if ok {
+		*stmts = append(*stmts, Statement{query, args})
+	}
+	return ok
+}
+
+// dryRunResult is the sql.Result of a statement which was not executed.
+type dryRunResult struct{}
+
+func (dryRunResult) LastInsertId() (int64, error) { return 0, nil }
+func (dryRunResult) RowsAffected() (int64, error) { return 0, nil }
+
+// runQuery runs query on q and passes the resulting rows to scan, which returns the
+// number of rows it read. The rows are closed afterwards. All queries executed by this
+// package go through runQuery. When running dry, scan is not called.
+func runQuery(ctx context.Context, q Queryer, query string, args []interface{}, scan func(*sql.Rows) (int64, error)) error {
+	query, err := rewrite(ctx, query)
+	if err != nil {
+		return err
+	}
+	if dryRun(ctx, query, args) {
+		return nil
+	}
+
+	ctx, end := startStatement(ctx, "query", query, args)
+	start := time.Now()
+	queryStarted(ctx)
+	rows, err := q.QueryContext(ctx, query, args...)
+	var n int64
+	if err == nil {
+		n, err = scan(rows)
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}
+	queryFinished(ctx, start, n, err)
+	end(n, err)
+	logStatement(ctx, "query", query, start, n, err)
+	callQueryHook(ctx, query, args, start, err)
+	return err
+}
+
+// runExec runs the statement query on e. All statements executed by this package
+// go through runExec.
+func runExec(ctx context.Context, e Execer, query string, args []interface{}) (sql.Result, error) {
+	query, err := rewrite(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun(ctx, query, args) {
+		return dryRunResult{}, nil
+	}
+
+	ctx, end := startStatement(ctx, "exec", query, args)
+	start := time.Now()
+	queryStarted(ctx)
+	res, err := e.ExecContext(ctx, query, args...)
+	var n int64
+	if err == nil {
+		n, _ = res.RowsAffected()
+	}
+	queryFinished(ctx, start, n, err)
+	end(n, err)
+	logStatement(ctx, "exec", query, start, n, err)
+	callQueryHook(ctx, query, args, start, err)
+	return res, err
+}
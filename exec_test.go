@@ -0,0 +1,126 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRewriter(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	defer func() { Rewriter = nil }()
+	Rewriter = func(ctx context.Context, query string) (string, error) {
+		return "/* app */ " + query, nil
+	}
+
+	if _, err := Query[testUser]("SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "/* app */ SELECT id, name FROM users"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+
+	errRejected := errors.New("rejected")
+	Rewriter = func(ctx context.Context, query string) (string, error) {
+		return "", errRejected
+	}
+	if _, err := Query[testUser]("SELECT * FROM users"); err != errRejected {
+		t.Errorf("expected %v got %v", errRejected, err)
+	}
+	if len(fake.queries) != 1 {
+		t.Errorf("expected rejected query not to run, got %q", fake.queries)
+	}
+}
+
+func TestHandleRewriter(t *testing.T) {
+	db, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"})
+	tenant := func(name string) *Handle {
+		h := New(db)
+		h.Rewriter = func(ctx context.Context, query string) (string, error) {
+			return "/* tenant " + name + " */ " + query, nil
+		}
+		return h
+	}
+
+	ctx := context.Background()
+	for _, h := range []*Handle{tenant("a"), tenant("b")} {
+		if _, err := QueryContext[testUser](ctx, "SELECT * FROM users", WithHandle(h)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	expected := []string{"/* tenant a */ SELECT id, name FROM users", "/* tenant b */ SELECT id, name FROM users"}
+	if !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected %q got %q", expected, fake.queries)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	old := defaultHandle.db
+	SetDatabase(nil)
+	defer SetDatabase(old)
+
+	var stmts []Statement
+	users, err := Query[testUser]("SELECT * FROM users WHERE id = ?", 1, DryRun(&stmts))
+	if err != nil || users != nil {
+		t.Fatalf("expected no users and no error got %v, %v", users, err)
+	}
+	if len(stmts) != 1 || stmts[0].Query != "SELECT id, name FROM users WHERE id = ?" || len(stmts[0].Args) != 1 {
+		t.Errorf("unexpected statements %v", stmts)
+	}
+
+	ctx := ContextWithDryRun(context.Background(), &stmts)
+	res, err := runExec(ctx, nil, "DELETE FROM users", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n, _ := res.RowsAffected(); n != 0 {
+		t.Errorf("expected 0 rows affected got %d", n)
+	}
+	if len(stmts) != 2 || stmts[1].Query != "DELETE FROM users" {
+		t.Errorf("unexpected statements %v", stmts)
+	}
+}
+
+// testTracer records the statements traced by it.
+type testTracer struct {
+	traced []string
+	rows   []int64
+}
+
+func (t *testTracer) StartStatement(ctx context.Context, kind, name, query string, args []interface{}) (context.Context, func(int64, error)) {
+	t.traced = append(t.traced, kind+" "+name+": "+query)
+	return ctx, func(rows int64, err error) {
+		t.rows = append(t.rows, rows)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	tracer := &testTracer{}
+	defer func() { Tracer = nil }()
+	Tracer = tracer
+
+	if _, err := Query[testUser]("SELECT * FROM users", QueryName("users")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := Insert(context.Background(), testUser{3, "c"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"query users: SELECT id, name FROM users", "exec : INSERT INTO users (id, name) VALUES (?, ?)"}
+	if !reflect.DeepEqual(tracer.traced, expected) {
+		t.Errorf("expected %q got %q", expected, tracer.traced)
+	}
+	if expected := []int64{2, 1}; !reflect.DeepEqual(tracer.rows, expected) {
+		t.Errorf("expected rows %v got %v", expected, tracer.rows)
+	}
+}
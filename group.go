@@ -0,0 +1,107 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// GroupJoined scans the rows of a one-to-many join of parents of type P with their
+// children of type C, and returns the parents with their children appended to the
+// slice field of P named field, which must be of type []C or []*C. For example:
+//
+//	type User struct {
+//		Id     int     `sql:"id,pk"`
+//		Name   string  `sql:"name"`
+//		Orders []Order `sql:"-"`
+//	}
+//
+//	rows, err := db.Query("SELECT " + sqlstruct.ColumnsAliased(User{}, "u") + ", " +
+//		sqlstruct.ColumnsAliased(Order{}, "o") +
+//		" FROM users AS u LEFT JOIN orders AS o ON o.user_id = u.id ORDER BY u.id")
+//	...
+//	users, err := sqlstruct.GroupJoined[User, Order](rows, "u", "o", "Orders")
+//
+// The columns of the parents and children are prefixed by their aliases, as with
+// ScanJoined. Rows are grouped by the fields of P tagged with the "pk" option, which
+// P must have and which must be comparable or byte slices, as for binary UUIDs;
+// parents are returned in the order in which they first appear. A row whose child
+// has only zero fields, as scanned from the NULL columns of a LEFT JOIN into pointer
+// or sql.Null fields, adds no child. rows are closed afterwards.
+func GroupJoined[P, C any](rows RowsIter, parentAlias, childAlias, field string) ([]P, error) {
+	defer rows.Close()
+
+	ptyp := reflect.TypeOf((*P)(nil)).Elem()
+	ctyp := reflect.TypeOf((*C)(nil)).Elem()
+	sf, ok := ptyp.FieldByName(field)
+	if !ok || sf.Type != reflect.SliceOf(ctyp) && sf.Type != reflect.SliceOf(reflect.PointerTo(ctyp)) {
+		return nil, fmt.Errorf("sqlstruct: %v has no field %s of type []%v or []*%v", ptyp, field, ctyp, ctyp)
+	}
+	fields, err := getFieldInfo(ptyp)
+	if err != nil {
+		return nil, err
+	}
+	keys := fields.primaryKeys()
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("sqlstruct: GroupJoined requires a primary key field in %v", ptyp)
+	}
+	for _, f := range keys {
+		if !f.typ.Comparable() && !isBytes(f.typ) {
+			return nil, fmt.Errorf("sqlstruct: GroupJoined cannot group by primary key field %s of %v of type %v", f.name, ptyp, f.typ)
+		}
+	}
+	keyType := reflect.ArrayOf(len(keys), reflect.TypeOf((*interface{})(nil)).Elem())
+
+	var result []P
+	index := make(map[interface{}]int)
+	ctx := context.Background()
+	for rows.Next() {
+		var p P
+		var c C
+		if err := defaultHandle.scanJoined(ctx, rows, []AliasedDest{{parentAlias, &p}, {childAlias, &c}}, false); err != nil {
+			return nil, err
+		}
+
+		pv := reflect.ValueOf(&p).Elem()
+		key := reflect.New(keyType).Elem()
+		for i, f := range keys {
+			fv, err := pv.FieldByIndexErr(f.index)
+			switch {
+			case err != nil:
+			case isBytes(fv.Type()):
+				key.Index(i).Set(reflect.ValueOf(string(fv.Bytes())))
+			default:
+				key.Index(i).Set(fv)
+			}
+		}
+		i, ok := index[key.Interface()]
+		if !ok {
+			i = len(result)
+			index[key.Interface()] = i
+			result = append(result, p)
+		}
+
+		cv := reflect.ValueOf(&c)
+		if cv.Elem().IsZero() {
+			continue
+		}
+		if sf.Type.Elem() == ctyp {
+			cv = cv.Elem()
+		}
+		children := reflect.ValueOf(&result[i]).Elem().FieldByIndex(sf.Index)
+		children.Set(reflect.Append(children, cv))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// isBytes reports whether typ is a slice of bytes.
+func isBytes(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
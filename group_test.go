@@ -0,0 +1,87 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+type groupOrder struct {
+	Id   sql.NullInt64  `sql:"id"`
+	Item sql.NullString `sql:"item"`
+}
+
+type groupUser struct {
+	Id     int          `sql:"id,pk"`
+	Name   string       `sql:"name"`
+	Orders []groupOrder `sql:"-"`
+}
+
+func TestGroupJoined(t *testing.T) {
+	rows := &testIter{
+		columns: []string{"u_id", "u_name", "o_id", "o_item"},
+		rows: [][]interface{}{
+			{int64(1), "a", int64(10), "x"},
+			{int64(2), "b", nil, nil},
+			{int64(1), "a", int64(11), "y"},
+		},
+	}
+	users, err := GroupJoined[groupUser, groupOrder](rows, "u", "o", "Orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	order := func(id int64, item string) groupOrder {
+		return groupOrder{sql.NullInt64{Int64: id, Valid: true}, sql.NullString{String: item, Valid: true}}
+	}
+	expected := []groupUser{
+		{1, "a", []groupOrder{order(10, "x"), order(11, "y")}},
+		{2, "b", nil},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %v got %v", expected, users)
+	}
+	if !rows.closed {
+		t.Error("expected rows to be closed")
+	}
+
+	if _, err := GroupJoined[groupUser, groupOrder](&testIter{}, "u", "o", "Name"); err == nil {
+		t.Error("expected error for field of the wrong type")
+	}
+	if _, err := GroupJoined[groupOrder, groupUser](&testIter{}, "o", "u", "Missing"); err == nil {
+		t.Error("expected error for missing field")
+	}
+}
+
+type groupDevice struct {
+	Id     []byte       `sql:"id,pk"`
+	Orders []groupOrder `sql:"-"`
+}
+
+func TestGroupJoinedBytesKey(t *testing.T) {
+	rows := &testIter{
+		columns: []string{"d_id", "o_id", "o_item"},
+		rows: [][]interface{}{
+			{[]byte{1, 2}, int64(10), "x"},
+			{[]byte{3}, int64(11), "y"},
+			{[]byte{1, 2}, int64(12), "z"},
+		},
+	}
+	devices, err := GroupJoined[groupDevice, groupOrder](rows, "d", "o", "Orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(devices) != 2 || len(devices[0].Orders) != 2 || len(devices[1].Orders) != 1 {
+		t.Errorf("unexpected result %v", devices)
+	}
+
+	type tagged struct {
+		Tags   []string     `sql:"tags,pk"`
+		Orders []groupOrder `sql:"-"`
+	}
+	if _, err := GroupJoined[tagged, groupOrder](&testIter{}, "t", "o", "Orders"); err == nil {
+		t.Error("expected error for a primary key which is not comparable")
+	}
+}
@@ -0,0 +1,465 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Handle is a database together with the configuration used to map structs to its
+// columns. Each Handle has its own cache of struct mappings, so handles with different
+// configurations can be used side by side. The package-level functions use a default
+// Handle configured by NameMapper, TagName and SetDatabase.
+//
+// A Handle is safe for concurrent use. Its configuration must not be changed after
+// it has been used.
+type Handle struct {
+	// NameMapper converts the names of struct fields without sql tags into column
+	// names. If nil, the package-level NameMapper is used.
+	NameMapper func(string) string
+
+	// TagName is the name of the struct tag holding column names and options.
+	// If empty, the package-level TagName is used.
+	TagName string
+
+	// TagNames, if not empty, replaces TagName with a list of tag names consulted in
+	// order for each field, as described for the package-level TagNames.
+	TagNames []string
+
+	// QueryReplace is the token replaced by the columns of the result type in the
+	// queries run with h. If empty, the package-level QueryReplace is used.
+	QueryReplace string
+
+	// Dialect is the dialect of SQL generated for the database of h. New sets it
+	// to DefaultDialect.
+	Dialect Dialect
+
+	// Quoter quotes the identifiers in the SQL generated for h. If nil, the
+	// package-level Quoter is used.
+	Quoter func(string) string
+
+	// Rewriter, if set, rewrites every statement executed on the database of h, as
+	// described for the package-level Rewriter, which is used if it is nil. Handles
+	// for different tenants may scope their statements with their own Rewriters.
+	Rewriter func(ctx context.Context, query string) (string, error)
+
+	// Logger, if set, receives a record for every statement executed on the database
+	// of h, as described for the package-level Logger, which is used if it is nil.
+	Logger *slog.Logger
+
+	// QueryHook, if set, is called after every statement executed on the database
+	// of h, as described for the package-level QueryHook, which is used if it is nil.
+	QueryHook func(ctx context.Context, query string, args []interface{}, d time.Duration, err error)
+
+	// Metrics, if set, receives measurements of the work done for h, so that each
+	// database can report separately. The package-level Metrics is used if it is nil.
+	Metrics MetricsCollector
+
+	// StatementCacheSize is the number of prepared statements kept open for the
+	// queries and statements run on the database of h, keyed by their final SQL text.
+	// When it is 0, statements are not prepared in advance. Close closes the cached
+	// statements.
+	StatementCacheSize int
+
+	// DefaultTimeout, if positive, limits the duration of the queries and statements
+	// run with h whose context has no deadline, as if they were given the Timeout
+	// option. The package-level DefaultTimeout is used if it is 0.
+	DefaultTimeout time.Duration
+
+	// Retry, if set, retries the queries and statements run on the database of h
+	// which fail with transient errors. The package-level Retry is used if it is nil.
+	Retry *RetryPolicy
+
+	// NullToZero makes the scans done with h set fields which are not pointers to
+	// their zero value for NULL columns, instead of failing, as the NullToZero option
+	// does for a single call.
+	NullToZero bool
+
+	// TimeLayouts and TimeLocation configure the scanning of times returned as text
+	// into the time fields of h, as described for the package-level TimeLayouts and
+	// TimeLocation, which are used if they are not set. They must be set before h is
+	// first used.
+	TimeLayouts  []string
+	TimeLocation *time.Location
+
+	db       *sql.DB
+	replicas []*sql.DB
+
+	// The index of the replica to run the next query on, modulo their number.
+	nextReplica atomic.Uint64
+
+	stmtOnce     sync.Once
+	stmts        *stmtCache
+	replicaStmts []*stmtCache
+
+	// A cache of fieldInfos to save reflecting every time, keyed by fieldKey.
+	// Inspried by encoding/xml
+	finfos sync.Map
+
+	// A cache of the comma-separated column lists of types, keyed by columnKey.
+	columnLists sync.Map
+
+	// A cache of scanPlans, keyed by planKey.
+	plans sync.Map
+}
+
+// defaultHandle is the Handle used by the package-level functions.
+var defaultHandle = &Handle{}
+
+// New returns a Handle for db.
+//
+// For example:
+//
+//	h := sqlstruct.New(db)
+//	h.NameMapper = sqlstruct.ToSnakeCase
+//
+//	var users []User
+//	err := h.Query(&users, "SELECT * FROM users WHERE active = ?", true)
+func New(db *sql.DB) *Handle {
+	return &Handle{db: db, Dialect: DefaultDialect}
+}
+
+// NewWithReplicas returns a Handle which runs statements and transactions on the
+// primary database and spreads the queries over the replicas, in turn. Queries which
+// must see the latest writes, such as those following them closely, can be run on the
+// primary with the Primary option:
+//
+//	h := sqlstruct.NewWithReplicas(primary, replica1, replica2)
+//	users, err := sqlstruct.Query[User]("SELECT * FROM users", sqlstruct.WithHandle(h))
+//	user, err := sqlstruct.QueryRow[User]("SELECT * FROM users WHERE id = ?", id, sqlstruct.WithHandle(h), sqlstruct.Primary())
+//
+// Queries which modify the database, such as INSERT ... RETURNING, must also be run
+// with the Primary option; Insert does so itself. DB returns the primary database.
+// Without replicas, the Handle works like one returned by New.
+func NewWithReplicas(primary *sql.DB, replicas ...*sql.DB) *Handle {
+	h := New(primary)
+	h.replicas = replicas
+	return h
+}
+
+// Config is the configuration of a Handle created with NewWithConfig. Its fields
+// correspond to the package-level settings of the same names.
+type Config struct {
+	NameMapper   func(string) string
+	TagName      string
+	TagNames     []string
+	QueryReplace string
+	Quoter       func(string) string
+	Dialect      Dialect
+	TimeLayouts  []string
+	TimeLocation *time.Location
+}
+
+// NewWithConfig returns a Handle for db configured by c. Unlike New, the settings not
+// given in c are copied from the package-level ones when the Handle is created, so
+// later changes to the package-level settings, for example by another library in the
+// same program, do not affect it. db may be nil for a Handle which is only used to
+// scan rows and list columns:
+//
+//	h := sqlstruct.NewWithConfig(nil, sqlstruct.Config{TagName: "db", NameMapper: sqlstruct.ToSnakeCase})
+//	err := h.Scan(&user, rows)
+//
+// The Handle can be passed to the generic functions with the WithHandle option.
+func NewWithConfig(db *sql.DB, c Config) *Handle {
+	h := &Handle{
+		db:           db,
+		NameMapper:   c.NameMapper,
+		TagNames:     c.TagNames,
+		QueryReplace: c.QueryReplace,
+		Quoter:       c.Quoter,
+		Dialect:      c.Dialect,
+		TimeLayouts:  c.TimeLayouts,
+		TimeLocation: c.TimeLocation,
+	}
+	if h.NameMapper == nil {
+		h.NameMapper = NameMapper
+	}
+	if len(h.TagNames) == 0 {
+		h.TagNames = defaultHandle.tagNames()
+		if c.TagName != "" {
+			h.TagNames = []string{c.TagName}
+		}
+	}
+	h.TagNames = append([]string(nil), h.TagNames...)
+	if h.QueryReplace == "" {
+		h.QueryReplace = QueryReplace
+	}
+	if h.Quoter == nil {
+		h.Quoter = Quoter
+	}
+	if len(h.TimeLayouts) == 0 {
+		h.TimeLayouts = TimeLayouts
+	}
+	if h.TimeLocation == nil {
+		h.TimeLocation = TimeLocation
+	}
+	if h.Quoter == nil {
+		// Keep identifiers unquoted even if the package-level Quoter is set later.
+		h.Quoter = noQuote
+	}
+	return h
+}
+
+// SetDatabase sets the database used by the package-level Query and QueryRow.
+func SetDatabase(sqldb *sql.DB) {
+	defaultHandle.db = sqldb
+}
+
+// DB returns the database of h, or its primary database if it has replicas.
+func (h *Handle) DB() *sql.DB {
+	return h.db
+}
+
+// Close closes the prepared statements cached by h. The database of h is left open.
+func (h *Handle) Close() error {
+	// Initializing the caches, if no query did, orders the reads below after any
+	// concurrent initialization by a first query.
+	h.initStmts()
+	h.stmts.close()
+	for _, c := range h.replicaStmts {
+		c.close()
+	}
+	return nil
+}
+
+// queryer returns the Queryer running queries on the database of h, which caches
+// prepared statements if StatementCacheSize is set.
+func (h *Handle) queryer() Queryer {
+	if h.StatementCacheSize <= 0 {
+		return h.db
+	}
+	h.initStmts()
+	return h.stmts
+}
+
+// replica returns the Queryer running queries on the next replica of h in turn, or
+// on its primary database if it has no replicas.
+func (h *Handle) replica() Queryer {
+	if len(h.replicas) == 0 {
+		return h.queryer()
+	}
+	i := (h.nextReplica.Add(1) - 1) % uint64(len(h.replicas))
+	if h.StatementCacheSize <= 0 {
+		return h.replicas[i]
+	}
+	h.initStmts()
+	return h.replicaStmts[i]
+}
+
+// initStmts creates the caches of prepared statements of the databases of h.
+func (h *Handle) initStmts() {
+	h.stmtOnce.Do(func() {
+		h.stmts = newStmtCache(h.db, h.StatementCacheSize)
+		for _, db := range h.replicas {
+			h.replicaStmts = append(h.replicaStmts, newStmtCache(db, h.StatementCacheSize))
+		}
+	})
+}
+
+func (h *Handle) nameMapper() func(string) string {
+	if h.NameMapper != nil {
+		return h.NameMapper
+	}
+	return NameMapper
+}
+
+func (h *Handle) dialect() Dialect {
+	if h == defaultHandle {
+		return DefaultDialect
+	}
+	return h.Dialect
+}
+
+// timeLayouts returns the layouts and location of the times scanned from text into the
+// time fields of h, or nil layouts if times are scanned by database/sql.
+func (h *Handle) timeLayouts() ([]string, *time.Location) {
+	layouts, loc := h.TimeLayouts, h.TimeLocation
+	if len(layouts) == 0 {
+		layouts = TimeLayouts
+	}
+	if loc == nil {
+		loc = TimeLocation
+	}
+	if len(layouts) == 0 && loc != nil {
+		layouts = defaultTimeLayouts
+	}
+	return layouts, loc
+}
+
+// DefaultTimeout is the DefaultTimeout of the Handles which do not set their own,
+// including the one used by the package-level functions. It is 0 by default, so
+// queries are only limited by their contexts.
+var DefaultTimeout time.Duration
+
+// defaultTimeout returns the DefaultTimeout of h, or the package-level one.
+func (h *Handle) defaultTimeout() time.Duration {
+	if h.DefaultTimeout > 0 {
+		return h.DefaultTimeout
+	}
+	return DefaultTimeout
+}
+
+// quote quotes the identifier name with the Quoter of h, if any.
+func (h *Handle) quote(name string) string {
+	q := h.quoter()
+	if q == nil {
+		return name
+	}
+	return q(name)
+}
+
+// noQuote leaves identifiers as they are.
+func noQuote(name string) string { return name }
+
+// quoter returns the Quoter of h, or the package-level one.
+func (h *Handle) quoter() func(string) string {
+	if h.Quoter != nil {
+		return h.Quoter
+	}
+	return Quoter
+}
+
+// quoteAll quotes each of names, returning a new slice.
+func (h *Handle) quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = h.quote(name)
+	}
+	return quoted
+}
+
+// queryReplace returns the QueryReplace of h, or the package-level one.
+func (h *Handle) queryReplace() string {
+	if h.QueryReplace != "" {
+		return h.QueryReplace
+	}
+	return QueryReplace
+}
+
+// tagNames returns the names of the struct tags consulted for each field, in order.
+// The settings of h take precedence over the package-level ones, and TagNames over
+// TagName.
+func (h *Handle) tagNames() []string {
+	switch {
+	case len(h.TagNames) > 0:
+		return h.TagNames
+	case h.TagName != "":
+		return []string{h.TagName}
+	case len(TagNames) > 0:
+		return TagNames
+	}
+	return []string{TagName}
+}
+
+// fieldKey identifies the mapping of a struct type under tag names and a name mapper.
+// Mappings are cached by fieldKey so that changes to the package-level TagName,
+// TagNames or NameMapper take effect for types which have already been used.
+type fieldKey struct {
+	typ    reflect.Type
+	tag    string
+	mapper unsafe.Pointer
+}
+
+// fieldKey returns the fieldKey of typ under the current configuration of h.
+func (h *Handle) fieldKey(typ reflect.Type) fieldKey {
+	return fieldKey{typ, strings.Join(h.tagNames(), ","), funcID(h.nameMapper())}
+}
+
+// columnKey identifies a column list, which depends on the Quoter as well as on the
+// mapping of the type.
+type columnKey struct {
+	fieldKey
+	quoter unsafe.Pointer
+}
+
+// funcID returns the identity of the function value fn. Unlike its code pointer, it
+// differs between closures created by the same function literal, such as mappers
+// returned by a factory for different prefixes. Holding it in a cache key keeps the
+// closure alive, so that its address is not reused by another one.
+func funcID(fn func(string) string) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&fn))
+}
+
+// Columns works like the package-level Columns for the configuration of h.
+func (h *Handle) Columns(s interface{}) string {
+	return h.mustColumnList(reflect.TypeOf(s))
+}
+
+// Scan works like the package-level Scan for the configuration of h.
+func (h *Handle) Scan(dest interface{}, rows Rows) error {
+	return h.doScan(context.Background(), dest, rows, "", false, false)
+}
+
+// Query executes query on the database of h and stores its rows in the slice pointed
+// to by dest, whose elements must be structs. It otherwise works like the package-level
+// Query, including the replacement of QueryReplace and the handling of Options.
+func (h *Handle) Query(dest interface{}, query string, args ...interface{}) error {
+	return h.QueryContext(context.Background(), dest, query, args...)
+}
+
+// QueryContext works like Query but runs the query with ctx.
+func (h *Handle) QueryContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice || slice.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sqlstruct: dest must be pointer to slice of structs; got %T", dest)
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+
+	args, opts := splitOptions(args)
+	o := h.applyOptions(opts)
+
+	query, err := o.expand(query, elemType)
+	if err != nil {
+		return err
+	}
+	return o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		if err := scanSlice(ctx, rows, o, slice); err != nil {
+			return 0, err
+		}
+		return int64(slice.Len()), nil
+	})
+}
+
+// QueryRow works like Query but scans only the first row of the result into the struct
+// pointed to by dest. It returns ErrNoRows if the query selects no rows.
+func (h *Handle) QueryRow(dest interface{}, query string, args ...interface{}) error {
+	return h.QueryRowContext(context.Background(), dest, query, args...)
+}
+
+// QueryRowContext works like QueryRow but runs the query with ctx.
+func (h *Handle) QueryRowContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	typ := reflect.TypeOf(dest)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sqlstruct: dest must be pointer to struct; got %T", dest)
+	}
+
+	args, opts := splitOptions(args)
+	o := h.applyOptions(opts)
+
+	query, err := o.expand(query, typ.Elem())
+	if err != nil {
+		return err
+	}
+	return o.run(ctx, o.dialectOf().limitOne(query), args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error { return o.scan(ctx, dest, rows) })
+	})
+}
+
+// applyOptions returns the options for a call on h.
+func (h *Handle) applyOptions(opts []Option) options {
+	o := applyOptions(opts)
+	o.handle = h
+	return o
+}
@@ -0,0 +1,285 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandle(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	h := New(sqldb)
+	h.TagName = "db"
+	h.NameMapper = ToSnakeCase
+
+	type account struct {
+		AccountID int64
+		Owner     string `db:"owner_name" sql:"owner"`
+	}
+	if cols, expected := h.Columns(account{}), "account_id, owner_name"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+	if cols, expected := Columns(account{}), "accountid, owner"; cols != expected {
+		t.Errorf("expected the package configuration to be unchanged, expected %q got %q", expected, cols)
+	}
+
+	fake.setRows([]string{"account_id", "owner_name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+	var accounts []account
+	if err := h.Query(&accounts, "SELECT * FROM accounts"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(accounts) != 2 || accounts[1] != (account{2, "b"}) {
+		t.Errorf("unexpected result %v", accounts)
+	}
+	if q := "SELECT account_id, owner_name FROM accounts"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+
+	fake.setRows([]string{"account_id", "owner_name"})
+	var a account
+	if err := h.QueryRow(&a, "SELECT * FROM accounts WHERE account_id = ?", 3); err != sql.ErrNoRows {
+		t.Errorf("expected %v got %v", sql.ErrNoRows, err)
+	}
+	if err := h.Query(&a, "SELECT * FROM accounts"); err == nil {
+		t.Error("expected error for non-slice destination")
+	}
+}
+
+func TestNameMapperClosures(t *testing.T) {
+	prefixed := func(prefix string) func(string) string {
+		return func(name string) string { return prefix + strings.ToLower(name) }
+	}
+	type item struct {
+		Name string
+	}
+
+	h := New(nil)
+	h.NameMapper = prefixed("a_")
+	if cols := h.Columns(item{}); cols != "a_name" {
+		t.Errorf("expected a_name got %q", cols)
+	}
+	h.NameMapper = prefixed("b_")
+	if cols := h.Columns(item{}); cols != "b_name" {
+		t.Errorf("expected the mapping to follow the new mapper, expected b_name got %q", cols)
+	}
+}
+
+func TestNewWithConfig(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	h := NewWithConfig(sqldb, Config{TagName: "db", NameMapper: ToSnakeCase, QueryReplace: "{cols}"})
+
+	type account struct {
+		AccountID int64
+		Owner     string `db:"owner_name" sql:"owner"`
+	}
+	defer func(mapper func(string) string, q func(string) string) { NameMapper, Quoter = mapper, q }(NameMapper, Quoter)
+	NameMapper = strings.ToUpper
+	Quoter = func(s string) string { return `"` + s + `"` }
+	if cols, expected := h.Columns(account{}), "account_id, owner_name"; cols != expected {
+		t.Errorf("expected the handle to ignore later package-level changes, expected %q got %q", expected, cols)
+	}
+
+	fake.setRows([]string{"account_id", "owner_name"}, []driver.Value{int64(1), "a"})
+	accounts, err := Query[account]("SELECT {cols} FROM accounts", WithHandle(h))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(accounts) != 1 || accounts[0] != (account{1, "a"}) {
+		t.Errorf("unexpected result %v", accounts)
+	}
+	if q := "SELECT account_id, owner_name FROM accounts"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+
+	if cols, expected := Columns(account{}), `"ACCOUNTID", "OWNER"`; cols != expected {
+		t.Errorf("expected the package-level Quoter to be used, expected %q got %q", expected, cols)
+	}
+	Quoter = nil
+	if cols, expected := Columns(account{}), "ACCOUNTID, OWNER"; cols != expected {
+		t.Errorf("expected the columns to be requoted, expected %q got %q", expected, cols)
+	}
+}
+
+func TestFieldInfoCacheKey(t *testing.T) {
+	type account struct {
+		AccountID int    `db:"id"`
+		Owner     string `sql:"owner"`
+	}
+	if cols, expected := Columns(account{}), "accountid, owner"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+
+	defer func(tag string) { TagName = tag }(TagName)
+	TagName = "db"
+	if cols, expected := Columns(account{}), "id, owner"; cols != expected {
+		t.Errorf("expected the new TagName to take effect, expected %q got %q", expected, cols)
+	}
+}
+
+func TestTagNames(t *testing.T) {
+	type account struct {
+		AccountID int    `db:"id"`
+		Owner     string `sql:"owner" db:"account_owner"`
+		Secret    string `sql:"-" db:"secret"`
+		Plain     string
+	}
+
+	defer func(names []string) { TagNames = names }(TagNames)
+	TagNames = []string{"sql", "db"}
+	if cols, expected := Columns(account{}), "id, owner, plain"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+	TagNames = []string{"db", "sql"}
+	if cols, expected := Columns(account{}), "account_owner, id, plain, secret"; cols != expected {
+		t.Errorf("expected the new TagNames to take effect, expected %q got %q", expected, cols)
+	}
+
+	h := &Handle{TagName: "sql"}
+	if cols, expected := h.Columns(account{}), "accountid, owner, plain"; cols != expected {
+		t.Errorf("expected the TagName of the handle to take precedence, expected %q got %q", expected, cols)
+	}
+}
+
+type testLegacyUser struct {
+	UserID int `sql:"user_id"`
+	Name   string
+}
+
+func (testLegacyUser) MapName(name string) string { return strings.ToUpper(name) }
+
+func TestNamer(t *testing.T) {
+	if cols, expected := Columns(testLegacyUser{}), "NAME, USER_ID"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+	if table := tableName(reflect.TypeOf(testLegacyUser{})); table != "TESTLEGACYUSER" {
+		t.Errorf("expected table TESTLEGACYUSER got %q", table)
+	}
+	if cols, expected := Columns(testUser{}), "id, name"; cols != expected {
+		t.Errorf("expected other types to use NameMapper, expected %q got %q", expected, cols)
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	h := New(sqldb)
+	h.DefaultTimeout = time.Minute
+	var deadline time.Time
+	var ok bool
+	h.QueryHook = func(ctx context.Context, query string, args []interface{}, d time.Duration, err error) {
+		deadline, ok = ctx.Deadline()
+	}
+
+	fake.setRows([]string{"id", "name"})
+	var users []testUser
+	if err := h.Query(&users, "SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected the default timeout, got deadline %v %v", deadline, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if err := h.QueryContext(ctx, &users, "SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok || time.Until(deadline) < time.Minute {
+		t.Errorf("expected the deadline of the context to be kept, got %v", deadline)
+	}
+
+	h.DefaultTimeout = 0
+	if err := h.Query(&users, "SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ok {
+		t.Errorf("expected no deadline, got %v", deadline)
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	primary, pfake := newFakeDB(t)
+	r1, rfake1 := newFakeDB(t)
+	r2, rfake2 := newFakeDB(t)
+	h := NewWithReplicas(primary, r1, r2)
+	for _, f := range []*fakeDB{pfake, rfake1, rfake2} {
+		f.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := Query[testUser]("SELECT * FROM users", WithHandle(h)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if len(rfake1.queries) != 2 || len(rfake2.queries) != 2 || len(pfake.queries) != 0 {
+		t.Errorf("expected queries to alternate between replicas, got %d, %d and %d on the primary",
+			len(rfake1.queries), len(rfake2.queries), len(pfake.queries))
+	}
+
+	pfake.setQueryRows("SELECT COUNT(*)", []string{"count"}, []driver.Value{int64(1)})
+	if _, err := Count[testUser](context.Background(), "", WithHandle(h), Primary()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := Insert(context.Background(), &testUser{Name: "b"}, WithHandle(h)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := h.WithTx(context.Background(), func(tx *Tx) error {
+		var users []testUser
+		return tx.Query(context.Background(), &users, "SELECT * FROM users")
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The count, the insert, and the query and commit of the transaction.
+	if len(pfake.queries) != 4 || len(rfake1.queries)+len(rfake2.queries) != 4 {
+		t.Errorf("expected 4 statements on the primary, got %v", pfake.queries)
+	}
+	if h.DB() != primary {
+		t.Error("expected DB to return the primary")
+	}
+}
+
+// workerRow waits in AfterScan until both rows of a result are being scanned, which
+// only happens when they are decoded in parallel.
+type workerRow struct {
+	Id int `sql:"id"`
+}
+
+var workerBarrier sync.WaitGroup
+
+func (workerRow) AfterScan(ctx context.Context) error {
+	workerBarrier.Done()
+	done := make(chan struct{})
+	go func() {
+		workerBarrier.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(time.Second):
+		return errors.New("rows were not scanned in parallel")
+	}
+}
+
+func TestHandleQueryWorkers(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+	h := New(sqldb)
+
+	workerBarrier.Add(2)
+	var rows []workerRow
+	if err := h.Query(&rows, "SELECT * FROM worker_rows", Workers(2)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(rows, []workerRow{{1}, {2}}) {
+		t.Errorf("unexpected result %v", rows)
+	}
+}
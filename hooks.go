@@ -0,0 +1,53 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"reflect"
+)
+
+// AfterScanner is implemented by types which need to update or validate their fields
+// once a row has been scanned into them, for example to compute derived fields. Scan
+// and the functions scanning query results call AfterScan after each successful scan.
+// Its error is returned as the error of the scan.
+//
+// The functions which are not given a context, such as Scan, call AfterScan with
+// context.Background().
+type AfterScanner interface {
+	AfterScan(ctx context.Context) error
+}
+
+// BeforeSaver is implemented by types which need to normalize or validate their fields
+// before they are written. Insert, Update, Upsert and BatchInsert call BeforeSave on
+// each value before generating the statement, and return its error without executing
+// the statement. Changes made to a value passed as a struct rather than a pointer only
+// affect the statement.
+type BeforeSaver interface {
+	BeforeSave(ctx context.Context) error
+}
+
+// afterScan calls the AfterScan method of dest, if it has one.
+func afterScan(ctx context.Context, dest interface{}) error {
+	if s, ok := dest.(AfterScanner); ok {
+		return s.AfterScan(ctx)
+	}
+	return nil
+}
+
+// savedStruct returns the struct value held by v as structOf does, after calling its
+// BeforeSave method if it has one.
+func savedStruct(ctx context.Context, v interface{}) (reflect.Value, error) {
+	rv, err := structOf(v)
+	if err != nil {
+		return rv, err
+	}
+	if s, ok := rv.Addr().Interface().(BeforeSaver); ok {
+		if err := s.BeforeSave(ctx); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return rv, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type hookedUser struct {
+	Id    int    `sql:"id,pk"`
+	Name  string `sql:"name"`
+	Upper string `sql:"-"`
+}
+
+func (u *hookedUser) AfterScan(ctx context.Context) error {
+	if u.Name == "" {
+		return errors.New("missing name")
+	}
+	u.Upper = strings.ToUpper(u.Name)
+	return nil
+}
+
+func (u *hookedUser) BeforeSave(ctx context.Context) error {
+	if u.Name == "" {
+		return errors.New("missing name")
+	}
+	u.Name = strings.TrimSpace(u.Name)
+	return nil
+}
+
+func TestAfterScan(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	users, err := Query[hookedUser]("SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 2 || users[0].Upper != "A" || users[1].Upper != "B" {
+		t.Errorf("unexpected result %v", users)
+	}
+
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), ""})
+	if _, err := QueryRow[hookedUser]("SELECT * FROM users"); err == nil || err.Error() != "missing name" {
+		t.Errorf("expected error from AfterScan, got %v", err)
+	}
+}
+
+func TestBeforeSave(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	if _, err := Insert(ctx, hookedUser{Id: 1, Name: " a "}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []interface{}{int64(1), "a"}; !reflect.DeepEqual(fake.args[0], expected) {
+		t.Errorf("expected args %v got %v", expected, fake.args[0])
+	}
+
+	if _, err := Update(ctx, &hookedUser{Id: 1}); err == nil {
+		t.Error("expected error from BeforeSave")
+	}
+	if len(fake.queries) != 1 {
+		t.Errorf("expected no statement to be executed, got %q", fake.queries)
+	}
+}
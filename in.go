@@ -0,0 +1,96 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// In expands the slice arguments in args into one argument per element, and the
+// matching ? placeholders in query into as many placeholders. For example:
+//
+//	query, args, err := sqlstruct.In("SELECT * FROM users WHERE id IN (?) AND active = ?", []int{1, 2, 3}, true)
+//
+// returns the query SELECT * FROM users WHERE id IN (?, ?, ?) AND active = ? and the
+// arguments 1, 2, 3, true. Byte slices and values implementing driver.Valuer are not
+// expanded. Placeholders inside quoted strings and identifiers are ignored. An error
+// is returned if a slice is empty or if the number of placeholders does not match
+// the number of arguments.
+func In(query string, args ...interface{}) (string, []interface{}, error) {
+	expand := false
+	for _, arg := range args {
+		if isInSlice(arg) {
+			expand = true
+			break
+		}
+	}
+	if !expand {
+		return query, args, nil
+	}
+
+	var b strings.Builder
+	var expanded []interface{}
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if n >= len(args) {
+				return "", nil, errors.New("sqlstruct: query has more placeholders than arguments")
+			}
+			arg := args[n]
+			n++
+			if !isInSlice(arg) {
+				expanded = append(expanded, arg)
+				break
+			}
+			v := reflect.ValueOf(arg)
+			if v.Len() == 0 {
+				return "", nil, fmt.Errorf("sqlstruct: empty slice passed as argument %d", n)
+			}
+			for j := 0; j < v.Len(); j++ {
+				expanded = append(expanded, v.Index(j).Interface())
+			}
+			b.WriteString(placeholders(v.Len()))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if n != len(args) {
+		return "", nil, errors.New("sqlstruct: query has fewer placeholders than arguments")
+	}
+	return b.String(), expanded, nil
+}
+
+// isInSlice reports whether arg is a slice expanded by In.
+func isInSlice(arg interface{}) bool {
+	if arg == nil {
+		return false
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return false
+	}
+	t := reflect.TypeOf(arg)
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+// ExpandIn makes queries and statements expand their slice arguments as described
+// by In before they are executed.
+func ExpandIn() Option {
+	return func(o *options) {
+		o.expandIn = true
+	}
+}
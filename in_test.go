@@ -0,0 +1,39 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestIn(t *testing.T) {
+	query, args, err := In("SELECT * FROM t WHERE id IN (?) AND name = '?' AND data = ? AND ok = ?", []int{1, 2, 3}, []byte("x"), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "SELECT * FROM t WHERE id IN (?, ?, ?) AND name = '?' AND data = ? AND ok = ?"; query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+	if expected := []interface{}{1, 2, 3, []byte("x"), true}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v got %v", expected, args)
+	}
+
+	if _, _, err := In("SELECT * FROM t WHERE id IN (?)", []int{}); err == nil {
+		t.Error("expected error for empty slice")
+	}
+	if _, _, err := In("SELECT * FROM t WHERE id IN (?)", []int{1}, 2); err == nil {
+		t.Error("expected error for extra argument")
+	}
+
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	if _, err := Query[testUser]("SELECT * FROM users WHERE id IN (?)", []int{1, 2}, ExpandIn()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := "SELECT id, name FROM users WHERE id IN (?, ?)"; fake.queries[0] != expected {
+		t.Errorf("expected %q got %q", expected, fake.queries[0])
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// MaxInterned is the maximum number of distinct strings kept in the intern table.
+// Once it is full, values that are not already interned are allocated as usual.
+var MaxInterned = 1 << 16
+
+// internTable holds the strings shared by fields tagged with the "intern" option.
+var internTable = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// intern returns a string equal to b, sharing storage with previous results
+// for the same contents.
+func intern(b []byte) string {
+	internTable.RLock()
+	s, ok := internTable.m[string(b)]
+	internTable.RUnlock()
+	if ok {
+		return s
+	}
+
+	return internStore(string(b))
+}
+
+// internString is like intern for values that are already strings.
+func internString(s string) string {
+	internTable.RLock()
+	interned, ok := internTable.m[s]
+	internTable.RUnlock()
+	if ok {
+		return interned
+	}
+
+	return internStore(s)
+}
+
+// internStore adds s to the intern table if there is room and returns the interned value.
+func internStore(s string) string {
+	internTable.Lock()
+	defer internTable.Unlock()
+	if interned, ok := internTable.m[s]; ok {
+		return interned
+	}
+	if len(internTable.m) < MaxInterned {
+		internTable.m[s] = s
+	}
+	return s
+}
+
+// internScanner is a sql.Scanner that stores interned strings in a string field.
+// It is used for fields tagged with the "intern" option, for example:
+//
+//	Status string `sql:"status,intern"`
+//
+// Interning is useful for low-cardinality columns in large result sets, where it
+// avoids allocating a new string for every row.
+type internScanner struct {
+	v reflect.Value
+}
+
+func (s *internScanner) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		s.v.SetString(intern(src))
+	case string:
+		s.v.SetString(internString(src))
+	case nil:
+		return fmt.Errorf("converting NULL to %s is unsupported", s.v.Type())
+	default:
+		s.v.SetString(internString(fmt.Sprint(src)))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+)
+
+var (
+	ipType       = reflect.TypeOf(net.IP(nil))
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	ipNetPtrType = reflect.TypeOf((*net.IPNet)(nil))
+	addrType     = reflect.TypeOf(netip.Addr{})
+	prefixType   = reflect.TypeOf(netip.Prefix{})
+)
+
+// isIPType reports whether typ is one of the IP address types converted by ipValue.
+func isIPType(typ reflect.Type) bool {
+	switch typ {
+	case ipType, ipNetType, ipNetPtrType, addrType, prefixType:
+		return true
+	}
+	return false
+}
+
+// ipValue converts between IP address fields and database columns. The supported
+// field types are net.IP and netip.Addr for addresses and net.IPNet, *net.IPNet and
+// netip.Prefix for networks.
+//
+// Columns may hold the textual form of the address or network, as used by the
+// Postgres inet and cidr types, or an address as 4 or 16 raw bytes, as commonly
+// stored in MySQL binary columns. Values are always written in textual form.
+// NULL is represented by the zero value of the field.
+type ipValue struct {
+	v reflect.Value
+}
+
+func (ip *ipValue) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		ip.v.Set(reflect.Zero(ip.v.Type()))
+		return nil
+	case string:
+		b = []byte(src)
+	case []byte:
+		b = src
+	default:
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %s", src, ip.v.Type())
+	}
+
+	switch ip.v.Type() {
+	case ipType, addrType:
+		addr, err := parseAddr(b)
+		if err != nil {
+			return err
+		}
+		if ip.v.Type() == ipType {
+			ip.v.Set(reflect.ValueOf(net.IP(addr.AsSlice())))
+		} else {
+			ip.v.Set(reflect.ValueOf(addr))
+		}
+	default:
+		prefix, err := netip.ParsePrefix(string(b))
+		if err != nil {
+			addr, aerr := parseAddr(b)
+			if aerr != nil {
+				return err
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		switch ip.v.Type() {
+		case prefixType:
+			ip.v.Set(reflect.ValueOf(prefix))
+		case ipNetType:
+			ip.v.Set(reflect.ValueOf(*prefixToIPNet(prefix)))
+		case ipNetPtrType:
+			ip.v.Set(reflect.ValueOf(prefixToIPNet(prefix)))
+		}
+	}
+	return nil
+}
+
+// Value implements driver.Valuer, returning the textual form of the address or network.
+func (ip *ipValue) Value() (driver.Value, error) {
+	switch v := ip.v.Interface().(type) {
+	case net.IP:
+		if v == nil {
+			return nil, nil
+		}
+		return v.String(), nil
+	case netip.Addr:
+		if !v.IsValid() {
+			return nil, nil
+		}
+		return v.String(), nil
+	case netip.Prefix:
+		if !v.IsValid() {
+			return nil, nil
+		}
+		return v.String(), nil
+	case net.IPNet:
+		if v.IP == nil {
+			return nil, nil
+		}
+		return v.String(), nil
+	case *net.IPNet:
+		if v == nil {
+			return nil, nil
+		}
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("sqlstruct: unsupported IP type %s", ip.v.Type())
+}
+
+// parseAddr parses an IP address in textual or 4 or 16 byte binary form.
+func parseAddr(b []byte) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(string(b))
+	if err == nil {
+		return addr, nil
+	}
+	if len(b) == net.IPv4len || len(b) == net.IPv6len {
+		addr, _ := netip.AddrFromSlice(b)
+		return addr.Unmap(), nil
+	}
+	return netip.Addr{}, err
+}
+
+func prefixToIPNet(p netip.Prefix) *net.IPNet {
+	return &net.IPNet{
+		IP:   net.IP(p.Masked().Addr().AsSlice()),
+		Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen()),
+	}
+}
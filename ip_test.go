@@ -0,0 +1,55 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestScanIP(t *testing.T) {
+	type host struct {
+		IP      net.IP       `sql:"ip"`
+		Addr    netip.Addr   `sql:"addr"`
+		Network *net.IPNet   `sql:"network"`
+		Prefix  netip.Prefix `sql:"prefix"`
+		Missing net.IP       `sql:"missing"`
+	}
+
+	rows := testRows{}
+	rows.addValue("ip", []byte("192.168.0.1"))
+	rows.addValue("addr", []byte{10, 0, 0, 1})
+	rows.addValue("network", "10.1.2.0/24")
+	rows.addValue("prefix", "2001:db8::1")
+	rows.addValue("missing", nil)
+
+	var h host
+	if err := Scan(&h, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !h.IP.Equal(net.IPv4(192, 168, 0, 1)) {
+		t.Errorf("expected 192.168.0.1 got %s", h.IP)
+	}
+	if h.Addr != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("expected 10.0.0.1 got %s", h.Addr)
+	}
+	if h.Network == nil || h.Network.String() != "10.1.2.0/24" {
+		t.Errorf("expected 10.1.2.0/24 got %s", h.Network)
+	}
+	if h.Prefix != netip.MustParsePrefix("2001:db8::1/128") {
+		t.Errorf("expected 2001:db8::1/128 got %s", h.Prefix)
+	}
+	if h.Missing != nil {
+		t.Errorf("expected nil got %s", h.Missing)
+	}
+
+	if v, _ := (&ipValue{reflect.ValueOf(&h.Network).Elem()}).Value(); v != "10.1.2.0/24" {
+		t.Errorf("expected 10.1.2.0/24 got %v", v)
+	}
+	if v, _ := (&ipValue{reflect.ValueOf(&h.Missing).Elem()}).Value(); v != nil {
+		t.Errorf("expected nil got %v", v)
+	}
+}
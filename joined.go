@@ -0,0 +1,263 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// AliasedDest is a destination of ScanJoined along with the alias prefixing its
+// columns in the result.
+type AliasedDest struct {
+	Alias string
+	Dest  interface{}
+}
+
+// ScanJoined scans the current row of a joined result into several structs at once.
+// Each column is stored in the destination whose alias and AliasSeparator prefix it,
+// as generated by ColumnsAliased. For example:
+//
+//	query := "SELECT " + sqlstruct.ColumnsAliased(User{}, "u") + ", " + sqlstruct.ColumnsAliased(Address{}, "a") +
+//		" FROM users AS u JOIN addresses AS a ON a.id = u.address_id"
+//	...
+//	var user User
+//	var address Address
+//	err := sqlstruct.ScanJoined(rows, sqlstruct.AliasedDest{"u", &user}, sqlstruct.AliasedDest{"a", &address})
+//
+// When several aliases prefix a column, the longest one is used. ScanJoined returns an
+// error without scanning the row if some of the columns are not mapped to a field of
+// any destination.
+func ScanJoined(rows Rows, dests ...AliasedDest) error {
+	return defaultHandle.scanJoined(context.Background(), rows, dests, false)
+}
+
+func (h *Handle) scanJoined(ctx context.Context, rows Rows, dests []AliasedDest, nullToZero bool) error {
+	nullToZero = nullToZero || h.NullToZero
+	type joinedDest struct {
+		prefix string
+		v      reflect.Value
+		fields fieldInfo
+	}
+	joined := make([]joinedDest, len(dests))
+	for i, d := range dests {
+		destv, err := structDest(d.Dest)
+		if err != nil {
+			return err
+		}
+		fields, err := h.getFieldInfo(destv.Type().Elem())
+		if err != nil {
+			return err
+		}
+		joined[i] = joinedDest{aliasPrefix(d.Alias), destv.Elem(), fields}
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vp := getValues(len(cols))
+	defer putValues(vp)
+	var unclaimed []string
+	for i, col := range cols {
+		var match *joinedDest
+		for j := range joined {
+			d := &joined[j]
+			if strings.HasPrefix(col, d.prefix) && (match == nil || len(d.prefix) > len(match.prefix)) {
+				match = d
+			}
+		}
+		if match != nil {
+			name := strings.ToLower(strings.TrimPrefix(col, match.prefix))
+			if f, ok := match.fields[name]; ok && !f.writeOnly {
+				(*vp)[i] = f.dest(match.v, nullToZero)
+				continue
+			}
+		}
+		unclaimed = append(unclaimed, col)
+	}
+	if len(unclaimed) > 0 {
+		return fmt.Errorf("sqlstruct: columns not mapped to any destination: %s", strings.Join(unclaimed, ", "))
+	}
+	if err := rows.Scan(*vp...); err != nil {
+		return err
+	}
+	for _, d := range dests {
+		if err := afterScan(ctx, d.Dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aliasToken matches the tokens replaced by ExpandAliased, such as {u:User}.
+var aliasToken = regexp.MustCompile(`\{(\w+):(\w+)\}`)
+
+// ExpandAliased replaces each token of the form {alias:Type} in query with the columns
+// of the struct type named Type among the types of values, aliased with alias as by
+// ColumnsAliased. Each value must be a struct or a pointer to a struct. For example:
+//
+//	query, err := sqlstruct.ExpandAliased("SELECT {u:User}, {a:Address} FROM users AS u "+
+//		"JOIN addresses AS a ON a.id = u.address_id", User{}, Address{})
+//
+// The rows are then scanned with ScanJoined and the same aliases. A type given twice,
+// as for a self-join, is matched to the aliases in the order of their first tokens. An
+// error is returned if a token names none of the types, or if a type has no token.
+func ExpandAliased(query string, values ...interface{}) (string, error) {
+	types := make([]reflect.Type, len(values))
+	for i, v := range values {
+		typ := reflect.TypeOf(v)
+		if typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			return "", fmt.Errorf("sqlstruct: cannot expand %T: not a struct or pointer to struct", v)
+		}
+		types[i] = typ
+	}
+	query, _, err := defaultHandle.expandAliased(query, types)
+	return query, err
+}
+
+// expandAliased replaces the tokens of query as described by ExpandAliased and returns
+// the alias of each of types.
+func (h *Handle) expandAliased(query string, types []reflect.Type) (string, []string, error) {
+	aliases := make([]string, len(types))
+	index := make(map[string]int)
+	var err error
+	query = aliasToken.ReplaceAllStringFunc(query, func(token string) string {
+		m := aliasToken.FindStringSubmatch(token)
+		alias, name := m[1], m[2]
+		i, ok := index[alias]
+		if !ok {
+			i = -1
+			for j, typ := range types {
+				if typ.Name() == name && aliases[j] == "" {
+					i = j
+					break
+				}
+			}
+		}
+		if i == -1 || types[i].Name() != name {
+			if err == nil {
+				err = fmt.Errorf("sqlstruct: no type %s for alias %s in %s", name, alias, token)
+			}
+			return token
+		}
+		index[alias], aliases[i] = i, alias
+		fields, ferr := h.getFieldInfo(types[i])
+		if ferr != nil {
+			if err == nil {
+				err = ferr
+			}
+			return token
+		}
+		cols := fields.columns()
+		for k, col := range cols {
+			cols[k] = alias + "." + h.quote(col) + " AS " + aliasPrefix(alias) + col
+		}
+		return strings.Join(cols, ", ")
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	for i, alias := range aliases {
+		if alias == "" {
+			return "", nil, fmt.Errorf("sqlstruct: query has no {alias:%s} token for %s", types[i].Name(), types[i])
+		}
+	}
+	return query, aliases, nil
+}
+
+// Query2 runs a query joining the tables of A and B and returns the rows scanned into
+// two slices of the same length, holding the A and the B of each row. The tokens of
+// query are expanded as by ExpandAliased and the rows scanned as by ScanJoined, so the
+// join is written once without listing any columns:
+//
+//	users, addresses, err := sqlstruct.Query2[User, Address](ctx,
+//		"SELECT {u:User}, {a:Address} FROM users AS u JOIN addresses AS a ON a.id = u.address_id "+
+//			"WHERE u.name = ?", "gedi")
+//
+// Options may be mixed into args as with Query.
+func Query2[A, B any](ctx context.Context, query string, args ...interface{}) ([]A, []B, error) {
+	var as []A
+	var bs []B
+	types := []reflect.Type{reflect.TypeOf((*A)(nil)).Elem(), reflect.TypeOf((*B)(nil)).Elem()}
+	err := queryJoined(ctx, query, args, types, func(scan func(...interface{}) error) error {
+		var a A
+		var b B
+		if err := scan(&a, &b); err != nil {
+			return err
+		}
+		as, bs = append(as, a), append(bs, b)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return as, bs, nil
+}
+
+// Query3 works like Query2 for a query joining the tables of A, B and C.
+func Query3[A, B, C any](ctx context.Context, query string, args ...interface{}) ([]A, []B, []C, error) {
+	var as []A
+	var bs []B
+	var cs []C
+	types := []reflect.Type{reflect.TypeOf((*A)(nil)).Elem(), reflect.TypeOf((*B)(nil)).Elem(), reflect.TypeOf((*C)(nil)).Elem()}
+	err := queryJoined(ctx, query, args, types, func(scan func(...interface{}) error) error {
+		var a A
+		var b B
+		var c C
+		if err := scan(&a, &b, &c); err != nil {
+			return err
+		}
+		as, bs, cs = append(as, a), append(bs, b), append(cs, c)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return as, bs, cs, nil
+}
+
+// queryJoined expands the tokens of query for types, runs it and calls row for each
+// row of the result. row is given a function scanning the row into destinations of
+// types, in order.
+func queryJoined(ctx context.Context, query string, args []interface{}, types []reflect.Type, row func(scan func(...interface{}) error) error) error {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+	h := o.handleOf()
+	query, aliases, err := h.expandAliased(query, types)
+	if err != nil {
+		return err
+	}
+	return o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		scan := func(dests ...interface{}) error {
+			aliased := make([]AliasedDest, len(dests))
+			for i, d := range dests {
+				aliased[i] = AliasedDest{aliases[i], d}
+			}
+			return h.scanJoined(ctx, rows, aliased, o.nullToZero)
+		}
+		var n int64
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				return n, err
+			}
+			if o.maxRows > 0 && n >= int64(o.maxRows) {
+				return n, ErrMaxRows
+			}
+			if err := row(scan); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, rows.Err()
+	})
+}
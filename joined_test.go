@@ -0,0 +1,85 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestScanJoined(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("t1_field_a", "a")
+	rows.addValue("t1_field_c", "c")
+	rows.addValue("t2_field_a", "a2")
+	rows.addValue("t2_field_sec", "sec")
+
+	var t1 testType
+	var t2 testType2
+	if err := ScanJoined(rows, AliasedDest{"t1", &t1}, AliasedDest{"t2", &t2}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := (testType{FieldA: "a", FieldC: "c"}); t1 != expected {
+		t.Errorf("expected %v got %v", expected, t1)
+	}
+	if expected := (testType2{"a2", "sec"}); t2 != expected {
+		t.Errorf("expected %v got %v", expected, t2)
+	}
+
+	rows.addValue("t3_other", "x")
+	rows.addValue("t1_missing", "y")
+	err := ScanJoined(rows, AliasedDest{"t1", &t1}, AliasedDest{"t2", &t2})
+	if err == nil || !strings.Contains(err.Error(), "t3_other, t1_missing") {
+		t.Errorf("expected error reporting unclaimed columns, got %v", err)
+	}
+
+	if err := ScanJoined(rows, AliasedDest{"t1", t1}); err == nil {
+		t.Error("expected error for non-pointer destination")
+	}
+}
+
+func TestExpandAliased(t *testing.T) {
+	query, err := ExpandAliased("SELECT {u:testUser}, {m:testUser} FROM users AS u JOIN users AS m ON m.id = u.manager", testUser{}, &testUser{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "SELECT u.id AS u_id, u.name AS u_name, m.id AS m_id, m.name AS m_name FROM users AS u JOIN users AS m ON m.id = u.manager"
+	if query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+
+	for _, query := range []string{"SELECT {u:testUser}, {t:testType}", "SELECT {u:testUser}", "SELECT {u:testUser}, {u:testType2}"} {
+		if _, err := ExpandAliased(query, testUser{}, testType2{}); err == nil {
+			t.Errorf("expected error for %q", query)
+		}
+	}
+	if _, err := ExpandAliased("SELECT {u:testUser}", 1); err == nil {
+		t.Error("expected error for non-struct value")
+	}
+}
+
+func TestQuery2(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"u_id", "u_name", "t_field_a", "t_field_sec"},
+		[]driver.Value{int64(1), "a", "x", "y"},
+		[]driver.Value{int64(2), "b", "z", "w"})
+
+	users, others, err := Query2[testUser, testType2](context.Background(), "SELECT {u:testUser}, {t:testType2} FROM users AS u JOIN other AS t ON t.user_id = u.id WHERE u.id > ?", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 2 || users[1] != (testUser{2, "b"}) || len(others) != 2 || others[1] != (testType2{"z", "w"}) {
+		t.Errorf("unexpected result %v %v", users, others)
+	}
+	expected := "SELECT u.id AS u_id, u.name AS u_name, t.field_a AS t_field_a, t.field_sec AS t_field_sec FROM users AS u JOIN other AS t ON t.user_id = u.id WHERE u.id > ?"
+	if fake.queries[0] != expected {
+		t.Errorf("expected %q got %q", expected, fake.queries[0])
+	}
+
+	if _, _, _, err := Query3[testUser, testType2, testType](context.Background(), "SELECT {u:testUser}, {t:testType2}"); err == nil {
+		t.Error("expected error for type without token")
+	}
+}
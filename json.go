@@ -0,0 +1,107 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RowsToJSON writes the remaining rows of rows to w as a JSON array of objects, one
+// per row, keyed by the column names in the order of the result, then closes rows.
+// Rows are written as they are read, so the result set is never held in memory:
+//
+//	rows, err := db.QueryContext(ctx, "SELECT id, name FROM users")
+//	...
+//	w.Header().Set("Content-Type", "application/json")
+//	err = sqlstruct.RowsToJSON(w, rows)
+//
+// Values are encoded as by encoding/json, except that byte slices are written as
+// strings since most drivers return text columns as bytes. If an error occurs after
+// the first row, w has received an incomplete array.
+func RowsToJSON(w io.Writer, rows RowsIter) error {
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	keys := make([][]byte, len(cols))
+	for i, col := range cols {
+		if keys[i], err = json.Marshal(col); err != nil {
+			return err
+		}
+	}
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+	for n := 0; rows.Next(); n++ {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("sqlstruct: cannot encode column %s: %w", cols[i], err)
+			}
+			bw.Write(keys[i])
+			bw.WriteByte(':')
+			bw.Write(b)
+		}
+		bw.WriteByte('}')
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
+
+// EncodeJSON works like RowsToJSON but scans each row into a T and encodes it with
+// encoding/json, so that the objects are shaped by the json tags of T rather than by
+// the columns of the result.
+func EncodeJSON[T any](w io.Writer, rows RowsIter, opts ...Option) error {
+	defer rows.Close()
+	o := applyOptions(opts)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+	for n := 0; rows.Next(); n++ {
+		var t T
+		if err := o.scan(context.Background(), &t, rows); err != nil {
+			return err
+		}
+		b, err := json.Marshal(&t)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		bw.Write(b)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
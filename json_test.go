@@ -0,0 +1,64 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestRowsToJSON(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"name", "id", "data"},
+		[]driver.Value{"a", int64(1), []byte("x")},
+		[]driver.Value{"b", int64(2), nil},
+	)
+
+	rows, err := sqldb.Query("SELECT name, id, data FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := RowsToJSON(&buf, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := `[{"name":"a","id":1,"data":"x"},{"name":"b","id":2,"data":null}]`; buf.String() != expected {
+		t.Errorf("expected %s got %s", expected, buf.String())
+	}
+
+	fake.setRows([]string{"id"})
+	rows, err = sqldb.Query("SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	buf.Reset()
+	if err := RowsToJSON(&buf, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.String() != "[]" {
+		t.Errorf("expected [] got %s", buf.String())
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	type user struct {
+		Id   int    `sql:"id" json:"user_id"`
+		Name string `sql:"name" json:"-"`
+	}
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeJSON[user](&buf, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := `[{"user_id":1},{"user_id":2}]`; buf.String() != expected {
+		t.Errorf("expected %s got %s", expected, buf.String())
+	}
+}
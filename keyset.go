@@ -0,0 +1,222 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Cursor marks the position after a page of results returned by PaginateKeyset. It
+// is opaque: callers pass the cursor returned with one page to fetch the next. The
+// empty Cursor starts at the first page.
+type Cursor string
+
+// ErrInvalidCursor is returned by PaginateKeyset when it is given a cursor which it
+// did not create for the same type.
+var ErrInvalidCursor = errors.New("sqlstruct: invalid cursor")
+
+// PaginateKeyset returns a page of at most limit rows of baseQuery scanned into T,
+// starting after cursor, and the cursor of the following page. The next cursor is
+// empty when there are no more rows. For example:
+//
+//	type Post struct {
+//		Id      int       `sql:"id,pk"`
+//		Created time.Time `sql:"created,sort=desc"`
+//		Title   string    `sql:"title"`
+//	}
+//
+//	posts, next, err := sqlstruct.PaginateKeyset[Post](ctx, "SELECT * FROM posts WHERE author = ?", cursor, 20, author)
+//
+// Rows are ordered by the field tagged with the "sort" option, if there is one, and
+// then by the primary key so that the order is total. The rows are sorted in ascending
+// order unless the option is given as sort=desc, which may also be used on the
+// primary key itself. Rather than skipping an offset, each page selects the rows whose
+// keys come after those of the last row of the previous page, so that deep pages are
+// as fast as the first given an index on the key columns.
+//
+// baseQuery must select the columns of T without an ORDER BY or LIMIT clause; the
+// first occurrence of QueryReplace is replaced as with Query. The query is wrapped in
+// a subquery which adds the keyset condition, ordering and limit.
+func PaginateKeyset[T any](ctx context.Context, baseQuery string, cursor Cursor, limit int, args ...interface{}) ([]T, Cursor, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("sqlstruct: PaginateKeyset limit must be positive; got %d", limit)
+	}
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+	h := o.handleOf()
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	keys, desc, err := h.keysetFields(typ)
+	if err != nil {
+		return nil, "", err
+	}
+	after, err := cursor.values(keys)
+	if err != nil {
+		return nil, "", err
+	}
+	query, err := o.expand(baseQuery, typ)
+	if err != nil {
+		return nil, "", err
+	}
+	query, after = h.keysetQuery(o.dialectOf(), query, keys, after, desc, limit+1)
+	args = append(args, after...)
+
+	var result []T
+	err = o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		var err error
+		result, err = scanRows[T](ctx, rows, o)
+		return int64(len(result)), err
+	})
+	if err != nil || len(result) <= limit {
+		return result, "", err
+	}
+	result = result[:limit]
+	next, err := newCursor(keys, reflect.ValueOf(&result[limit-1]).Elem())
+	if err != nil {
+		return nil, "", err
+	}
+	return result, next, nil
+}
+
+// keysetFields returns the fields ordering the pages of typ: the field tagged with
+// the "sort" option, if any, followed by the primary key. desc reports whether they
+// are sorted in descending order.
+func (h *Handle) keysetFields(typ reflect.Type) ([]*field, bool, error) {
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return nil, false, err
+	}
+	var sort *field
+	var order string
+	for _, name := range fields.names() {
+		f := fields[name]
+		value, ok := f.opts.Value("sort")
+		if !ok {
+			continue
+		}
+		if sort != nil {
+			return nil, false, fmt.Errorf("sqlstruct: %s has more than one field with the sort option", typ)
+		}
+		if value != "" && value != "asc" && value != "desc" {
+			return nil, false, fmt.Errorf("sqlstruct: sort option of column %s must be asc or desc; got %q", name, value)
+		}
+		sort, order = f, value
+	}
+
+	pks := fields.primaryKeys()
+	if len(pks) == 0 {
+		return nil, false, fmt.Errorf("sqlstruct: %s has no primary key to paginate by", typ)
+	}
+	var keys []*field
+	if sort != nil && !sort.pk {
+		keys = append(keys, sort)
+	}
+	return append(keys, pks...), order == "desc", nil
+}
+
+// keysetQuery wraps query to select, in the order of keys, the limit rows whose keys
+// follow the values after of the last row of the previous page, or the first rows if
+// after is nil. It returns the query and the arguments of its added placeholders.
+func (h *Handle) keysetQuery(d Dialect, query string, keys []*field, after []interface{}, desc bool, limit int) (string, []interface{}) {
+	cols := make([]string, len(keys))
+	for i, f := range keys {
+		cols[i] = h.quote(f.name)
+	}
+	op, dir := " > ", ""
+	if desc {
+		op, dir = " < ", " DESC"
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT * FROM (")
+	b.WriteString(strings.TrimRight(query, "; \t\r\n"))
+	b.WriteString(") page")
+	var args []interface{}
+	if after != nil {
+		var cond string
+		cond, args = keysetCondition(d, cols, op, after)
+		b.WriteString(" WHERE " + cond)
+	}
+	b.WriteString(" ORDER BY ")
+	b.WriteString(strings.Join(cols, dir+", ") + dir)
+	b.WriteString(d.limit(limit, 0))
+	return b.String(), args
+}
+
+// keysetCondition returns the condition selecting the rows whose columns cols compare
+// to values with op, and its arguments. Row values are compared directly where the
+// dialect supports it, as in (created, id) > (?, ?), and otherwise column by column,
+// as in (created > ? OR created = ? AND id > ?).
+func keysetCondition(d Dialect, cols []string, op string, values []interface{}) (string, []interface{}) {
+	if len(cols) == 1 {
+		return cols[0] + op + "?", values
+	}
+	if d != SQLServer && d != Oracle {
+		return "(" + strings.Join(cols, ", ") + ")" + op + "(" + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + ")", values
+	}
+	terms := make([]string, len(cols))
+	var args []interface{}
+	for i, col := range cols {
+		var term strings.Builder
+		for _, prev := range cols[:i] {
+			term.WriteString(prev + " = ? AND ")
+		}
+		term.WriteString(col + op + "?")
+		terms[i] = term.String()
+		args = append(args, values[:i+1]...)
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
+
+// newCursor returns the cursor following the row v, holding the values of its keys.
+func newCursor(keys []*field, v reflect.Value) (Cursor, error) {
+	values := make([]interface{}, len(keys))
+	for i, f := range keys {
+		if fv, err := v.FieldByIndexErr(f.index); err == nil {
+			values[i] = fv.Interface()
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("sqlstruct: cannot encode cursor: %w", err)
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(b)), nil
+}
+
+// values decodes the key values held by c into arguments for the keys of the page
+// following it. It returns nil for the empty Cursor.
+func (c Cursor) values(keys []*field) ([]interface{}, error) {
+	if c == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil || len(raw) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]interface{}, len(keys))
+	for i, f := range keys {
+		v := reflect.New(f.typ).Elem()
+		if err := json.Unmarshal(raw[i], v.Addr().Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v.Interface()
+		if d, ok := f.wrap(v).(driver.Valuer); ok {
+			values[i] = d
+		}
+	}
+	return values, nil
+}
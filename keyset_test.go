@@ -0,0 +1,72 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type testPost struct {
+	Id      int    `sql:"id,pk"`
+	Created int64  `sql:"created,sort=desc"`
+	Title   string `sql:"title"`
+}
+
+func TestPaginateKeyset(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	fake.setRows([]string{"id", "created", "title"},
+		[]driver.Value{int64(3), int64(30), "c"},
+		[]driver.Value{int64(2), int64(20), "b"},
+		[]driver.Value{int64(1), int64(10), "a"},
+	)
+
+	posts, next, err := PaginateKeyset[testPost](ctx, "SELECT * FROM posts WHERE title <> ?", "", 2, "x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(posts) != 2 || posts[1].Id != 2 || next == "" {
+		t.Fatalf("unexpected page %v with cursor %q", posts, next)
+	}
+	if _, _, err := PaginateKeyset[testPost](ctx, "SELECT * FROM posts WHERE title <> ?", next, 2, "x"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"SELECT * FROM (SELECT created, id, title FROM posts WHERE title <> ?) page ORDER BY created DESC, id DESC LIMIT 3",
+		"SELECT * FROM (SELECT created, id, title FROM posts WHERE title <> ?) page WHERE (created, id) < (?, ?) ORDER BY created DESC, id DESC LIMIT 3",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if args := []interface{}{"x", int64(20), int64(2)}; !reflect.DeepEqual(fake.args[1], args) {
+		t.Errorf("expected args %v got %v", args, fake.args[1])
+	}
+
+	fake.setRows([]string{"id", "created", "title"}, []driver.Value{int64(1), int64(10), "a"})
+	if posts, next, err := PaginateKeyset[testPost](ctx, "SELECT * FROM posts", next, 2); err != nil || len(posts) != 1 || next != "" {
+		t.Errorf("expected last page without cursor got %v %q %v", posts, next, err)
+	}
+	if _, _, err := PaginateKeyset[testPost](ctx, "SELECT * FROM posts", "bogus", 2); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor got %v", err)
+	}
+	if _, _, err := PaginateKeyset[testType](ctx, "SELECT * FROM t", "", 2); err == nil {
+		t.Error("expected error for type without primary key")
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	cond, args := keysetCondition(SQLServer, []string{"a", "b", "c"}, " > ", []interface{}{1, 2, 3})
+	if expected := "(a > ? OR a = ? AND b > ? OR a = ? AND b = ? AND c > ?)"; cond != expected {
+		t.Errorf("expected %q got %q", expected, cond)
+	}
+	if expected := []interface{}{1, 1, 2, 1, 2, 3}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v got %v", expected, args)
+	}
+}
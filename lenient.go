@@ -0,0 +1,94 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RowError is the error for a single row which could not be scanned.
+type RowError struct {
+	Row    int    // index of the row in the result, starting at 0
+	Column string // column which could not be converted, if known
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("sqlstruct: row %d, column %q: %v", e.Row, e.Column, e.Err)
+	}
+	return fmt.Sprintf("sqlstruct: row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// SliceFromRowsLenient works like SliceFromRows, except that rows which cannot be
+// scanned are skipped rather than ending the scan. The rows which were scanned
+// successfully are returned along with an error joining a *RowError for each
+// skipped row, which can be examined with errors.As. Errors reading the result
+// itself still end the scan.
+func SliceFromRowsLenient[T any](rows RowsIter, opts ...Option) ([]T, error) {
+	defer rows.Close()
+	o := applyOptions(opts)
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	result := make([]T, 0, o.capacity)
+	for i := 0; rows.Next(); i++ {
+		if o.maxRows > 0 && len(result) >= o.maxRows {
+			return result, errors.Join(append(errs, ErrMaxRows)...)
+		}
+		var t T
+		if err := o.scan(context.Background(), &t, rows); err != nil {
+			errs = append(errs, &RowError{Row: i, Column: failedColumn[T](rows, cols), Err: err})
+			continue
+		}
+		result = append(result, t)
+	}
+	if err := rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return result, errors.Join(errs...)
+}
+
+// failedColumn returns the name of the first column of the current row which cannot
+// be scanned into T, or an empty string if no single column fails.
+func failedColumn[T any](rows Rows, cols []string) string {
+	for i, col := range cols {
+		var t T
+		if defaultHandle.scanFields(&t, singleColumnRows{rows, i}, "", false, false) != nil {
+			return col
+		}
+	}
+	return ""
+}
+
+// singleColumnRows is a Rows which scans only one column of the current row of
+// rows, discarding the others.
+type singleColumnRows struct {
+	rows   Rows
+	column int
+}
+
+func (r singleColumnRows) Columns() ([]string, error) {
+	return r.rows.Columns()
+}
+
+func (r singleColumnRows) Scan(dest ...interface{}) error {
+	for i := range dest {
+		if i != r.column {
+			dest[i] = discard
+		}
+	}
+	return r.rows.Scan(dest...)
+}
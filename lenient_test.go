@@ -0,0 +1,36 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestSliceFromRowsLenient(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"},
+		[]driver.Value{int64(1), "a"},
+		[]driver.Value{"bad", "b"},
+		[]driver.Value{int64(3), "c"},
+	)
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	users, err := SliceFromRowsLenient[testUser](rows)
+	if len(users) != 2 || users[0] != (testUser{1, "a"}) || users[1] != (testUser{3, "c"}) {
+		t.Errorf("expected [{1 a} {3 c}] got %v", users)
+	}
+
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("expected a RowError got %v", err)
+	}
+	if rowErr.Row != 1 || rowErr.Column != "id" {
+		t.Errorf("expected error in row 1, column id got %v", rowErr)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Logger, if set, receives a record for every statement executed by this package,
+// with the statement's query, duration, number of rows read or affected and error,
+// if any. The source position of the record is the code which called into this
+// package, which handlers include when slog.HandlerOptions.AddSource is set. It is
+// used for a Handle whose own Logger is nil.
+var Logger *slog.Logger
+
+type loggerKey struct{}
+
+// LogLevel is the level of the records for statements which succeed.
+var LogLevel = slog.LevelDebug
+
+// ErrorLogLevel is the level of the records for statements which fail.
+// A query returning no rows where one was expected is not considered a failure.
+var ErrorLogLevel = slog.LevelError
+
+// QueryHook, if set, is called after every statement executed by this package with
+// the final SQL text sent to the database, after the expansion of QueryReplace and
+// the rebinding of placeholders, along with its arguments, duration and error. It is
+// called for the statements of a Handle whose own QueryHook is nil.
+var QueryHook func(ctx context.Context, query string, args []interface{}, d time.Duration, err error)
+
+type queryHookKey struct{}
+
+// callQueryHook calls the QueryHook of the Handle running the statement, as recorded
+// in ctx, or the package-level QueryHook.
+func callQueryHook(ctx context.Context, query string, args []interface{}, start time.Time, err error) {
+	hook, _ := ctx.Value(queryHookKey{}).(func(context.Context, string, []interface{}, time.Duration, error))
+	if hook == nil {
+		hook = QueryHook
+	}
+	if hook != nil {
+		hook(ctx, query, args, time.Since(start), err)
+	}
+}
+
+// pkgPrefix is the prefix of the names of the functions in this package.
+var pkgPrefix = reflect.TypeOf(Statement{}).PkgPath() + "."
+
+// logStatement logs the execution of a statement to the Logger of the Handle running
+// it, as recorded in ctx, or to the package-level Logger.
+func logStatement(ctx context.Context, kind, query string, start time.Time, rows int64, err error) {
+	l, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	if l == nil {
+		l = Logger
+	}
+	if l == nil {
+		return
+	}
+	level := LogLevel
+	if err != nil && !errors.Is(err, ErrNoRows) {
+		level = ErrorLogLevel
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), level, "sqlstruct: "+kind, callerPC())
+	r.AddAttrs(
+		slog.String("query", query),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("rows", rows),
+	)
+	if err != nil {
+		r.AddAttrs(slog.Any("error", err))
+	}
+	l.Handler().Handle(ctx, r)
+}
+
+// callerPC returns the program counter of the innermost caller outside this package,
+// in the form returned by runtime.Callers. Frame.PC is one less than that, which also
+// keeps the caller of a function inlined into it addressable.
+func callerPC() uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPrefix) || strings.HasSuffix(f.File, "_test.go") {
+			return f.PC + 1
+		}
+		if !more {
+			return 0
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	var buf bytes.Buffer
+	defer func() { Logger = nil }()
+	Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug}))
+
+	if _, err := Query[testUser]("SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	out := buf.String()
+	for _, s := range []string{"level=DEBUG", `msg="sqlstruct: query"`, `query="SELECT id, name FROM users"`, "rows=2", "log_test.go"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected log to contain %s, got %q", s, out)
+		}
+	}
+
+	buf.Reset()
+	fake.err = errors.New("boom")
+	if _, err := Query[testUser]("SELECT * FROM users"); err == nil {
+		t.Fatal("expected error")
+	}
+	if out := buf.String(); !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "error=boom") {
+		t.Errorf("expected error record got %q", out)
+	}
+}
+
+func TestHandleLogger(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"})
+
+	var global, buf bytes.Buffer
+	defer func() { Logger = nil }()
+	Logger = slog.New(slog.NewTextHandler(&global, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := New(sqldb)
+	h.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ctx := context.Background()
+	if _, err := QueryContext[testUser](ctx, "SELECT * FROM users", WithHandle(h)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(buf.String(), `query="SELECT id, name FROM users"`) || global.Len() != 0 {
+		t.Errorf("expected record in the Logger of the Handle only, got %q and %q", buf.String(), global.String())
+	}
+
+	buf.Reset()
+	err := runQuery(context.WithValue(ctx, loggerKey{}, h.Logger), sqldb, "SELECT id, name FROM users", nil, func(rows *sql.Rows) (int64, error) {
+		return 0, fmt.Errorf("user: %w", ErrNoRows)
+	})
+	if !errors.Is(err, ErrNoRows) {
+		t.Fatalf("expected ErrNoRows got %v", err)
+	}
+	if out := buf.String(); strings.Contains(out, "level=ERROR") || !strings.Contains(out, "level=DEBUG") {
+		t.Errorf("expected wrapped ErrNoRows to be logged at the normal level, got %q", out)
+	}
+}
+
+func TestQueryHook(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	var global []string
+	defer func() { QueryHook = nil }()
+	QueryHook = func(ctx context.Context, query string, args []interface{}, d time.Duration, err error) {
+		global = append(global, query)
+	}
+
+	var queries []string
+	var hookArgs [][]interface{}
+	h := New(sqldb)
+	h.Dialect = Postgres
+	h.QueryHook = func(ctx context.Context, query string, args []interface{}, d time.Duration, err error) {
+		queries = append(queries, query)
+		hookArgs = append(hookArgs, args)
+	}
+
+	var users []testUser
+	if err := h.Query(&users, "SELECT * FROM users WHERE id = ?", 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"SELECT id, name FROM users WHERE id = $1"}; !reflect.DeepEqual(queries, expected) {
+		t.Errorf("expected %q got %q", expected, queries)
+	}
+	if expected := [][]interface{}{{1}}; !reflect.DeepEqual(hookArgs, expected) {
+		t.Errorf("expected %v got %v", expected, hookArgs)
+	}
+	if len(global) != 0 {
+		t.Errorf("expected the package-level hook not to be called, got %q", global)
+	}
+
+	setFakeDatabase(t).setRows([]string{"id", "name"})
+	if _, err := Query[testUser]("SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"SELECT id, name FROM users"}; !reflect.DeepEqual(global, expected) {
+		t.Errorf("expected %q got %q", expected, global)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import "strings"
+
+// ColumnMapper is implemented by struct types which map their fields to columns
+// themselves, such as those for which code has been generated by the sqlstructgen
+// command. Scan uses the methods of a ColumnMapper instead of reflection, unless
+// the scan is strict, NULL is scanned as the zero value, or some of the fields have
+// a default value or are times parsed with TimeLayouts.
+type ColumnMapper interface {
+	// SQLDest returns the destination passed to Rows.Scan for the column, given in
+	// lower case, or nil if no field is mapped to it.
+	SQLDest(column string) interface{}
+}
+
+// scanMapper scans the current row of rows into m, removing prefix from the column
+// names.
+func scanMapper(m ColumnMapper, rows Rows, prefix string) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vp := getValues(len(cols))
+	defer putValues(vp)
+	for i, name := range cols {
+		if v := m.SQLDest(strings.ToLower(strings.TrimPrefix(name, prefix))); v != nil {
+			(*vp)[i] = v
+		} else {
+			(*vp)[i] = discard
+		}
+	}
+	return rows.Scan(*vp...)
+}
@@ -0,0 +1,82 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+// mappedType implements ColumnMapper the way sqlstructgen generates it, except that
+// it records whether SQLDest was used.
+type mappedType struct {
+	A      string `sql:"a"`
+	B      string `sql:"b"`
+	mapped bool
+}
+
+func (v *mappedType) SQLDest(column string) interface{} {
+	v.mapped = true
+	switch column {
+	case "a":
+		return &v.A
+	case "b":
+		return &v.B
+	}
+	return nil
+}
+
+func TestScanColumnMapper(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("x_a", "a")
+	rows.addValue("x_c", "c")
+	rows.addValue("x_b", "b")
+
+	var v mappedType
+	if err := ScanAliased(&v, rows, "x"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !v.mapped || v.A != "a" || v.B != "b" {
+		t.Errorf("unexpected result %+v", v)
+	}
+
+	var strict mappedType
+	if err := ScanStrict(&strict, rows); err == nil {
+		t.Error("expected mapping error from strict scan")
+	}
+	if strict.mapped {
+		t.Error("expected strict scan to use reflection")
+	}
+}
+
+func TestScanColumnMapperNull(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"a", "b"}, []driver.Value{"a", nil})
+	h := New(sqldb)
+	scan := func(v *mappedType) error {
+		rows, err := sqldb.Query("SELECT a, b FROM mapped")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer rows.Close()
+		rows.Next()
+		return h.Scan(v, rows)
+	}
+
+	var v mappedType
+	var serr *ScanError
+	if err := scan(&v); !errors.As(err, &serr) || serr.Column != "b" || serr.Field != "mappedType.B" {
+		t.Errorf("expected *ScanError for column b, got %v", err)
+	}
+
+	h.NullToZero = true
+	v = mappedType{B: "z"}
+	if err := scan(&v); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.mapped || v.A != "a" || v.B != "" {
+		t.Errorf("unexpected result %+v", v)
+	}
+}
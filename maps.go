@@ -0,0 +1,51 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+// ScanMap scans the current row of rows into a map from column names to values, for
+// queries whose result has no corresponding struct type. The values are those returned
+// by the driver, except that byte slices are copied so that they remain valid after
+// the next call to rows.Next.
+func ScanMap(rows Rows) (map[string]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, len(cols))
+	for i, col := range cols {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = append([]byte(nil), b...)
+		}
+		m[col] = values[i]
+	}
+	return m, nil
+}
+
+// MapsFromRows scans all remaining rows into maps as described by ScanMap and closes rows.
+func MapsFromRows(rows RowsIter) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		m, err := ScanMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestMapsFromRows(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name", "data"},
+		[]driver.Value{int64(1), "a", []byte("x")},
+		[]driver.Value{int64(2), nil, nil},
+	)
+
+	rows, err := sqldb.Query("SELECT id, name, data FROM t")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	maps, err := MapsFromRows(rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []map[string]interface{}{
+		{"id": int64(1), "name": "a", "data": []byte("x")},
+		{"id": int64(2), "name": nil, "data": nil},
+	}
+	if !reflect.DeepEqual(maps, expected) {
+		t.Errorf("expected %v got %v", expected, maps)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// MetricsCollector receives measurements of the work done by this package, for
+// example to export them to a monitoring system. Implementations must be safe for
+// concurrent use. See the prometheus subpackage for a ready-made implementation.
+type MetricsCollector interface {
+	// QueryStarted is called before a statement is sent to the database.
+	// name is the name given with QueryName, if any.
+	QueryStarted(ctx context.Context, name string)
+
+	// QueryFinished is called after a statement has completed, including the
+	// scanning of its rows. rows is the number of rows read or affected.
+	QueryFinished(ctx context.Context, name string, d time.Duration, rows int64, err error)
+
+	// ScanFailed is called when a row cannot be scanned into a value of type typ.
+	ScanFailed(typ reflect.Type, err error)
+
+	// FieldCacheLookup is called whenever the field mapping of a type is looked up,
+	// reporting whether it was already cached.
+	FieldCacheLookup(hit bool)
+}
+
+// Metrics, if set, receives measurements of the work done by this package. It is
+// used for a Handle whose own Metrics is nil.
+var Metrics MetricsCollector
+
+type metricsKey struct{}
+
+// metricsOf returns the MetricsCollector of the Handle running the statement, as
+// recorded in ctx, or the package-level Metrics.
+func metricsOf(ctx context.Context) MetricsCollector {
+	if m, ok := ctx.Value(metricsKey{}).(MetricsCollector); ok {
+		return m
+	}
+	return Metrics
+}
+
+type queryNameKey struct{}
+
+// ContextWithQueryName returns a copy of ctx which names the statements executed
+// with it for the purpose of metrics and logging.
+func ContextWithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+// queryName returns the name of the statements executed with ctx.
+func queryName(ctx context.Context) string {
+	name, _ := ctx.Value(queryNameKey{}).(string)
+	return name
+}
+
+// QueryName names the statement executed by the call for the purpose of metrics and
+// logging. See also ContextWithQueryName.
+func QueryName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// queryStarted reports the start of a statement to the metrics of ctx.
+func queryStarted(ctx context.Context) {
+	if m := metricsOf(ctx); m != nil {
+		m.QueryStarted(ctx, queryName(ctx))
+	}
+}
+
+// queryFinished reports the end of a statement to the metrics of ctx.
+func queryFinished(ctx context.Context, start time.Time, rows int64, err error) {
+	if m := metricsOf(ctx); m != nil {
+		m.QueryFinished(ctx, queryName(ctx), time.Since(start), rows, err)
+	}
+}
+
+// metrics returns the MetricsCollector of h.
+func (h *Handle) metrics() MetricsCollector {
+	if h.Metrics != nil {
+		return h.Metrics
+	}
+	return Metrics
+}
+
+// scanFailed reports a failed scan into a value of type typ to the metrics of h.
+func (h *Handle) scanFailed(typ reflect.Type, err error) {
+	if m := h.metrics(); m != nil {
+		m.ScanFailed(typ, err)
+	}
+}
+
+// fieldCacheLookup reports a lookup in the field-info cache to the metrics of h.
+func (h *Handle) fieldCacheLookup(hit bool) {
+	if m := h.metrics(); m != nil {
+		m.FieldCacheLookup(hit)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMetrics is a MetricsCollector which records the calls made to it.
+type testMetrics struct {
+	mu       sync.Mutex
+	started  []string
+	finished []string
+	rows     int64
+	scans    []reflect.Type
+	lookups  int
+}
+
+func (m *testMetrics) QueryStarted(ctx context.Context, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = append(m.started, name)
+}
+
+func (m *testMetrics) QueryFinished(ctx context.Context, name string, d time.Duration, rows int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished = append(m.finished, name)
+	m.rows += rows
+}
+
+func (m *testMetrics) ScanFailed(typ reflect.Type, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scans = append(m.scans, typ)
+}
+
+func (m *testMetrics) FieldCacheLookup(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+}
+
+func TestMetrics(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	m := &testMetrics{}
+	Metrics = m
+	defer func() { Metrics = nil }()
+
+	if _, err := Query[testUser]("SELECT * FROM users", QueryName("users")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(m.started, []string{"users"}) || !reflect.DeepEqual(m.finished, []string{"users"}) {
+		t.Errorf("expected users query to be started and finished got %v and %v", m.started, m.finished)
+	}
+	if m.rows != 2 {
+		t.Errorf("expected 2 rows got %d", m.rows)
+	}
+	if m.lookups == 0 {
+		t.Error("expected field cache lookups")
+	}
+
+	fake.setRows([]string{"id"}, []driver.Value{"not a number"})
+	if _, err := Query[testUser]("SELECT * FROM users"); err == nil {
+		t.Fatal("expected scan error")
+	}
+	if len(m.scans) != 1 || m.scans[0] != reflect.TypeOf(testUser{}) {
+		t.Errorf("expected a scan failure for testUser got %v", m.scans)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	global := &testMetrics{}
+	Metrics = global
+	defer func() { Metrics = nil }()
+
+	m := &testMetrics{}
+	h := New(sqldb)
+	h.Metrics = m
+
+	var users []testUser
+	if err := h.Query(&users, "SELECT * FROM users", QueryName("users")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fake.setRows([]string{"id"}, []driver.Value{"not a number"})
+	if err := h.Query(&users, "SELECT * FROM users"); err == nil {
+		t.Fatal("expected scan error")
+	}
+	if !reflect.DeepEqual(m.started, []string{"users", ""}) || m.rows != 1 || len(m.scans) != 1 || m.lookups == 0 {
+		t.Errorf("unexpected handle metrics %+v", m)
+	}
+	if len(global.started) != 0 || len(global.scans) != 0 || global.lookups != 0 {
+		t.Errorf("expected no package-level metrics got %+v", global)
+	}
+}
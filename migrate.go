@@ -0,0 +1,255 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffSchema returns the statements, in the dialect d, which bring the table of T in
+// the database q in line with the fields of T. If the table does not exist, they are
+// the statement returned by CreateTableSQL followed by the CREATE INDEX statements of
+// the table. Otherwise they are:
+//
+//   - ALTER TABLE statements adding the columns of fields missing from the table,
+//     defined as by CreateTableSQL.
+//   - ALTER TABLE statements changing the nullability of columns which differ from
+//     their fields. SQLite cannot change the nullability of a column without
+//     rebuilding its table, so no statement is generated for it.
+//   - CREATE INDEX statements for the indexes of fields which do not exist yet.
+//
+// Fields tagged with the "index" option are indexed. The index is named
+// idx_<table>_<column> unless a name is given, as in index=idx_name; fields sharing a
+// name make up one index on their columns, in the order of the fields. For example:
+//
+//	type Event struct {
+//		Id      int64     `sql:"id,pk,auto"`
+//		Kind    string    `sql:"kind,size=20,index=idx_event_kind_time"`
+//		Created time.Time `sql:"created,index=idx_event_kind_time"`
+//	}
+//
+//	stmts, err := sqlstruct.DiffSchema[Event](ctx, db, sqlstruct.Postgres)
+//
+// The changes are additive: columns and indexes which are not declared by T are left
+// alone, and column types are not changed. The statements are meant to be reviewed
+// before they are run, for example to add defaults to new NOT NULL columns of tables
+// which already have rows. Indexes are not compared for the Generic dialect, for which
+// there is no standard way of listing them.
+func DiffSchema[T any](ctx context.Context, q Queryer, d Dialect) ([]string, error) {
+	o := applyOptions([]Option{Executor(q), WithDialect(d)})
+	h := o.handleOf()
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	table := h.tableName(typ)
+	columns, err := o.tableColumns(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	var stmts []string
+	if len(columns) == 0 {
+		create, err := CreateTableSQL[T](d)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, create)
+		for _, index := range tableIndexes(table, fields) {
+			stmts = append(stmts, h.createIndex(typ, index))
+		}
+		return stmts, nil
+	}
+
+	single := len(fields.primaryKeys()) == 1
+	qtable := h.quotedTable(typ)
+	for _, name := range fields.names() {
+		f := fields[name]
+		col, ok := columns[strings.ToLower(name)]
+		if !ok {
+			def, _, err := columnDef(d, f, single)
+			if err != nil {
+				return nil, fmt.Errorf("sqlstruct: cannot create column %s of %s: %w", name, typ, err)
+			}
+			stmts = append(stmts, addColumn(d, qtable, h.quote(name), def))
+			continue
+		}
+		_, nullable := columnGoType(f.typ)
+		nullable = nullable && !f.pk
+		if col.nullable == nullable || d == SQLite {
+			continue
+		}
+		stmt, err := alterNullable(d, qtable, h.quote(name), f, nullable)
+		if err != nil {
+			return nil, fmt.Errorf("sqlstruct: cannot alter column %s of %s: %w", name, typ, err)
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	if d == Generic {
+		return stmts, nil
+	}
+	existing, err := o.tableIndexNames(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range tableIndexes(table, fields) {
+		if !existing[strings.ToLower(index.name)] {
+			stmts = append(stmts, h.createIndex(typ, index))
+		}
+	}
+	return stmts, nil
+}
+
+// addColumn returns the statement adding the column col defined by def to table.
+func addColumn(d Dialect, table, col, def string) string {
+	switch d {
+	case SQLServer:
+		return "ALTER TABLE " + table + " ADD " + col + " " + def
+	case Oracle:
+		return "ALTER TABLE " + table + " ADD (" + col + " " + def + ")"
+	}
+	return "ALTER TABLE " + table + " ADD COLUMN " + col + " " + def
+}
+
+// alterNullable returns the statement making the column col of the field f nullable,
+// or NOT NULL if nullable is false.
+func alterNullable(d Dialect, table, col string, f *field, nullable bool) (string, error) {
+	null := " NOT NULL"
+	if nullable {
+		null = " NULL"
+	}
+	switch d {
+	case MySQL, SQLServer:
+		typ, _ := columnGoType(f.typ)
+		if f.split != "" || f.timeFormat != "" {
+			typ = reflect.TypeOf("")
+		}
+		size, _ := f.opts.Value("size")
+		def, err := columnType(d, typ, size, f.pk)
+		if err != nil {
+			return "", err
+		}
+		if d == MySQL {
+			return "ALTER TABLE " + table + " MODIFY COLUMN " + col + " " + def + null, nil
+		}
+		return "ALTER TABLE " + table + " ALTER COLUMN " + col + " " + def + null, nil
+	case Oracle:
+		return "ALTER TABLE " + table + " MODIFY (" + col + null + ")", nil
+	}
+	if nullable {
+		return "ALTER TABLE " + table + " ALTER COLUMN " + col + " DROP NOT NULL", nil
+	}
+	return "ALTER TABLE " + table + " ALTER COLUMN " + col + " SET NOT NULL", nil
+}
+
+// tableIndex is an index declared with the "index" option.
+type tableIndex struct {
+	name   string
+	fields []*field
+}
+
+// tableIndexes returns the indexes declared by the fields of table. The columns of
+// each index are in the order of their fields in the struct.
+func tableIndexes(table string, fields fieldInfo) []tableIndex {
+	_, base := splitTable(table)
+	var indexes []tableIndex
+	pos := make(map[string]int)
+	for _, name := range fields.names() {
+		value, ok := fields[name].opts.Value("index")
+		if !ok {
+			continue
+		}
+		if value == "" {
+			value = "idx_" + base + "_" + name
+		}
+		i, ok := pos[value]
+		if !ok {
+			i = len(indexes)
+			pos[value] = i
+			indexes = append(indexes, tableIndex{name: value})
+		}
+		indexes[i].fields = append(indexes[i].fields, fields[name])
+	}
+	for _, index := range indexes {
+		sort.Slice(index.fields, func(i, j int) bool {
+			a, b := index.fields[i].index, index.fields[j].index
+			for k := 0; k < len(a) && k < len(b); k++ {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+			return len(a) < len(b)
+		})
+	}
+	return indexes
+}
+
+// createIndex returns the statement creating index on the table of typ.
+func (h *Handle) createIndex(typ reflect.Type, index tableIndex) string {
+	cols := make([]string, len(index.fields))
+	for i, f := range index.fields {
+		cols[i] = h.quote(f.name)
+	}
+	return "CREATE INDEX " + h.quote(index.name) + " ON " + h.quotedTable(typ) + " (" + strings.Join(cols, ", ") + ")"
+}
+
+// tableIndexNames returns the lowercase names of the indexes of table.
+func (o options) tableIndexNames(ctx context.Context, table string) (map[string]bool, error) {
+	schema, name := splitTable(table)
+	args := []interface{}{name}
+	var query string
+	switch o.dialectOf() {
+	case Postgres:
+		query = "SELECT indexname FROM pg_indexes WHERE tablename = ? AND schemaname = current_schema()"
+		if schema != "" {
+			query = "SELECT indexname FROM pg_indexes WHERE tablename = ? AND schemaname = ?"
+			args = append(args, schema)
+		}
+	case MySQL:
+		query = "SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_name = ? AND table_schema = DATABASE()"
+		if schema != "" {
+			query = "SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_name = ? AND table_schema = ?"
+			args = append(args, schema)
+		}
+	case SQLite:
+		query = "SELECT name FROM pragma_index_list(?)"
+		if schema != "" {
+			query = "SELECT name FROM pragma_index_list(?, ?)"
+			args = append(args, schema)
+		}
+	case SQLServer:
+		query = "SELECT name FROM sys.indexes WHERE object_id = OBJECT_ID(?) AND name IS NOT NULL"
+		args[0] = table
+	case Oracle:
+		query = "SELECT index_name FROM user_indexes WHERE table_name = ?"
+		args[0] = strings.ToUpper(name)
+		if schema != "" {
+			query = "SELECT index_name FROM all_indexes WHERE table_name = ? AND owner = ?"
+			args = append(args, strings.ToUpper(schema))
+		}
+	}
+
+	names := make(map[string]bool)
+	err := o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		var n int64
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return n, err
+			}
+			names[strings.ToLower(name)] = true
+			n++
+		}
+		return n, rows.Err()
+	})
+	return names, err
+}
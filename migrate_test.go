@@ -0,0 +1,102 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testEvent struct {
+	Id      int64     `sql:"id,pk,auto"`
+	Kind    string    `sql:"kind,size=20,index=idx_event_kind_time"`
+	Created time.Time `sql:"created,index=idx_event_kind_time"`
+	Note    *string   `sql:"note,index"`
+}
+
+func (testEvent) TableName() string { return "event" }
+
+func TestDiffSchema(t *testing.T) {
+	db, fake := newFakeDB(t)
+	ctx := context.Background()
+
+	fake.setQueryRows("SELECT column_name", []string{"column_name", "data_type", "is_nullable"},
+		[]driver.Value{"id", "bigint", "NO"},
+		[]driver.Value{"kind", "character varying", "YES"},
+		[]driver.Value{"note", "text", "YES"},
+		[]driver.Value{"legacy", "text", "NO"},
+	)
+	fake.setQueryRows("SELECT indexname", []string{"indexname"}, []driver.Value{"idx_event_note"})
+
+	stmts, err := DiffSchema[testEvent](ctx, db, Postgres)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"ALTER TABLE event ADD COLUMN created TIMESTAMP WITH TIME ZONE NOT NULL",
+		"ALTER TABLE event ALTER COLUMN kind SET NOT NULL",
+		"CREATE INDEX idx_event_kind_time ON event (kind, created)",
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("expected %q got %q", expected, stmts)
+	}
+
+	fake.setQueryRows("SELECT column_name", []string{"column_name", "data_type", "is_nullable"})
+	stmts, err = DiffSchema[testEvent](ctx, db, Postgres)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected = []string{
+		"CREATE TABLE event (created TIMESTAMP WITH TIME ZONE NOT NULL, id BIGSERIAL NOT NULL, kind VARCHAR(20) NOT NULL, note TEXT, PRIMARY KEY (id))",
+		"CREATE INDEX idx_event_kind_time ON event (kind, created)",
+		"CREATE INDEX idx_event_note ON event (note)",
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("expected %q got %q", expected, stmts)
+	}
+}
+
+type testSchemaEvent struct {
+	Kind string `sql:"kind,index"`
+}
+
+func (testSchemaEvent) TableName() string { return "audit.event" }
+
+func TestCreateIndexSchema(t *testing.T) {
+	h := NewWithConfig(nil, Config{Quoter: func(s string) string { return `"` + s + `"` }})
+	typ := reflect.TypeOf(testSchemaEvent{})
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	indexes := tableIndexes(h.tableName(typ), fields)
+	if len(indexes) != 1 {
+		t.Fatalf("expected 1 index got %d", len(indexes))
+	}
+	expected := `CREATE INDEX "idx_event_kind" ON "audit"."event" ("kind")`
+	if stmt := h.createIndex(typ, indexes[0]); stmt != expected {
+		t.Errorf("expected %q got %q", expected, stmt)
+	}
+}
+
+func TestAlterNullable(t *testing.T) {
+	f := newField("name", []int{0}, reflect.TypeOf(""), "size=50")
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{MySQL, "ALTER TABLE t MODIFY COLUMN name VARCHAR(50) NOT NULL"},
+		{SQLServer, "ALTER TABLE t ALTER COLUMN name NVARCHAR(50) NOT NULL"},
+		{Oracle, "ALTER TABLE t MODIFY (name NOT NULL)"},
+	}
+	for _, test := range tests {
+		stmt, err := alterNullable(test.dialect, "t", "name", f, false)
+		if err != nil || stmt != test.expected {
+			t.Errorf("%s: expected %q got %q, %v", test.dialect, test.expected, stmt, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Named replaces the :name parameters in query with ? placeholders and returns the
+// query along with the values of the parameters, taken from arg. arg is either a
+// map[string]interface{} or a struct, or pointer to one, whose fields are matched to
+// parameters by column name, using the same tags and NameMapper as Scan:
+//
+//	query, args, err := sqlstruct.Named("UPDATE users SET name = :name WHERE id = :id", user)
+//
+// Parameters inside quoted strings and identifiers are left alone, as are Postgres
+// casts such as ::text.
+func Named(query string, arg interface{}) (string, []interface{}, error) {
+	return defaultHandle.bindNamed(query, arg)
+}
+
+// NamedQuery works like QueryContext, but binds the :name parameters of query from
+// arg as described by Named.
+func NamedQuery[T any](ctx context.Context, query string, arg interface{}, opts ...Option) ([]T, error) {
+	query, args, err := applyOptions(opts).handleOf().bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		args = append(args, opt)
+	}
+	return QueryContext[T](ctx, query, args...)
+}
+
+// NamedExec executes the statement query, binding its :name parameters from arg as
+// described by Named.
+func NamedExec(ctx context.Context, query string, arg interface{}, opts ...Option) (sql.Result, error) {
+	o := applyOptions(opts)
+	query, args, err := o.handleOf().bindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return o.exec(ctx, query, args)
+}
+
+// bindNamed implements Named for the configuration of h.
+func (h *Handle) bindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	lookup, err := h.namedLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var args []interface{}
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			// A Postgres cast such as ::text.
+			b.WriteString("::")
+			for i += 2; i < len(query) && isNameByte(query[i]); i++ {
+				b.WriteByte(query[i])
+			}
+			i--
+			continue
+		case c == ':' && i+1 < len(query) && isNameByte(query[i+1]):
+			j := i + 1
+			for j < len(query) && (isNameByte(query[j]) || query[j] == '.') {
+				j++
+			}
+			name := query[i+1 : j]
+			v, ok := lookup(name)
+			if !ok {
+				return "", nil, fmt.Errorf("sqlstruct: no value for parameter :%s in %T", name, arg)
+			}
+			args = append(args, v)
+			b.WriteByte('?')
+			i = j - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), args, nil
+}
+
+// namedLookup returns a function looking up the values of parameters in arg.
+func (h *Handle) namedLookup(arg interface{}) (func(string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			v, ok := m[name]
+			return v, ok
+		}, nil
+	}
+	rv, err := structOf(arg)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := h.getFieldInfo(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) (interface{}, bool) {
+		f, ok := fields[strings.ToLower(name)]
+		if !ok {
+			return nil, false
+		}
+		return f.value(rv), true
+	}, nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	tests := []struct {
+		query    string
+		arg      interface{}
+		expected string
+		args     []interface{}
+	}{
+		{
+			"UPDATE users SET name = :name WHERE id = :id",
+			testUser{1, "a"},
+			"UPDATE users SET name = ? WHERE id = ?",
+			[]interface{}{"a", 1},
+		},
+		{
+			"SELECT * FROM users WHERE name = ':id' AND id = :Id::int",
+			&testUser{2, "b"},
+			"SELECT * FROM users WHERE name = ':id' AND id = ?::int",
+			[]interface{}{2},
+		},
+		{
+			"SELECT * FROM users WHERE created > :since",
+			map[string]interface{}{"since": 10},
+			"SELECT * FROM users WHERE created > ?",
+			[]interface{}{10},
+		},
+	}
+	for _, test := range tests {
+		query, args, err := Named(test.query, test.arg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if query != test.expected {
+			t.Errorf("expected %q got %q", test.expected, query)
+		}
+		if len(args) != len(test.args) {
+			t.Errorf("expected args %v got %v", test.args, args)
+			continue
+		}
+		for i := range args {
+			if args[i] != test.args[i] {
+				t.Errorf("expected args %v got %v", test.args, args)
+			}
+		}
+	}
+
+	if _, _, err := Named("SELECT :missing", testUser{}); err == nil {
+		t.Error("expected error for unknown parameter")
+	}
+}
+
+func TestNamedQuery(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	users, err := NamedQuery[testUser](context.Background(), "SELECT * FROM users WHERE name = :name", testUser{Name: "a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 1 || users[0] != (testUser{1, "a"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+	if _, err := NamedExec(context.Background(), "DELETE FROM users WHERE id = :id", testUser{Id: 1}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{"SELECT id, name FROM users WHERE name = ?", "DELETE FROM users WHERE id = ?"}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+}
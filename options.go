@@ -0,0 +1,365 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Option configures a single call to Query, QueryRow or SliceFromRows.
+// Options may be passed to Query and QueryRow among the query arguments;
+// they are removed before the query is executed.
+type Option func(*options)
+
+type options struct {
+	capacity   int
+	workers    int
+	timeout    time.Duration
+	maxRows    int
+	executor   Queryer
+	strict     bool
+	dialect    *Dialect
+	dryRun     *[]Statement
+	name       string
+	handle     *Handle
+	columns    []string
+	params     int
+	tx         bool
+	expandIn   bool
+	only       []string
+	returning  bool
+	unscoped   bool
+	primary    bool
+	nullToZero bool
+}
+
+// ErrMaxRows is returned when a result has more rows than allowed by the MaxRows option.
+var ErrMaxRows = errors.New("sqlstruct: result has more rows than allowed by MaxRows")
+
+// Capacity preallocates room for n rows in the slice returned by Query or
+// SliceFromRows, or filled by ScanAll. It avoids repeatedly growing the slice when
+// the number of rows is known or can be estimated in advance.
+func Capacity(n int) Option {
+	return func(o *options) {
+		o.capacity = n
+	}
+}
+
+// Timeout cancels the query if it, including the scanning of its rows, takes longer than d.
+func Timeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// MaxRows stops scanning and returns ErrMaxRows if the result has more than n rows.
+func MaxRows(n int) Option {
+	return func(o *options) {
+		o.maxRows = n
+	}
+}
+
+// Executor runs the query on q instead of the database set with SetDatabase.
+func Executor(q Queryer) Option {
+	return func(o *options) {
+		o.executor = q
+	}
+}
+
+// Strict returns a *MappingError if the result has columns which are not mapped to any
+// field of the destination, or if the destination has fields with no column in the
+// result, instead of ignoring them. See ScanStrict.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithDialect uses d instead of the dialect of the Handle when generating SQL.
+func WithDialect(d Dialect) Option {
+	return func(o *options) {
+		o.dialect = &d
+	}
+}
+
+// Primary runs the query on the primary database of a Handle created with
+// NewWithReplicas instead of one of its replicas.
+func Primary() Option {
+	return func(o *options) {
+		o.primary = true
+	}
+}
+
+// WithHandle runs the call on the database of h, with the configuration of h instead
+// of the package-level settings. See NewWithConfig.
+func WithHandle(h *Handle) Option {
+	return func(o *options) {
+		o.handle = h
+	}
+}
+
+// DryRun appends the statement the call would execute to stmts instead of running it.
+// The call then returns an empty result. See also ContextWithDryRun.
+func DryRun(stmts *[]Statement) Option {
+	return func(o *options) {
+		o.dryRun = stmts
+	}
+}
+
+// withExecutor returns a copy of args with an Executor option for q appended.
+func withExecutor(q Queryer, args []interface{}) []interface{} {
+	return append(args[:len(args):len(args)], Executor(q))
+}
+
+// UpdateColumns limits Update to setting the given columns. By default all columns
+// except the primary key and auto-generated columns are set.
+func UpdateColumns(columns ...string) Option {
+	return func(o *options) {
+		o.columns = columns
+	}
+}
+
+// ParamLimit splits BatchInsert into statements of at most n bind parameters
+// instead of MaxParams, for example 65535 for Postgres.
+func ParamLimit(n int) Option {
+	return func(o *options) {
+		o.params = n
+	}
+}
+
+// InTransaction runs all of the statements executed by BatchInsert in a single
+// transaction, so that either all of the rows are inserted or none are. It has no
+// effect when the Executor cannot begin transactions, such as a *sql.Tx.
+func InTransaction() Option {
+	return func(o *options) {
+		o.tx = true
+	}
+}
+
+// Only replaces QueryReplace with the columns of only the named fields of the result
+// type, as listed by ColumnsOnly. The other fields are left unchanged when scanning.
+func Only(fields ...string) Option {
+	return func(o *options) {
+		o.only = fields
+	}
+}
+
+// Returning makes Insert scan the values generated by the database for the fields
+// tagged with the "auto" or "readonly" options back into the inserted struct, which
+// must be passed by pointer. See Insert.
+func Returning() Option {
+	return func(o *options) {
+		o.returning = true
+	}
+}
+
+// Unscoped includes soft-deleted rows, whose field tagged with the "softdelete" option
+// is set, in Count, Exists, Load and the queries using TableReplace, and makes Delete
+// remove rows for good instead of marking them as deleted. See Delete.
+func Unscoped() Option {
+	return func(o *options) {
+		o.unscoped = true
+	}
+}
+
+// NullToZero scans NULL columns into fields which are not pointers, such as a string
+// or an int, as their zero value instead of returning an error, as happens for the
+// columns of a LEFT JOIN without a match:
+//
+//	users, err := sqlstruct.QueryContext[UserWithTeam](ctx, "SELECT u.name, t.name AS team "+
+//		"FROM users AS u LEFT JOIN teams AS t ON t.id = u.team_id", sqlstruct.NullToZero())
+//
+// Fields tagged with the "default" option are set to their default instead. Fields
+// which implement sql.Scanner, such as sql.NullString, scan NULL themselves. It can be
+// enabled for all the scans done with a Handle with its NullToZero field.
+func NullToZero() Option {
+	return func(o *options) {
+		o.nullToZero = true
+	}
+}
+
+// splitOptions separates the Options from the query arguments in args.
+func splitOptions(args []interface{}) ([]interface{}, []Option) {
+	var opts []Option
+	var rest []interface{}
+	for i, arg := range args {
+		if opt, ok := arg.(Option); ok {
+			if rest == nil {
+				rest = append(make([]interface{}, 0, len(args)), args[:i]...)
+			}
+			opts = append(opts, opt)
+		} else if rest != nil {
+			rest = append(rest, arg)
+		}
+	}
+	if rest == nil {
+		return args, nil
+	}
+	return rest, opts
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// handleOf returns the Handle the call was made on.
+func (o options) handleOf() *Handle {
+	if o.handle != nil {
+		return o.handle
+	}
+	return defaultHandle
+}
+
+// queryer returns the Queryer to run queries on.
+func (o options) queryer() (Queryer, error) {
+	if o.executor != nil {
+		return o.executor, nil
+	}
+	h := o.handleOf()
+	if h.db == nil {
+		if o.dryRun == nil {
+			return nil, errors.New("sqlstruct: no database set, call SetDatabase first")
+		}
+		return nil, nil
+	}
+	return h.queryer(), nil
+}
+
+// reader returns the Queryer selected by o for running queries, which is a replica of
+// the Handle unless the Primary option is given.
+func (o options) reader() (Queryer, error) {
+	if o.executor != nil || o.primary {
+		return o.queryer()
+	}
+	if h := o.handleOf(); h.db != nil {
+		return h.replica(), nil
+	}
+	return o.queryer()
+}
+
+// scan scans the current row of rows into dest.
+func (o options) scan(ctx context.Context, dest interface{}, rows Rows) error {
+	return o.handleOf().doScan(ctx, dest, rows, "", o.strict, o.nullToZero)
+}
+
+// run runs query on the Queryer selected by o, passing its rows to scan.
+// See runQuery.
+func (o options) run(ctx context.Context, query string, args []interface{}, scan func(context.Context, *sql.Rows) (int64, error)) error {
+	q, err := o.reader()
+	if err != nil {
+		return err
+	}
+	query, args, err = o.prepare(query, args)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+	return o.retryPolicy().do(ctx, func() (bool, error) {
+		scanned := false
+		err := runQuery(ctx, q, query, args, func(rows *sql.Rows) (int64, error) {
+			scanned = true
+			return scan(ctx, rows)
+		})
+		return !scanned, err
+	})
+}
+
+// exec runs the statement query on the Queryer selected by o. See runExec.
+func (o options) exec(ctx context.Context, query string, args []interface{}) (sql.Result, error) {
+	q, err := o.queryer()
+	if err != nil {
+		return nil, err
+	}
+	return o.execOn(ctx, q, query, args)
+}
+
+// execOn works like exec but runs the statement on e.
+func (o options) execOn(ctx context.Context, e Execer, query string, args []interface{}) (sql.Result, error) {
+	query, args, err := o.prepare(query, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+	var res sql.Result
+	err = o.retryPolicy().do(ctx, func() (bool, error) {
+		var err error
+		res, err = runExec(ctx, e, query, args)
+		return true, err
+	})
+	return res, err
+}
+
+// retryPolicy returns the RetryPolicy for the statements run with o, or nil if they
+// are run on a Queryer given with the Executor option, such as a transaction.
+func (o options) retryPolicy() *RetryPolicy {
+	if o.executor != nil {
+		return nil
+	}
+	return o.handleOf().retryPolicy()
+}
+
+// prepare applies the rewriting of query and args requested by o, converts the args
+// of types with a registered Converter, and rebinds the placeholders of query for the
+// dialect in use.
+func (o options) prepare(query string, args []interface{}) (string, []interface{}, error) {
+	if o.expandIn {
+		var err error
+		if query, args, err = In(query, args...); err != nil {
+			return "", nil, err
+		}
+	}
+	return o.dialectOf().Rebind(query), convertArgs(args), nil
+}
+
+// dialectOf returns the dialect to generate SQL for.
+func (o options) dialectOf() Dialect {
+	if o.dialect != nil {
+		return *o.dialect
+	}
+	return o.handleOf().dialect()
+}
+
+// context derives the context for a query from ctx, applying the Timeout, DryRun and
+// QueryName options and the Rewriter, Logger, QueryHook, Metrics and DefaultTimeout
+// of the Handle.
+func (o options) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	h := o.handleOf()
+	if h.Rewriter != nil {
+		ctx = context.WithValue(ctx, rewriterKey{}, h.Rewriter)
+	}
+	if h.Logger != nil {
+		ctx = context.WithValue(ctx, loggerKey{}, h.Logger)
+	}
+	if h.QueryHook != nil {
+		ctx = context.WithValue(ctx, queryHookKey{}, h.QueryHook)
+	}
+	if h.Metrics != nil {
+		ctx = context.WithValue(ctx, metricsKey{}, h.Metrics)
+	}
+	if o.name != "" {
+		ctx = ContextWithQueryName(ctx, o.name)
+	}
+	if o.dryRun != nil {
+		ctx = ContextWithDryRun(ctx, o.dryRun)
+	}
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	if d := h.defaultTimeout(); d > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			return context.WithTimeout(ctx, d)
+		}
+	}
+	return context.WithCancel(ctx)
+}
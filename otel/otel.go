@@ -0,0 +1,89 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+/*
+Package otel traces the statements executed by the sqlstruct package with
+OpenTelemetry.
+
+A single call starts tracing every statement with the global TracerProvider:
+
+	otel.Register()
+
+Each statement is recorded as a client span named after the name given by
+sqlstruct.QueryName or sqlstruct.ContextWithQueryName, or after the kind of the
+statement if it was not named. The span records the SQL text, the number of
+arguments and the number of rows read or affected. Failed statements set the span's
+status to Error; a query returning no rows where one was expected is not considered
+a failure.
+*/
+package otel
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kisielk/sqlstruct"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the instrumentation library creating the spans.
+const instrumentationName = "github.com/kisielk/sqlstruct/otel"
+
+// Attribute keys recorded on the spans of statements.
+const (
+	QueryKey     = attribute.Key("db.query.text")
+	ArgCountKey  = attribute.Key("sqlstruct.args")
+	RowsKey      = attribute.Key("sqlstruct.rows")
+	StatementKey = attribute.Key("sqlstruct.kind")
+)
+
+// Tracer is a sqlstruct.StatementTracer which records statements as OpenTelemetry
+// spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns a Tracer creating spans with the given TracerProvider, or the global
+// TracerProvider if tp is nil. It only records statements once it has been set as
+// sqlstruct.Tracer.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Register creates a Tracer using the global TracerProvider and sets it as
+// sqlstruct.Tracer.
+func Register() *Tracer {
+	t := New(nil)
+	sqlstruct.Tracer = t
+	return t
+}
+
+// StartStatement implements sqlstruct.StatementTracer.
+func (t *Tracer) StartStatement(ctx context.Context, kind, name, query string, args []interface{}) (context.Context, func(int64, error)) {
+	if name == "" {
+		name = kind
+	}
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			StatementKey.String(kind),
+			QueryKey.String(query),
+			ArgCountKey.Int(len(args)),
+		),
+	)
+	return ctx, func(rows int64, err error) {
+		span.SetAttributes(RowsKey.Int64(rows))
+		if err != nil && !errors.Is(err, sqlstruct.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tr := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	ctx, end := tr.StartStatement(context.Background(), "query", "users", "SELECT id FROM users WHERE id = ?", []interface{}{1})
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		t.Error("expected the statement context to hold the span")
+	}
+	end(1, nil)
+	_, end = tr.StartStatement(context.Background(), "exec", "", "DELETE FROM users", nil)
+	end(0, errors.New("boom"))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(spans))
+	}
+
+	s := spans[0]
+	if s.Name() != "users" || s.SpanKind() != trace.SpanKindClient || s.Status().Code != codes.Unset {
+		t.Errorf("unexpected span %q kind %v status %v", s.Name(), s.SpanKind(), s.Status())
+	}
+	attrs := attribute.NewSet(s.Attributes()...)
+	for key, expected := range map[attribute.Key]attribute.Value{
+		QueryKey:     attribute.StringValue("SELECT id FROM users WHERE id = ?"),
+		ArgCountKey:  attribute.IntValue(1),
+		RowsKey:      attribute.Int64Value(1),
+		StatementKey: attribute.StringValue("query"),
+	} {
+		if v, ok := attrs.Value(key); !ok || v != expected {
+			t.Errorf("expected %s=%v got %v", key, expected.Emit(), v.Emit())
+		}
+	}
+
+	s = spans[1]
+	if s.Name() != "exec" || s.Status().Code != codes.Error || s.Status().Description != "boom" {
+		t.Errorf("unexpected span %q status %v", s.Name(), s.Status())
+	}
+}
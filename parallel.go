@@ -0,0 +1,120 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Workers decodes rows into structs on n goroutines. Rows are still read from the
+// database one at a time, but their values are converted in parallel, which helps
+// when fields have expensive conversions such as JSON decoding or decryption.
+// The order of the rows is preserved.
+func Workers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// decodeChunk is the number of rows allocated together by scanSliceParallel.
+const decodeChunk = 256
+
+// decodeJob is a row read from the database waiting to be decoded into dest.
+type decodeJob struct {
+	dest interface{}
+	row  valueRows
+}
+
+// scanSliceParallel implements scanSlice for the Workers option.
+func scanSliceParallel(ctx context.Context, rows RowsIter, o options, slice reflect.Value) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	jobs := make(chan decodeJob, o.workers*2)
+	for i := 0; i < o.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := o.scan(ctx, job.dest, job.row); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	var chunks []reflect.Value
+	n := 0
+	for !failed() && rows.Next() {
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			break
+		}
+		if o.maxRows > 0 && n >= o.maxRows {
+			fail(ErrMaxRows)
+			break
+		}
+		if n%decodeChunk == 0 {
+			chunks = append(chunks, reflect.MakeSlice(slice.Type(), decodeChunk, decodeChunk))
+		}
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			fail(err)
+			break
+		}
+		dest := chunks[n/decodeChunk].Index(n % decodeChunk).Addr().Interface()
+		jobs <- decodeJob{dest, valueRows{cols, values}}
+		n++
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	capacity := n
+	if o.capacity > capacity {
+		capacity = o.capacity
+	}
+	result := reflect.MakeSlice(slice.Type(), 0, capacity)
+	for _, chunk := range chunks {
+		if result.Len()+chunk.Len() > n {
+			chunk = chunk.Slice(0, n-result.Len())
+		}
+		result = reflect.AppendSlice(result, chunk)
+	}
+	slice.Set(result)
+	return nil
+}
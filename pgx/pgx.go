@@ -0,0 +1,66 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+/*
+Package pgx adapts the rows of the pgx PostgreSQL driver to the sqlstruct package.
+
+The rows returned by pgx v5 describe their columns with FieldDescriptions rather than
+a Columns method, so they do not implement sqlstruct.Rows. Rows wraps them so that
+they can be used with Scan, SliceFromRows and the other functions consuming rows:
+
+	rows, err := conn.Query(ctx, "SELECT "+sqlstruct.Columns(User{})+" FROM users")
+	if err != nil {
+		return err
+	}
+	users, err := pgx.SliceFromRows[User](rows)
+
+The destinations of the fields are passed to pgx, which converts the column values
+as it does for database/sql types implementing sql.Scanner.
+*/
+package pgx
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/kisielk/sqlstruct"
+)
+
+// rows adapts pgx.Rows to sqlstruct.RowsIter.
+type rows struct {
+	pgx.Rows
+	columns []string
+}
+
+// Rows returns r as a sqlstruct.RowsIter. Closing the returned rows closes r.
+func Rows(r pgx.Rows) sqlstruct.RowsIter {
+	return &rows{Rows: r}
+}
+
+// Columns returns the names of the columns of r, from its field descriptions.
+func (r *rows) Columns() ([]string, error) {
+	if r.columns == nil {
+		fields := r.FieldDescriptions()
+		r.columns = make([]string, len(fields))
+		for i, f := range fields {
+			r.columns[i] = f.Name
+		}
+	}
+	return r.columns, nil
+}
+
+// Close closes the rows and returns their error, if any.
+func (r *rows) Close() error {
+	r.Rows.Close()
+	return r.Rows.Err()
+}
+
+// Scan works like sqlstruct.Scan for the current row of r.
+func Scan(dest interface{}, r pgx.Rows) error {
+	return sqlstruct.Scan(dest, Rows(r))
+}
+
+// SliceFromRows works like sqlstruct.SliceFromRows for the rows of r, which are
+// closed afterwards.
+func SliceFromRows[T any](r pgx.Rows, opts ...sqlstruct.Option) ([]T, error) {
+	return sqlstruct.SliceFromRows[T](Rows(r), opts...)
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package pgx
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeRows is a pgx.Rows holding a fixed result. Like pgx, it scans into
+// destinations implementing sql.Scanner by passing them the column value.
+type fakeRows struct {
+	pgx.Rows
+	fields []pgconn.FieldDescription
+	values [][]interface{}
+	i      int
+	closed bool
+}
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return r.fields }
+func (r *fakeRows) Next() bool                                   { r.i++; return r.i <= len(r.values) }
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) Close()                                       { r.closed = true }
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	row := r.values[r.i-1]
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destinations got %d", len(row), len(dest))
+	}
+	for i, d := range dest {
+		if s, ok := d.(sql.Scanner); ok {
+			if err := s.Scan(row[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(row[i]))
+	}
+	return nil
+}
+
+type user struct {
+	ID   int64  `sql:"id"`
+	Name string `sql:"name"`
+}
+
+func TestSliceFromRows(t *testing.T) {
+	r := &fakeRows{
+		fields: []pgconn.FieldDescription{{Name: "id"}, {Name: "extra"}, {Name: "name"}},
+		values: [][]interface{}{{int64(1), "x", "a"}, {int64(2), "y", "b"}},
+	}
+	users, err := SliceFromRows[user](r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []user{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %v got %v", expected, users)
+	}
+	if !r.closed {
+		t.Error("expected rows to be closed")
+	}
+}
+
+func TestScan(t *testing.T) {
+	r := &fakeRows{
+		fields: []pgconn.FieldDescription{{Name: "name"}},
+		values: [][]interface{}{{"a"}},
+	}
+	r.Next()
+	var u user
+	if err := Scan(&u, r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u.Name != "a" {
+		t.Errorf("unexpected result %v", u)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// scanPlan is the mapping of the columns of a result to the fields of a struct type.
+type scanPlan struct {
+	fields   []*field // the field of each column, nil for discarded columns
+	defaults []*field // fields with a default value and no column
+	err      error    // a *MappingError if some columns or fields have no counterpart
+}
+
+// planKey identifies a scanPlan by struct mapping, column alias prefix and result
+// columns.
+type planKey struct {
+	fields  fieldKey
+	prefix  string
+	columns string
+}
+
+// scanPlan returns the plan for scanning a result with the columns cols into the
+// struct type typ, whose names may start with prefix, building and caching it on
+// first use.
+func (h *Handle) scanPlan(typ reflect.Type, finfo fieldInfo, cols []string, prefix string) *scanPlan {
+	key := planKey{h.fieldKey(typ), prefix, strings.Join(cols, "\x00")}
+	if p, ok := h.plans.Load(key); ok {
+		return p.(*scanPlan)
+	}
+
+	p := &scanPlan{fields: make([]*field, len(cols))}
+	var unmapped []string
+	for i, name := range cols {
+		name = strings.TrimPrefix(name, prefix)
+		f, ok := finfo[strings.ToLower(name)]
+		if !ok || f.writeOnly {
+			// There is no field mapped to this column so we discard it
+			unmapped = append(unmapped, name)
+			continue
+		}
+		p.fields[i] = f
+	}
+	for _, name := range finfo.names() {
+		if f := finfo[name]; f.def.IsValid() && !f.writeOnly && !containsField(p.fields, f) {
+			p.defaults = append(p.defaults, f)
+		}
+	}
+	p.err = checkMapping(typ, finfo, cols, prefix, unmapped)
+
+	actual, _ := h.plans.LoadOrStore(key, p)
+	return actual.(*scanPlan)
+}
+
+// fill sets values to the scan destinations of the fields of the struct v, and sets
+// the fields with a default value and no column to their default if they are zero,
+// unless they are in a nested struct whose pointer is nil.
+// If nullToZero is true, NULL is scanned as their zero value into fields which cannot
+// hold nil and are not sql.Scanners.
+func (p *scanPlan) fill(values []interface{}, v reflect.Value, nullToZero bool) {
+	for i, f := range p.fields {
+		if f == nil {
+			values[i] = discard
+		} else {
+			values[i] = f.dest(v, nullToZero)
+		}
+	}
+	for _, f := range p.defaults {
+		if fv, ok := f.lookup(v); ok && fv.IsZero() {
+			f.setDefault(fv)
+		}
+	}
+}
+
+// containsField reports whether fields contains f.
+func containsField(fields []*field, f *field) bool {
+	for _, x := range fields {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
+
+// scanErr returns err, returned by rows.Scan for a result with the columns cols scanned
+// into the struct type typ, as a *ScanError naming the column and field which failed,
+// if database/sql reports which it was.
+func (p *scanPlan) scanErr(typ reflect.Type, cols []string, err error) error {
+	var i int
+	if _, serr := fmt.Sscanf(err.Error(), "sql: Scan error on column index %d,", &i); serr != nil {
+		return err
+	}
+	if i < 0 || i >= len(p.fields) || i >= len(cols) || p.fields[i] == nil {
+		return err
+	}
+	f := p.fields[i]
+	name := typ.Name()
+	t := typ
+	for _, x := range f.index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		sf := t.Field(x)
+		name += "." + sf.Name
+		t = sf.Type
+	}
+	cause := errors.Unwrap(err)
+	if cause == nil {
+		cause = err
+	}
+	return &ScanError{Column: cols[i], Field: name, Type: f.typ, Err: cause}
+}
+
+// ScanError is returned when a column of a result cannot be scanned into its field,
+// for example because their types do not match.
+type ScanError struct {
+	Column string       // the name of the column
+	Field  string       // the field, qualified by its struct type, as in User.CreatedAt
+	Type   reflect.Type // the type of the field
+	Err    error        // the error of the conversion
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("sqlstruct: scanning column %q into %s (%s): %v", e.Column, e.Field, e.Type, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// valuesPool holds the slices of scan destinations used by Scan, to save allocating
+// one for every row.
+var valuesPool = sync.Pool{
+	New: func() interface{} { return new([]interface{}) },
+}
+
+// getValues returns a slice of n scan destinations from valuesPool.
+func getValues(n int) *[]interface{} {
+	vp := valuesPool.Get().(*[]interface{})
+	if cap(*vp) < n {
+		*vp = make([]interface{}, n)
+	}
+	*vp = (*vp)[:n]
+	return vp
+}
+
+// putValues returns a slice obtained from getValues to valuesPool. The slice is
+// cleared first so that it does not keep the scanned struct alive.
+func putValues(vp *[]interface{}) {
+	clear(*vp)
+	valuesPool.Put(vp)
+}
+
+// Plan scans the rows of a single result into values of T. The columns of the result
+// are matched to the fields of T once, when the Plan is created, which makes it
+// cheaper than Scan in loops over large results. For example:
+//
+//	plan, err := sqlstruct.PlanFor[User](rows)
+//	...
+//	for rows.Next() {
+//		var u User
+//		if err := plan.Scan(&u); err != nil {
+//			...
+//		}
+//	}
+//
+// A Plan is not safe for concurrent use.
+type Plan[T any] struct {
+	rows   Rows
+	plan   *scanPlan
+	cols   []string
+	values []interface{}
+}
+
+// PlanFor returns a Plan scanning the current result of rows into values of T.
+func PlanFor[T any](rows Rows) (*Plan[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqlstruct: %v is not a struct type", typ)
+	}
+	finfo, err := defaultHandle.getFieldInfo(typ)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	return &Plan[T]{
+		rows:   rows,
+		plan:   defaultHandle.scanPlan(typ, finfo, cols, ""),
+		cols:   cols,
+		values: make([]interface{}, len(cols)),
+	}, nil
+}
+
+// Scan scans the current row into dest, which must not be nil.
+func (p *Plan[T]) Scan(dest *T) error {
+	p.plan.fill(p.values, reflect.ValueOf(dest).Elem(), false)
+	if err := p.rows.Scan(p.values...); err != nil {
+		return p.plan.scanErr(reflect.TypeOf(dest).Elem(), p.cols, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanFor(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "extra", "name"},
+		[]driver.Value{int64(1), "x", "a"},
+		[]driver.Value{int64(2), "y", "b"},
+	)
+
+	rows, err := sqldb.Query("SELECT id, extra, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rows.Close()
+
+	plan, err := PlanFor[testUser](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var users []testUser
+	for rows.Next() {
+		var u testUser
+		if err := plan.Scan(&u); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		users = append(users, u)
+	}
+	expected := []testUser{{1, "a"}, {2, "b"}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %v got %v", expected, users)
+	}
+
+	if _, err := PlanFor[int](rows); err == nil {
+		t.Error("expected error for non-struct type")
+	}
+}
+
+func TestScanPlanCache(t *testing.T) {
+	h := &Handle{}
+	typ := reflect.TypeOf(testUser{})
+	finfo, err := h.getFieldInfo(typ)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	p := h.scanPlan(typ, finfo, []string{"id", "name"}, "")
+	if p.err != nil {
+		t.Errorf("unexpected mapping error: %s", p.err)
+	}
+	if h.scanPlan(typ, finfo, []string{"id", "name"}, "") != p {
+		t.Error("expected cached plan to be reused")
+	}
+
+	p = h.scanPlan(typ, finfo, []string{"u_id", "u_other"}, "u_")
+	if p.fields[0] == nil || p.fields[1] != nil {
+		t.Errorf("unexpected plan fields %v", p.fields)
+	}
+	if p.err == nil || !strings.Contains(p.err.Error(), "other") {
+		t.Errorf("expected mapping error, got %v", p.err)
+	}
+}
+
+func TestValuesPool(t *testing.T) {
+	vp := getValues(3)
+	if len(*vp) != 3 {
+		t.Fatalf("expected 3 values got %d", len(*vp))
+	}
+	(*vp)[0] = discard
+	putValues(vp)
+	for i, v := range (*vp)[:3] {
+		if v != nil {
+			t.Errorf("value %d was not cleared: %v", i, v)
+		}
+	}
+}
+
+func TestScanError(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{"one", "a"})
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rows.Close()
+	rows.Next()
+	var u testUser
+	err = Scan(&u, rows)
+	var serr *ScanError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *ScanError got %v", err)
+	}
+	if serr.Column != "id" || serr.Field != "testUser.Id" || serr.Type != reflect.TypeOf(0) {
+		t.Errorf("unexpected error %+v", serr)
+	}
+	if prefix := `sqlstruct: scanning column "id" into testUser.Id (int): `; !strings.HasPrefix(err.Error(), prefix) {
+		t.Errorf("expected prefix %q got %q", prefix, err)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+/*
+Package prometheus exports the metrics of the sqlstruct package to Prometheus.
+
+A single call registers the metrics with the default registry and starts collecting
+them:
+
+	if _, err := prometheus.Register(); err != nil {
+		log.Fatal(err)
+	}
+
+Statements are labelled with the name given by sqlstruct.QueryName or
+sqlstruct.ContextWithQueryName, or an empty name if they were not named.
+*/
+package prometheus
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/kisielk/sqlstruct"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a sqlstruct.MetricsCollector which records its measurements as
+// Prometheus metrics. It is also a prometheus.Collector.
+type Collector struct {
+	duration     *prometheus.HistogramVec
+	inFlight     *prometheus.GaugeVec
+	rows         *prometheus.CounterVec
+	scanErrors   *prometheus.CounterVec
+	cacheLookups *prometheus.CounterVec
+}
+
+// New returns a new Collector. Its metrics are only exported once it has been
+// registered with a prometheus.Registerer, and only record measurements once it has
+// been set as sqlstruct.Metrics.
+func New() *Collector {
+	return &Collector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sqlstruct",
+			Name:      "query_duration_seconds",
+			Help:      "Time taken to execute statements and scan their rows.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"query", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "sqlstruct",
+			Name:      "queries_in_flight",
+			Help:      "Number of statements currently executing.",
+		}, []string{"query"}),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sqlstruct",
+			Name:      "rows_total",
+			Help:      "Number of rows read or affected by statements.",
+		}, []string{"query"}),
+		scanErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sqlstruct",
+			Name:      "scan_errors_total",
+			Help:      "Number of rows which could not be scanned, by destination type.",
+		}, []string{"type"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sqlstruct",
+			Name:      "field_cache_lookups_total",
+			Help:      "Number of lookups in the field mapping cache, by result.",
+		}, []string{"result"}),
+	}
+}
+
+// Register creates a Collector, registers it with prometheus.DefaultRegisterer and
+// sets it as sqlstruct.Metrics.
+func Register() (*Collector, error) {
+	c := New()
+	if err := prometheus.Register(c); err != nil {
+		return nil, err
+	}
+	sqlstruct.Metrics = c
+	return c, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.duration.Describe(ch)
+	c.inFlight.Describe(ch)
+	c.rows.Describe(ch)
+	c.scanErrors.Describe(ch)
+	c.cacheLookups.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.duration.Collect(ch)
+	c.inFlight.Collect(ch)
+	c.rows.Collect(ch)
+	c.scanErrors.Collect(ch)
+	c.cacheLookups.Collect(ch)
+}
+
+// QueryStarted implements sqlstruct.MetricsCollector.
+func (c *Collector) QueryStarted(ctx context.Context, name string) {
+	c.inFlight.WithLabelValues(name).Inc()
+}
+
+// QueryFinished implements sqlstruct.MetricsCollector.
+func (c *Collector) QueryFinished(ctx context.Context, name string, d time.Duration, rows int64, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	c.inFlight.WithLabelValues(name).Dec()
+	c.duration.WithLabelValues(name, status).Observe(d.Seconds())
+	c.rows.WithLabelValues(name).Add(float64(rows))
+}
+
+// ScanFailed implements sqlstruct.MetricsCollector.
+func (c *Collector) ScanFailed(typ reflect.Type, err error) {
+	c.scanErrors.WithLabelValues(typ.String()).Inc()
+}
+
+// FieldCacheLookup implements sqlstruct.MetricsCollector.
+func (c *Collector) FieldCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.cacheLookups.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,49 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := New()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx := context.Background()
+	c.QueryStarted(ctx, "users")
+	c.QueryFinished(ctx, "users", time.Millisecond, 3, nil)
+	c.QueryStarted(ctx, "users")
+	c.QueryFinished(ctx, "users", time.Millisecond, 0, errors.New("boom"))
+	c.ScanFailed(reflect.TypeOf(0), errors.New("boom"))
+	c.FieldCacheLookup(true)
+	c.FieldCacheLookup(true)
+	c.FieldCacheLookup(false)
+
+	if n := testutil.ToFloat64(c.rows.WithLabelValues("users")); n != 3 {
+		t.Errorf("expected 3 rows got %v", n)
+	}
+	if n := testutil.ToFloat64(c.inFlight.WithLabelValues("users")); n != 0 {
+		t.Errorf("expected 0 queries in flight got %v", n)
+	}
+	if n := testutil.ToFloat64(c.scanErrors.WithLabelValues("int")); n != 1 {
+		t.Errorf("expected 1 scan error got %v", n)
+	}
+	if n := testutil.ToFloat64(c.cacheLookups.WithLabelValues("hit")); n != 2 {
+		t.Errorf("expected 2 cache hits got %v", n)
+	}
+	if n := testutil.CollectAndCount(c, "sqlstruct_query_duration_seconds"); n != 2 {
+		t.Errorf("expected 2 duration series got %d", n)
+	}
+}
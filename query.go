@@ -0,0 +1,833 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// QueryReplace is the token in queries passed to Query and QueryRow that is
+// replaced by the columns of the result type. Only the first occurrence is replaced.
+var QueryReplace = "*"
+
+// TableReplace is the token in queries passed to Query and QueryRow that is replaced
+// by the quoted table name of the result type, as used by Insert and Count. Every
+// occurrence is replaced, so that queries need not repeat table names which may drift
+// from their types:
+//
+//	users, err := sqlstruct.Query[User]("SELECT * FROM {table} WHERE active")
+//
+// If the type has a field tagged with the "softdelete" option, a {table} following
+// FROM or JOIN selects only the rows which are not soft deleted, unless the Unscoped
+// option is given. Table names written out in queries are left as they are.
+var TableReplace = "{table}"
+
+// Queryer is the interface used to run queries and statements.
+// It is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	Execer
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+var (
+	_ Queryer = (*sql.DB)(nil)
+	_ Queryer = (*sql.Tx)(nil)
+	_ Queryer = (*sql.Conn)(nil)
+)
+
+// expandQuery replaces QueryReplace in query with the columns of typ.
+func (h *Handle) expandQuery(query string, typ reflect.Type) (string, error) {
+	cols, err := h.columnList(typ)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(query, h.queryReplace(), cols, 1), nil
+}
+
+// expandTable replaces every occurrence of TableReplace in query with the quoted
+// table name of typ. If typ has a field tagged with the "softdelete" option and o is
+// not unscoped, the occurrences which follow FROM or JOIN, other than in DELETE FROM,
+// are replaced by a subquery selecting the rows which are not soft deleted, aliased
+// as the table unless the query gives an alias.
+func (o options) expandTable(query string, typ reflect.Type) (string, error) {
+	if TableReplace == "" || !strings.Contains(query, TableReplace) {
+		return query, nil
+	}
+	h := o.handleOf()
+	table := h.quotedTable(typ)
+	if o.unscoped {
+		return strings.ReplaceAll(query, TableReplace, table), nil
+	}
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return "", err
+	}
+	live := h.notDeleted(fields)
+	if live == "" {
+		return strings.ReplaceAll(query, TableReplace, table), nil
+	}
+	_, name := splitTable(h.tableName(typ))
+	source := "(SELECT * FROM " + table + " WHERE " + live + ")"
+
+	var b strings.Builder
+	for {
+		i := strings.Index(query, TableReplace)
+		if i < 0 {
+			break
+		}
+		before, after := query[:i], query[i+len(TableReplace):]
+		b.WriteString(before)
+		switch {
+		case !tableSource.MatchString(before) || deleteFrom.MatchString(before):
+			b.WriteString(table)
+		case hasAlias(after):
+			b.WriteString(source)
+		default:
+			b.WriteString(source + " AS " + h.quote(name))
+		}
+		query = after
+	}
+	b.WriteString(query)
+	return b.String(), nil
+}
+
+var (
+	tableSource = regexp.MustCompile(`(?i)\b(FROM|JOIN)\s+$`)
+	deleteFrom  = regexp.MustCompile(`(?i)\bDELETE\s+FROM\s+$`)
+	nextWord    = regexp.MustCompile(`^\s+([A-Za-z_][A-Za-z0-9_]*|"[^"]*"|` + "`[^`]*`" + `)`)
+)
+
+// clauseWords are the keywords which may follow a table in a FROM clause and which
+// are not aliases.
+var clauseWords = map[string]bool{
+	"WHERE": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "CROSS": true, "NATURAL": true, "ON": true, "USING": true,
+	"GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true, "OFFSET": true,
+	"FETCH": true, "UNION": true, "EXCEPT": true, "INTERSECT": true,
+	"WINDOW": true, "FOR": true, "RETURNING": true, "SET": true,
+}
+
+// hasAlias reports whether the part of a query following a table in a FROM clause
+// starts with an alias for it.
+func hasAlias(rest string) bool {
+	m := nextWord.FindStringSubmatch(rest)
+	return m != nil && !clauseWords[strings.ToUpper(m[1])]
+}
+
+// expand replaces QueryReplace in query with the columns of typ, or only those of
+// the fields given to the Only option, and TableReplace with the table of typ, as
+// described for expandTable.
+func (o options) expand(query string, typ reflect.Type) (string, error) {
+	h := o.handleOf()
+	query, err := o.expandTable(query, typ)
+	if err != nil {
+		return "", err
+	}
+	if o.only == nil {
+		return h.expandQuery(query, typ)
+	}
+	cols, err := h.fieldColumns(typ, o.only)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(query, h.queryReplace(), strings.Join(h.quoteAll(cols), ", "), 1), nil
+}
+
+// Query executes query on the database set with SetDatabase and returns its rows
+// scanned into a slice of T. The first occurrence of QueryReplace in query is replaced
+// by the columns of T, for example:
+//
+//	users, err := sqlstruct.Query[User]("SELECT * FROM users WHERE active = ?", true)
+//
+// Options may be given among args to change how the query is run, for example:
+//
+//	users, err := sqlstruct.Query[User]("SELECT * FROM users", sqlstruct.Timeout(time.Second), sqlstruct.MaxRows(1000))
+func Query[T any](query string, args ...interface{}) ([]T, error) {
+	return QueryContext[T](context.Background(), query, args...)
+}
+
+// QueryContext works like Query but runs the query with ctx. If ctx is canceled or its
+// deadline passes, the query is canceled and the scanning of its rows is stopped.
+func QueryContext[T any](ctx context.Context, query string, args ...interface{}) ([]T, error) {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	query, err := o.expand(query, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	var result []T
+	err = o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		var err error
+		result, err = scanRows[T](ctx, rows, o)
+		return int64(len(result)), err
+	})
+	return result, err
+}
+
+// MustQuery is like Query but panics if the query fails. It is intended for tests,
+// tools and setup code where there is no sensible way to handle errors.
+func MustQuery[T any](query string, args ...interface{}) []T {
+	result, err := Query[T](query, args...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Page is a page of the results of a query returned by QueryPage.
+type Page[T any] struct {
+	Items      []T   // the rows of the page
+	TotalCount int64 // the number of rows of the whole query
+	HasNext    bool  // whether there are rows after this page
+}
+
+// QueryPage works like QueryContext but returns only the rows of page, counting from
+// 1, with perPage rows per page. The total number of rows selected by query is
+// counted by a second query, for example:
+//
+//	page, err := sqlstruct.QueryPage[User](ctx, "SELECT * FROM users WHERE active = ? ORDER BY name", 3, 50, true)
+//
+// This selects the users with LIMIT 50 OFFSET 100, or its equivalent in the dialect in
+// use, and counts them with SELECT COUNT(*) FROM (...) over the same query, without
+// its final ORDER BY clause. The query should have an ORDER BY clause so that the
+// pages are stable, and must have one for SQLServer.
+func QueryPage[T any](ctx context.Context, query string, page, perPage int, args ...interface{}) (Page[T], error) {
+	var p Page[T]
+	if page < 1 || perPage < 1 {
+		return p, fmt.Errorf("sqlstruct: QueryPage page and perPage must be positive; got %d and %d", page, perPage)
+	}
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	query, err := o.expand(query, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return p, err
+	}
+	query = strings.TrimRight(query, "; \t\r\n")
+	offset := (page - 1) * perPage
+
+	err = o.run(ctx, "SELECT COUNT(*) FROM ("+trimOrderBy(query)+") page", args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error { return rows.Scan(&p.TotalCount) })
+	})
+	if err != nil {
+		return p, err
+	}
+	err = o.run(ctx, query+o.dialectOf().limit(perPage, offset), args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		var err error
+		p.Items, err = scanRows[T](ctx, rows, o)
+		return int64(len(p.Items)), err
+	})
+	if err != nil {
+		return Page[T]{}, err
+	}
+	p.HasNext = int64(offset+len(p.Items)) < p.TotalCount
+	return p, nil
+}
+
+var (
+	orderBy    = regexp.MustCompile(`(?i)^ORDER\s+BY\b`)
+	pageClause = regexp.MustCompile(`(?i)\b(LIMIT|OFFSET|FETCH)\b`)
+)
+
+// trimOrderBy removes the ORDER BY clause ending query, which SQLServer rejects in
+// derived tables and which does not change the number of rows. Clauses followed by
+// LIMIT, OFFSET or FETCH are kept, since they select the rows which are counted.
+func trimOrderBy(query string) string {
+	depth, last := 0, -1
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'', '"', '`', '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(query[i+1:], end)
+			if j == -1 {
+				return query
+			}
+			i += j + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case 'o', 'O':
+			if depth == 0 && (i == 0 || !isWordByte(query[i-1])) && orderBy.MatchString(query[i:]) {
+				last = i
+			}
+		}
+	}
+	if last == -1 || pageClause.MatchString(query[last:]) {
+		return query
+	}
+	return strings.TrimRight(query[:last], " \t\r\n")
+}
+
+// isWordByte reports whether c may be part of an SQL keyword or identifier.
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// Count returns the number of rows of T's table matching the condition where, or of
+// all its rows if where is empty. For example:
+//
+//	n, err := sqlstruct.Count[User](ctx, "active = ?", true)
+//
+// As with Query, options may be given among args. Rows which are soft deleted, as
+// described for Delete, are not counted unless the Unscoped option is given.
+func Count[T any](ctx context.Context, where string, args ...interface{}) (int64, error) {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	from, err := o.tableWhere(reflect.TypeOf((*T)(nil)).Elem(), where)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = o.run(ctx, "SELECT COUNT(*) FROM "+from, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error { return rows.Scan(&n) })
+	})
+	return n, err
+}
+
+// Exists reports whether any row of T's table matches the condition where, or whether
+// the table has any rows if where is empty. The query selects at most one row. Like
+// Count, it ignores soft-deleted rows unless the Unscoped option is given.
+func Exists[T any](ctx context.Context, where string, args ...interface{}) (bool, error) {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	from, err := o.tableWhere(reflect.TypeOf((*T)(nil)).Elem(), where)
+	if err != nil {
+		return false, err
+	}
+	var found bool
+	err = o.run(ctx, o.dialectOf().limitOne("SELECT 1 FROM "+from), args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		if found = rows.Next(); found {
+			return 1, nil
+		}
+		return 0, rows.Err()
+	})
+	return found, err
+}
+
+// FindByID returns the row of T's table whose primary key is id, or ErrNoRows if
+// there is none. T must have a single primary key field. For example:
+//
+//	user, err := sqlstruct.FindByID[User](ctx, 42)
+//
+// Like Count, it ignores soft-deleted rows unless the Unscoped option is given.
+func FindByID[T any](ctx context.Context, id interface{}, opts ...Option) (T, error) {
+	var t T
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := applyOptions(opts).handleOf().getFieldInfo(typ)
+	if err != nil {
+		return t, err
+	}
+	pks := fields.primaryKeys()
+	if len(pks) != 1 {
+		return t, fmt.Errorf("sqlstruct: FindByID requires %v to have a single primary key field; it has %d", typ, len(pks))
+	}
+	return FindBy[T](ctx, map[string]interface{}{pks[0].name: id}, opts...)
+}
+
+// FindBy returns the first row of T's table whose columns have the values in keys, or
+// ErrNoRows if there is none. A nil value matches NULL. For example:
+//
+//	user, err := sqlstruct.FindBy[User](ctx, map[string]interface{}{"email": email})
+//
+// The keys must be columns of T. Like FindByID, it ignores soft-deleted rows unless
+// the Unscoped option is given.
+func FindBy[T any](ctx context.Context, keys map[string]interface{}, opts ...Option) (T, error) {
+	var t T
+	o := applyOptions(opts)
+	h := o.handleOf()
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return t, err
+	}
+	if len(keys) == 0 {
+		return t, errors.New("sqlstruct: FindBy requires at least one key")
+	}
+
+	conds := make([]string, 0, len(keys))
+	var args []interface{}
+	for _, name := range sortedKeys(keys) {
+		f, ok := fields[name]
+		if !ok {
+			f, ok = fields[strings.ToLower(name)]
+		}
+		if !ok {
+			return t, fmt.Errorf("sqlstruct: %v has no column %q", typ, name)
+		}
+		if keys[name] == nil {
+			conds = append(conds, h.quote(f.name)+" IS NULL")
+			continue
+		}
+		conds = append(conds, h.quote(f.name)+" = ?")
+		args = append(args, keys[name])
+	}
+	from, err := o.tableWhere(typ, strings.Join(conds, " AND "))
+	if err != nil {
+		return t, err
+	}
+	cols, err := h.columnList(typ)
+	if err != nil {
+		return t, err
+	}
+	err = o.run(ctx, o.dialectOf().limitOne("SELECT "+cols+" FROM "+from), args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error { return o.scan(ctx, &t, rows) })
+	})
+	return t, err
+}
+
+// tableWhere returns the table of typ followed by a WHERE clause with the condition
+// where, if it is not empty. Soft-deleted rows are excluded unless o is unscoped.
+func (o options) tableWhere(typ reflect.Type, where string) (string, error) {
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return "", err
+	}
+	from := h.quotedTable(typ)
+	live := h.notDeleted(fields)
+	switch {
+	case live == "" || o.unscoped:
+		if where != "" {
+			from += " WHERE " + where
+		}
+	case where == "":
+		from += " WHERE " + live
+	default:
+		from += " WHERE " + live + " AND (" + where + ")"
+	}
+	return from, nil
+}
+
+// QueryOn works like Query but runs the query on q, for example within a transaction:
+//
+//	tx, err := db.Begin()
+//	...
+//	users, err := sqlstruct.QueryOn[User](tx, "SELECT * FROM users WHERE active = ?", true)
+func QueryOn[T any](q Queryer, query string, args ...interface{}) ([]T, error) {
+	return QueryContext[T](context.Background(), query, withExecutor(q, args)...)
+}
+
+// ErrNoRows is returned by QueryRow and Get when the query selects no rows. It is
+// sql.ErrNoRows, so errors may be compared with either.
+var ErrNoRows = sql.ErrNoRows
+
+// QueryRow works like Query but scans only the first row of the result. It returns
+// ErrNoRows if the query selects no rows.
+//
+// Unless the query already limits its results, QueryRow adds a LIMIT 1 clause (or its
+// equivalent in DefaultDialect) so that no more than one row is sent by the database.
+func QueryRow[T any](query string, args ...interface{}) (T, error) {
+	return QueryRowContext[T](context.Background(), query, args...)
+}
+
+// QueryRowContext works like QueryRow but runs the query with ctx.
+func QueryRowContext[T any](ctx context.Context, query string, args ...interface{}) (T, error) {
+	var t T
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	query, err := o.expand(query, reflect.TypeOf(t))
+	if err != nil {
+		return t, err
+	}
+	err = o.run(ctx, o.dialectOf().limitOne(query), args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error { return o.scan(ctx, &t, rows) })
+	})
+	return t, err
+}
+
+// firstRow scans the first row of rows with scan. It returns ErrNoRows if there are
+// no rows, and any error reported by rows after the scan.
+func firstRow(rows *sql.Rows, scan func() error) (int64, error) {
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, ErrNoRows
+	}
+	if err := scan(); err != nil {
+		return 0, err
+	}
+	return 1, rows.Err()
+}
+
+// MustQueryRow is like QueryRow but panics if the query fails, including when it
+// selects no rows.
+func MustQueryRow[T any](query string, args ...interface{}) T {
+	t, err := QueryRow[T](query, args...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// QueryRowOn works like QueryRow but runs the query on q.
+func QueryRowOn[T any](q Queryer, query string, args ...interface{}) (T, error) {
+	return QueryRowContext[T](context.Background(), query, withExecutor(q, args)...)
+}
+
+// Get returns the single value selected by query. If T is a struct, it works like
+// QueryRow. Otherwise the first column of the first row is scanned into a T, which
+// suits counts, ids and other scalar results:
+//
+//	n, err := sqlstruct.Get[int64]("SELECT count(*) FROM users WHERE active = ?", true)
+//
+// QueryReplace is not replaced in scalar queries. Get returns ErrNoRows if the
+// query selects no rows. Struct types which scan themselves, such as time.Time or
+// sql.NullString, are treated as scalars.
+func Get[T any](query string, args ...interface{}) (T, error) {
+	return GetContext[T](context.Background(), query, args...)
+}
+
+// GetContext works like Get but runs the query with ctx.
+func GetContext[T any](ctx context.Context, query string, args ...interface{}) (T, error) {
+	var t T
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Struct && typ != timeType && !isScanner(typ) {
+		return QueryRowContext[T](ctx, query, args...)
+	}
+
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+	err := o.run(ctx, o.dialectOf().limitOne(query), args, func(_ context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error {
+			cols, err := rows.Columns()
+			if err != nil {
+				return err
+			}
+			if len(cols) == 0 {
+				return errors.New("sqlstruct: Get result has no columns")
+			}
+			dest := make([]interface{}, len(cols))
+			dest[0] = &t
+			for i := 1; i < len(dest); i++ {
+				dest[i] = discard
+			}
+			return rows.Scan(dest...)
+		})
+	})
+	return t, err
+}
+
+// QueryMapRows works like Query but passes each row to fn as soon as it has been
+// scanned, and returns the results of fn instead of the rows. It avoids holding
+// all of the rows in memory when only a projection of them is needed.
+func QueryMapRows[T, U any](query string, fn func(T) U, args ...interface{}) ([]U, error) {
+	return QueryMapRowsContext(context.Background(), query, fn, args...)
+}
+
+// QueryMapRowsContext works like QueryMapRows but runs the query with ctx.
+func QueryMapRowsContext[T, U any](ctx context.Context, query string, fn func(T) U, args ...interface{}) ([]U, error) {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	query, err := o.expand(query, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	var result []U
+	err = o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		result = make([]U, 0, o.capacity)
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				return int64(len(result)), err
+			}
+			if o.maxRows > 0 && len(result) >= o.maxRows {
+				return int64(len(result)), ErrMaxRows
+			}
+			var t T
+			if err := o.scan(ctx, &t, rows); err != nil {
+				return int64(len(result)), err
+			}
+			result = append(result, fn(t))
+		}
+		return int64(len(result)), rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// totalColumn is the name of the column added by QueryWithTotal.
+const totalColumn = "__total"
+
+// QueryWithTotal works like Query but also returns the total number of rows the
+// query would select without any LIMIT or OFFSET clause, which is useful for
+// paginated listings. The total is computed in the same statement by adding a
+// count(*) OVER() window column next to the columns of T, so the query must contain
+// QueryReplace and the database must support window functions.
+//
+// If the query selects no rows, for example because the offset is past the end of
+// the results, the total is reported as 0.
+func QueryWithTotal[T any](query string, args ...interface{}) ([]T, int64, error) {
+	return QueryWithTotalContext[T](context.Background(), query, args...)
+}
+
+// QueryWithTotalContext works like QueryWithTotal but runs the query with ctx.
+func QueryWithTotalContext[T any](ctx context.Context, query string, args ...interface{}) ([]T, int64, error) {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	h := o.handleOf()
+	cols, err := h.columnList(typ)
+	if err != nil {
+		return nil, 0, err
+	}
+	if query, err = o.expandTable(query, typ); err != nil {
+		return nil, 0, err
+	}
+	query = strings.Replace(query, h.queryReplace(), cols+", count(*) OVER() AS "+totalColumn, 1)
+
+	tr := &totalRows{}
+	var result []T
+	err = o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		tr.rows = rows
+		result = make([]T, 0, o.capacity)
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				return int64(len(result)), err
+			}
+			if o.maxRows > 0 && len(result) >= o.maxRows {
+				return int64(len(result)), ErrMaxRows
+			}
+			var t T
+			if err := o.scan(ctx, &t, tr); err != nil {
+				return int64(len(result)), err
+			}
+			result = append(result, t)
+		}
+		return int64(len(result)), rows.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, tr.total, nil
+}
+
+// totalRows hides the column added by QueryWithTotal from Scan and stores its value.
+type totalRows struct {
+	rows  *sql.Rows
+	total int64
+}
+
+func (r *totalRows) Columns() ([]string, error) {
+	cols, err := r.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 || cols[len(cols)-1] != totalColumn {
+		return nil, fmt.Errorf("sqlstruct: query result has no %s column", totalColumn)
+	}
+	return cols[:len(cols)-1], nil
+}
+
+func (r *totalRows) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, &r.total)...)
+}
+
+// SliceFromRows scans all remaining rows into a slice of T and closes rows.
+func SliceFromRows[T any](rows RowsIter, opts ...Option) ([]T, error) {
+	return SliceFromRowsContext[T](context.Background(), rows, opts...)
+}
+
+// SliceFromRowsContext works like SliceFromRows but stops scanning and returns the
+// error of ctx if ctx is canceled or its deadline passes. To also cancel the query in
+// the database, rows should come from a query run with the same context.
+func SliceFromRowsContext[T any](ctx context.Context, rows RowsIter, opts ...Option) ([]T, error) {
+	defer rows.Close()
+	o := applyOptions(opts)
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+	return scanRows[T](ctx, rows, o)
+}
+
+// SliceFromRowsFiltered works like SliceFromRows but only keeps the rows for which
+// keep returns true. keep is called with each row as soon as it has been scanned, so
+// rows which are discarded are never added to the result.
+func SliceFromRowsFiltered[T any](rows RowsIter, keep func(*T) bool, opts ...Option) ([]T, error) {
+	defer rows.Close()
+	o := applyOptions(opts)
+
+	result := make([]T, 0, o.capacity)
+	for rows.Next() {
+		var t T
+		if err := o.scan(context.Background(), &t, rows); err != nil {
+			return nil, err
+		}
+		if !keep(&t) {
+			continue
+		}
+		if o.maxRows > 0 && len(result) >= o.maxRows {
+			return nil, ErrMaxRows
+		}
+		result = append(result, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PointerSliceFromRows works like SliceFromRows but returns pointers to the scanned
+// values, which are not copied as the slice grows and can be modified in place.
+func PointerSliceFromRows[T any](rows RowsIter, opts ...Option) ([]*T, error) {
+	var result []*T
+	if err := ScanAll(&result, rows, opts...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ScanAll scans all remaining rows of rows and appends them to the slice dest points
+// to, then closes rows. dest must be a *[]T or a *[]*T for a struct type T; with
+// *[]*T the rows are scanned into Ts allocated together in blocks of up to 256, sized
+// by the Capacity option if it is given. If an error occurs, the rows scanned so far
+// are kept in dest.
+func ScanAll(dest interface{}, rows RowsIter, opts ...Option) error {
+	defer rows.Close()
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("sqlstruct: dest must be pointer to slice of structs or struct pointers; got %T", dest)
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+	ptrs := elemType.Kind() == reflect.Ptr
+	if ptrs {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("sqlstruct: dest must be pointer to slice of structs or struct pointers; got %T", dest)
+	}
+	o := applyOptions(opts)
+	if o.capacity > 0 {
+		slice.Grow(o.capacity)
+	}
+
+	var block reflect.Value
+	size, used := 0, 0
+	n := 0
+	for rows.Next() {
+		if o.maxRows > 0 && n >= o.maxRows {
+			return ErrMaxRows
+		}
+		var v reflect.Value
+		if ptrs {
+			if used == size {
+				size = nextBlock(size, o.capacity-n)
+				block, used = reflect.MakeSlice(reflect.SliceOf(elemType), size, size), 0
+			}
+			v = block.Index(used).Addr()
+		} else {
+			slice.Set(reflect.Append(slice, reflect.Zero(elemType)))
+			v = slice.Index(slice.Len() - 1).Addr()
+		}
+		if err := o.scan(context.Background(), v.Interface(), rows); err != nil {
+			if !ptrs {
+				slice.SetLen(slice.Len() - 1)
+			}
+			return err
+		}
+		if ptrs {
+			slice.Set(reflect.Append(slice, v))
+			used++
+		}
+		n++
+	}
+	return rows.Err()
+}
+
+// nextBlock returns the number of structs to allocate together for the rows scanned
+// into a []*T by ScanAll, given the size of the previous block and the number of rows
+// still expected from the Capacity option. Without it, blocks double in size from 8 up
+// to decodeChunk.
+func nextBlock(prev, expected int) int {
+	size := 2 * prev
+	if expected > size {
+		size = expected
+	}
+	if size < 8 && expected <= 0 {
+		size = 8
+	}
+	if size > decodeChunk {
+		size = decodeChunk
+	}
+	return size
+}
+
+// Reduce scans the remaining rows into values of T one at a time and combines them
+// with fn, starting from initial, then closes rows. Only one row is held in memory at
+// a time, so it can aggregate result sets too large to load at once. For example:
+//
+//	total, err := sqlstruct.Reduce(rows, 0.0, func(sum float64, o Order) float64 {
+//		return sum + o.Amount
+//	})
+func Reduce[T, A any](rows RowsIter, initial A, fn func(A, T) A) (A, error) {
+	defer rows.Close()
+
+	acc := initial
+	for rows.Next() {
+		var t T
+		if err := Scan(&t, rows); err != nil {
+			return acc, err
+		}
+		acc = fn(acc, t)
+	}
+	return acc, rows.Err()
+}
+
+// scanRows scans all remaining rows into a slice of T, stopping if ctx is done. See
+// scanSlice.
+func scanRows[T any](ctx context.Context, rows RowsIter, o options) ([]T, error) {
+	var result []T
+	if err := scanSlice(ctx, rows, o, reflect.ValueOf(&result).Elem()); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanSlice scans the rows into a new slice of the type of slice, a settable slice of
+// structs, and sets slice to it if all of the rows were scanned. It implements the
+// scanning of Query and Handle.Query, including the Capacity, MaxRows and Workers
+// options.
+func scanSlice(ctx context.Context, rows RowsIter, o options, slice reflect.Value) error {
+	if o.workers > 1 {
+		return scanSliceParallel(ctx, rows, o, slice)
+	}
+
+	elemType := slice.Type().Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, o.capacity)
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if o.maxRows > 0 && result.Len() >= o.maxRows {
+			return ErrMaxRows
+		}
+		result = reflect.Append(result, reflect.Zero(elemType))
+		if err := o.scan(ctx, result.Index(result.Len()-1).Addr().Interface(), rows); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	slice.Set(result)
+	return nil
+}
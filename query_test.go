@@ -0,0 +1,674 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// setFakeDatabase installs a new fakeDB as the database used by Query and QueryRow.
+func setFakeDatabase(t *testing.T) *fakeDB {
+	sqldb, fake := newFakeDB(t)
+	old := defaultHandle.db
+	SetDatabase(sqldb)
+	t.Cleanup(func() { SetDatabase(old) })
+	return fake
+}
+
+func TestQuery(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	users, err := Query[testUser]("SELECT * FROM users WHERE id > ?", 0, Capacity(10))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []testUser{{1, "a"}, {2, "b"}}
+	if len(users) != len(expected) || users[0] != expected[0] || users[1] != expected[1] {
+		t.Errorf("expected %v got %v", expected, users)
+	}
+	if cap(users) != 10 {
+		t.Errorf("expected capacity 10 got %d", cap(users))
+	}
+	if q := "SELECT id, name FROM users WHERE id > ?"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	if len(fake.args[0]) != 1 {
+		t.Errorf("expected options to be removed from args, got %v", fake.args[0])
+	}
+}
+
+func TestQueryContext(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := defaultHandle.db.QueryContext(ctx, "SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cancel()
+	if _, err := SliceFromRowsContext[testUser](ctx, rows); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+
+	if _, err := QueryContext[testUser](ctx, "SELECT * FROM users"); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+	if _, err := QueryRowContext[testUser](ctx, "SELECT * FROM users"); err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestQueryOnly(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"name"}, []driver.Value{"a"})
+
+	users, err := Query[testUser]("SELECT * FROM users", Only("Name"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 1 || users[0] != (testUser{Name: "a"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+	if q := "SELECT name FROM users"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	if _, err := Query[testUser]("SELECT * FROM users", Only("Missing")); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+type testTaggedTable struct {
+	Table `sql:"tagged"`
+	Id    int `sql:"id"`
+}
+
+func TestTableReplace(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	if _, err := Query[testUser]("SELECT * FROM {table} WHERE id IN (SELECT id FROM {table})"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "SELECT id, name FROM users WHERE id IN (SELECT id FROM users)"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+
+	fake.setRows([]string{"id"}, []driver.Value{int64(2)})
+	rows, err := Query[testTaggedTable]("SELECT * FROM {table}")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "SELECT id FROM tagged"; fake.queries[1] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[1])
+	}
+	if len(rows) != 1 || rows[0].Id != 2 {
+		t.Errorf("unexpected result %v", rows)
+	}
+}
+
+func TestQueryRow(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	user, err := QueryRow[testUser]("SELECT * FROM users WHERE id = ?", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := (testUser{1, "a"}); user != expected {
+		t.Errorf("expected %v got %v", expected, user)
+	}
+	if q := "SELECT id, name FROM users WHERE id = ? LIMIT 1"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+
+	fake.setRows([]string{"id", "name"})
+	if _, err := QueryRow[testUser]("SELECT * FROM users WHERE id = ?", 2); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows got %v", err)
+	}
+	if _, err := Get[int64]("SELECT count(*) FROM users WHERE id = ?", 2); err != ErrNoRows {
+		t.Errorf("expected ErrNoRows got %v", err)
+	}
+}
+
+func TestMustQuery(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	if users := MustQuery[testUser]("SELECT * FROM users"); len(users) != 1 || users[0] != (testUser{1, "a"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+	if user := MustQueryRow[testUser]("SELECT * FROM users"); user != (testUser{1, "a"}) {
+		t.Errorf("unexpected result %v", user)
+	}
+	if cols, expected := MustColumns[testUser](), "id, name"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+
+	fake.setRows([]string{"id", "name"})
+	defer func() {
+		if r := recover(); r != ErrNoRows {
+			t.Errorf("expected panic with ErrNoRows got %v", r)
+		}
+	}()
+	MustQueryRow[testUser]("SELECT * FROM users")
+}
+
+func TestSliceFromRowsWorkers(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	var values [][]driver.Value
+	for i := 0; i < 1000; i++ {
+		values = append(values, []driver.Value{int64(i), []byte("user")})
+	}
+	fake.setRows([]string{"id", "name"}, values...)
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	users, err := SliceFromRows[testUser](rows, Workers(4))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != len(values) {
+		t.Fatalf("expected %d users got %d", len(values), len(users))
+	}
+	for i, u := range users {
+		if u.Id != i || u.Name != "user" {
+			t.Fatalf("expected {%d user} at %d got %v", i, i, u)
+		}
+	}
+}
+
+func TestQueryWithTotal(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name", "__total"}, []driver.Value{int64(1), "a", int64(7)})
+
+	users, total, err := QueryWithTotal[testUser]("SELECT * FROM users LIMIT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 1 || users[0] != (testUser{1, "a"}) {
+		t.Errorf("expected [{1 a}] got %v", users)
+	}
+	if total != 7 {
+		t.Errorf("expected total 7 got %d", total)
+	}
+	if q := "SELECT id, name, count(*) OVER() AS __total FROM users LIMIT 1"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+}
+
+func TestScanColumns(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"a", "b"}, []driver.Value{int64(1), "a"})
+
+	var user testUser
+	row := sqldb.QueryRow("SELECT id, name FROM users")
+	if err := ScanColumns(&user, row, []string{"id", "name"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := (testUser{1, "a"}); user != expected {
+		t.Errorf("expected %v got %v", expected, user)
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name", "extra"}, []driver.Value{int64(1), "a", "x"}, []driver.Value{int64(2), "b", "y"})
+
+	if _, err := Query[testUser]("SELECT * FROM users", MaxRows(1)); err != ErrMaxRows {
+		t.Errorf("expected ErrMaxRows got %v", err)
+	}
+	if _, err := Query[testUser]("SELECT * FROM users", Strict()); err == nil {
+		t.Error("expected error for unmapped column in strict mode")
+	}
+
+	other, otherFake := newFakeDB(t)
+	otherFake.setRows([]string{"id", "name"}, []driver.Value{int64(3), "c"})
+	user, err := QueryRow[testUser]("SELECT * FROM users", Executor(other), WithDialect(SQLServer))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if user != (testUser{3, "c"}) {
+		t.Errorf("expected {3 c} got %v", user)
+	}
+	if q := "SELECT TOP 1 id, name FROM users"; otherFake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, otherFake.queries[0])
+	}
+	if len(fake.queries) != 2 {
+		t.Errorf("expected 2 queries on the default database got %d", len(fake.queries))
+	}
+}
+
+func TestSliceFromRowsFiltered(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"}, []driver.Value{int64(3), "c"})
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	users, err := SliceFromRowsFiltered(rows, func(u *testUser) bool { return u.Id != 2 })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 2 || users[0].Id != 1 || users[1].Id != 3 {
+		t.Errorf("expected users 1 and 3 got %v", users)
+	}
+}
+
+func TestScanAll(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	users := []testUser{{Id: 0, Name: "z"}}
+	if err := ScanAll(&users, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 3 || users[0].Name != "z" || users[2] != (testUser{2, "b"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+
+	rows, err = sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ptrs, err := PointerSliceFromRows[testUser](rows, Capacity(10))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ptrs) != 2 || *ptrs[0] != (testUser{1, "a"}) || *ptrs[1] != (testUser{2, "b"}) {
+		t.Errorf("unexpected result %v", ptrs)
+	}
+	if cap(ptrs) < 10 {
+		t.Errorf("expected capacity of at least 10 got %d", cap(ptrs))
+	}
+	if d := reflect.ValueOf(ptrs[1]).Pointer() - reflect.ValueOf(ptrs[0]).Pointer(); d != reflect.TypeOf(testUser{}).Size() {
+		t.Errorf("expected rows to be allocated in one block, got pointers %d bytes apart", d)
+	}
+
+	rows, err = sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := PointerSliceFromRows[testUser](rows, MaxRows(1)); !errors.Is(err, ErrMaxRows) {
+		t.Errorf("expected ErrMaxRows got %v", err)
+	}
+
+	for _, dest := range []interface{}{users, &[]int{}, (*[]testUser)(nil)} {
+		rows, err = sqldb.Query("SELECT id, name FROM users")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := ScanAll(dest, rows); err == nil {
+			t.Errorf("expected error for %T", dest)
+		}
+	}
+}
+
+func TestNullToZero(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), nil}, []driver.Value{nil, "b"})
+	h := New(sqldb)
+	ctx := context.Background()
+
+	if _, err := QueryContext[testUser](ctx, "SELECT * FROM users", WithHandle(h)); err == nil {
+		t.Fatal("expected error scanning NULL into a string")
+	}
+	users, err := QueryContext[testUser](ctx, "SELECT * FROM users", WithHandle(h), NullToZero())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 2 || users[0] != (testUser{1, ""}) || users[1] != (testUser{0, "b"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+
+	h.NullToZero = true
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer rows.Close()
+	rows.Next()
+	u := testUser{Id: 5, Name: "z"}
+	if err := h.Scan(&u, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u != (testUser{1, ""}) {
+		t.Errorf("expected {1 } got %v", u)
+	}
+}
+
+// nullMarker is a sql.Scanner which records whether it scanned NULL.
+type nullMarker struct{ null bool }
+
+func (m *nullMarker) Scan(src interface{}) error {
+	m.null = src == nil
+	return nil
+}
+
+func TestNullToZeroScanner(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "a")
+	rows.addValue("marker", nil)
+
+	var v struct {
+		Name   string
+		Marker nullMarker `sql:"marker"`
+	}
+	h := New(nil)
+	h.NullToZero = true
+	if err := h.Scan(&v, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.Name != "a" || !v.Marker.null {
+		t.Errorf("expected NULL to be passed to the Scanner, got %+v", v)
+	}
+}
+
+func TestQueryMapRows(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	names, err := QueryMapRows("SELECT * FROM users", func(u testUser) string { return u.Name })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("expected [a b] got %v", names)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"}, []driver.Value{int64(3), "c"})
+
+	rows, err := sqldb.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sum, err := Reduce(rows, 0, func(sum int, u testUser) int { return sum + u.Id })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sum != 6 {
+		t.Errorf("expected 6 got %d", sum)
+	}
+}
+
+func TestQueryPage(t *testing.T) {
+	fake := setFakeDatabase(t)
+	// The fake answers both queries with the same row, which is read as the count
+	// of 25 rows and as a page holding a single user.
+	fake.setRows([]string{"id"}, []driver.Value{int64(25)})
+
+	page, err := QueryPage[testUser](context.Background(), "SELECT * FROM users ORDER BY id;", 3, 12)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if page.TotalCount != 25 || len(page.Items) != 1 || page.HasNext {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	expected := []string{
+		"SELECT COUNT(*) FROM (SELECT id, name FROM users) page",
+		"SELECT id, name FROM users ORDER BY id LIMIT 12 OFFSET 24",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+
+	page, err = QueryPage[testUser](context.Background(), "SELECT * FROM users ORDER BY id", 1, 10)
+	if err != nil || !page.HasNext {
+		t.Errorf("expected a next page got %+v, %v", page, err)
+	}
+	if _, err := QueryPage[testUser](context.Background(), "SELECT * FROM users", 0, 10); err == nil {
+		t.Error("expected error for page 0")
+	}
+}
+
+func TestQueryPageSQLServer(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setQueryRows("SELECT COUNT(*)", []string{"count"}, []driver.Value{int64(3)})
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+
+	page, err := QueryPage[testUser](context.Background(), "SELECT * FROM users WHERE name IN (SELECT name FROM admins ORDER BY name) ORDER BY id", 1, 2, WithDialect(SQLServer))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if page.TotalCount != 3 || len(page.Items) != 2 || !page.HasNext {
+		t.Errorf("unexpected page %+v", page)
+	}
+	expected := []string{
+		"SELECT COUNT(*) FROM (SELECT id, name FROM users WHERE name IN (SELECT name FROM admins ORDER BY name)) page",
+		"SELECT id, name FROM users WHERE name IN (SELECT name FROM admins ORDER BY name) ORDER BY id OFFSET 0 ROWS FETCH NEXT 2 ROWS ONLY",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+}
+
+func TestTrimOrderBy(t *testing.T) {
+	for query, expected := range map[string]string{
+		"SELECT a FROM t ORDER BY a":                   "SELECT a FROM t",
+		"SELECT a FROM t order  by a DESC, b":          "SELECT a FROM t",
+		"SELECT a FROM t ORDER BY a LIMIT 5":           "SELECT a FROM t ORDER BY a LIMIT 5",
+		"SELECT a FROM t WHERE b = 'ORDER BY x'":       "SELECT a FROM t WHERE b = 'ORDER BY x'",
+		"SELECT reorder_by FROM t":                     "SELECT reorder_by FROM t",
+		"SELECT a FROM (SELECT a FROM t ORDER BY a) s": "SELECT a FROM (SELECT a FROM t ORDER BY a) s",
+	} {
+		if got := trimOrderBy(query); got != expected {
+			t.Errorf("trimOrderBy(%q): expected %q got %q", query, expected, got)
+		}
+	}
+}
+
+func TestCountExists(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	fake.setRows([]string{"count"}, []driver.Value{int64(3)})
+
+	n, err := Count[testUser](ctx, "name = ?", "a")
+	if err != nil || n != 3 {
+		t.Errorf("expected 3 got %d, %v", n, err)
+	}
+	found, err := Exists[testUser](ctx, "")
+	if err != nil || !found {
+		t.Errorf("expected row to exist got %v, %v", found, err)
+	}
+	fake.setRows([]string{"1"})
+	if found, err := Exists[testUser](ctx, "id = ?", 1); err != nil || found {
+		t.Errorf("expected no row got %v, %v", found, err)
+	}
+
+	expected := []string{
+		"SELECT COUNT(*) FROM users WHERE name = ?",
+		"SELECT 1 FROM users LIMIT 1",
+		"SELECT 1 FROM users WHERE id = ? LIMIT 1",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+}
+
+func TestFindBy(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(7), "a"})
+
+	u, err := FindByID[testUser](ctx, 7)
+	if err != nil || u != (testUser{7, "a"}) {
+		t.Errorf("unexpected result %v, %v", u, err)
+	}
+	if _, err := FindBy[testUser](ctx, map[string]interface{}{"Name": "a", "id": nil}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"SELECT id, name FROM users WHERE id = ? LIMIT 1",
+		"SELECT id, name FROM users WHERE name = ? AND id IS NULL LIMIT 1",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if len(fake.args[0]) != 1 || fake.args[0][0] != int64(7) {
+		t.Errorf("unexpected arguments %v", fake.args[0])
+	}
+
+	fake.setRows([]string{"id", "name"})
+	if _, err := FindByID[testUser](ctx, 8); err != ErrNoRows {
+		t.Errorf("expected %v got %v", ErrNoRows, err)
+	}
+	if _, err := FindBy[testUser](ctx, map[string]interface{}{"missing": 1}); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	if _, err := FindBy[testUser](ctx, nil); err == nil {
+		t.Error("expected error without keys")
+	}
+	if _, err := FindByID[testType](ctx, 1); err == nil {
+		t.Error("expected error for type without primary key")
+	}
+}
+
+func TestQueryOn(t *testing.T) {
+	setFakeDatabase(t)
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+
+	tx, err := sqldb.Begin()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer tx.Rollback()
+
+	users, err := QueryOn[testUser](tx, "SELECT * FROM users WHERE id = ?", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 1 || users[0] != (testUser{1, "a"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+	user, err := QueryRowOn[testUser](tx, "SELECT * FROM users WHERE id = ?", 1)
+	if err != nil || user != (testUser{1, "a"}) {
+		t.Errorf("unexpected result %v, %v", user, err)
+	}
+	if len(fake.queries) != 2 {
+		t.Errorf("expected 2 queries on the transaction got %v", fake.queries)
+	}
+}
+
+func TestQueryNullPointers(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"name", "count", "status", "addr"},
+		[]driver.Value{nil, nil, nil, nil},
+		[]driver.Value{"a", int64(2), "ok", "10.0.0.1"},
+	)
+
+	type row struct {
+		Name   *string     `sql:"name"`
+		Count  *int64      `sql:"count"`
+		Status *string     `sql:"status,intern"`
+		Addr   *netip.Addr `sql:"addr"`
+	}
+	rows, err := Query[row]("SELECT * FROM t")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r := rows[0]; r.Name != nil || r.Count != nil || r.Status != nil || r.Addr != nil {
+		t.Errorf("expected nil fields for NULL got %+v", r)
+	}
+	r := rows[1]
+	if r.Name == nil || *r.Name != "a" || r.Count == nil || *r.Count != 2 || r.Status == nil || *r.Status != "ok" {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r.Addr == nil || *r.Addr != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("unexpected address %v", r.Addr)
+	}
+}
+
+func TestGet(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"count"}, []driver.Value{int64(3)})
+
+	n, err := Get[int64]("SELECT count(*) FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 got %d", n)
+	}
+
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	user, err := Get[testUser]("SELECT * FROM users WHERE id = ?", 1)
+	if err != nil || user != (testUser{1, "a"}) {
+		t.Errorf("unexpected result %v, %v", user, err)
+	}
+
+	expected := []string{"SELECT count(*) FROM users LIMIT 1", "SELECT id, name FROM users WHERE id = ? LIMIT 1"}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+
+	fake.setRows([]string{"name"})
+	if _, err := Get[string]("SELECT name FROM users WHERE id = ?", 2); err != sql.ErrNoRows {
+		t.Errorf("expected %v got %v", sql.ErrNoRows, err)
+	}
+
+	fake.setRows([]string{}, []driver.Value{})
+	if _, err := Get[string]("SELECT FROM users"); err == nil {
+		t.Error("expected error for a result with no columns")
+	}
+}
+
+// testIter is a RowsIter over values held in memory.
+type testIter struct {
+	columns []string
+	rows    [][]interface{}
+	i       int
+	closed  bool
+}
+
+func (r *testIter) Columns() ([]string, error) { return r.columns, nil }
+func (r *testIter) Next() bool                 { r.i++; return r.i <= len(r.rows) }
+func (r *testIter) Err() error                 { return nil }
+func (r *testIter) Close() error               { r.closed = true; return nil }
+
+func (r *testIter) Scan(dest ...interface{}) error {
+	return valueRows{r.columns, r.rows[r.i-1]}.Scan(dest...)
+}
+
+func TestSliceFromRowsIter(t *testing.T) {
+	rows := &testIter{columns: []string{"id", "name"}, rows: [][]interface{}{{int64(1), "a"}, {int64(2), "b"}}}
+	users, err := SliceFromRows[testUser](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 2 || users[1] != (testUser{2, "b"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+	if !rows.closed {
+		t.Error("expected rows to be closed")
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RelTagName is the name of the struct tag describing the relations loaded by Load.
+var RelTagName = "rel"
+
+// Load loads the related rows of the relation field name of the structs held by dest,
+// which must be a pointer to a struct or to a slice of structs or struct pointers.
+// The relation is described by the field's "rel" tag, whose first element is its
+// kind and which names the foreign key column with the fk option:
+//
+//	type User struct {
+//		Id      int      `sql:"id,pk"`
+//		Orders  []Order  `sql:"-" rel:"has_many,fk=user_id"`
+//		Profile *Profile `sql:"-" rel:"has_one,fk=user_id"`
+//		TeamId  int      `sql:"team_id"`
+//		Team    *Team    `sql:"-" rel:"belongs_to,fk=team_id"`
+//	}
+//
+// For has_many and has_one relations, the foreign key is a column of the related
+// type referencing the primary key of the struct. For belongs_to relations, it is a
+// column of the struct referencing the primary key of the related type. The type
+// whose primary key is referenced must have a single primary key field. Related rows
+// are selected from the table of their type with a single query, or with as many as
+// needed to pass at most MaxParams keys to each, ordered by their primary key for
+// has_many and has_one relations:
+//
+//	err := sqlstruct.Load(ctx, &users, "Orders")
+//
+// A has_many field must be a slice of the related type or of pointers to it, and is
+// appended to. Other fields must be the related type or a pointer to it, and are set
+// to the first related row, if any, which is the one with the lowest primary key.
+// Options are handled as by Query.
+func Load(ctx context.Context, dest interface{}, name string, opts ...Option) error {
+	o := applyOptions(opts)
+	h := o.handleOf()
+
+	parents, ptyp, err := loadParents(dest)
+	if err != nil {
+		return err
+	}
+	sf, ok := ptyp.FieldByName(name)
+	if !ok {
+		return fmt.Errorf("sqlstruct: %v has no field %s", ptyp, name)
+	}
+	kind, relOpts := parseTag(sf.Tag.Get(RelTagName))
+	fk, _ := relOpts.Value("fk")
+	if fk == "" {
+		return fmt.Errorf("sqlstruct: field %s of %v has no rel tag with a foreign key", name, ptyp)
+	}
+	ctyp := sf.Type
+	if kind == "has_many" {
+		if ctyp.Kind() != reflect.Slice {
+			return fmt.Errorf("sqlstruct: has_many field %s of %v must be a slice", name, ptyp)
+		}
+		ctyp = ctyp.Elem()
+	}
+	if ctyp.Kind() == reflect.Ptr {
+		ctyp = ctyp.Elem()
+	}
+
+	pfields, err := h.getFieldInfo(ptyp)
+	if err != nil {
+		return err
+	}
+	cfields, err := h.getFieldInfo(ctyp)
+	if err != nil {
+		return err
+	}
+	var pkey, ckey *field
+	switch kind {
+	case "has_many", "has_one":
+		pkey, ckey = singleKey(pfields), cfields[fk]
+	case "belongs_to":
+		pkey, ckey = pfields[fk], singleKey(cfields)
+	default:
+		return fmt.Errorf("sqlstruct: unknown relation %q of field %s of %v", kind, name, ptyp)
+	}
+	if pkey == nil || ckey == nil {
+		return fmt.Errorf("sqlstruct: cannot relate %v and %v by %s", ptyp, ctyp, fk)
+	}
+
+	var keys []interface{}
+	seen := make(map[interface{}]bool)
+	for _, p := range parents {
+		if k, ok := keyOf(p, pkey, nil); ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	cols, err := h.columnList(ctyp)
+	if err != nil {
+		return err
+	}
+	query := "SELECT " + cols + " FROM " + h.quotedTable(ctyp) + " WHERE " + h.quote(ckey.name) + " IN (?)"
+	if live := h.notDeleted(cfields); live != "" && !o.unscoped {
+		query += " AND " + live
+	}
+	if cpks := cfields.primaryKeys(); kind != "belongs_to" && len(cpks) > 0 {
+		names := make([]string, len(cpks))
+		for i, f := range cpks {
+			names[i] = f.name
+		}
+		query += " ORDER BY " + strings.Join(h.quoteAll(names), ", ")
+	}
+	o.expandIn = true
+	related := make(map[interface{}][]reflect.Value)
+	ktyp := keyType(ptyp, pkey)
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > MaxParams {
+			n = MaxParams
+		}
+		err = o.run(ctx, query, []interface{}{keys[:n]}, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+			var n int64
+			for rows.Next() {
+				c := reflect.New(ctyp)
+				if err := o.scan(ctx, c.Interface(), rows); err != nil {
+					return n, err
+				}
+				n++
+				if k, ok := keyOf(c.Elem(), ckey, ktyp); ok {
+					related[k] = append(related[k], c)
+				}
+			}
+			return n, rows.Err()
+		})
+		if err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+
+	for _, p := range parents {
+		k, ok := keyOf(p, pkey, nil)
+		if !ok {
+			continue
+		}
+		setRelated(p.FieldByIndex(sf.Index), related[k])
+	}
+	return nil
+}
+
+// loadParents returns the addressable struct values held by dest, and their type.
+func loadParents(dest interface{}) ([]reflect.Value, reflect.Type, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, nil, fmt.Errorf("sqlstruct: dest must be a pointer to a struct or slice; got %T", dest)
+	}
+	v = v.Elem()
+	if v.Kind() == reflect.Struct {
+		return []reflect.Value{v}, v.Type(), nil
+	}
+	if v.Kind() != reflect.Slice || structType(v.Type().Elem()) == nil {
+		return nil, nil, fmt.Errorf("sqlstruct: dest must be a pointer to a struct or slice; got %T", dest)
+	}
+	parents := make([]reflect.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		p := v.Index(i)
+		if p.Kind() == reflect.Ptr {
+			if p.IsNil() {
+				continue
+			}
+			p = p.Elem()
+		}
+		parents = append(parents, p)
+	}
+	return parents, structType(v.Type().Elem()), nil
+}
+
+// singleKey returns the primary key field of fields, or nil if there is not exactly one.
+func singleKey(fields fieldInfo) *field {
+	keys := fields.primaryKeys()
+	if len(keys) != 1 {
+		return nil
+	}
+	return keys[0]
+}
+
+// keyType returns the type of the field f of the struct type typ, or the type it
+// points to.
+func keyType(typ reflect.Type, f *field) reflect.Type {
+	return elemType(typ.FieldByIndex(f.index).Type)
+}
+
+// keyOf returns the value of the field f of the struct v for relating rows, converted
+// to typ if it is not nil. Pointers, as used for nullable keys, are dereferenced. The
+// second result is false if the value is nil or zero or cannot be converted.
+func keyOf(v reflect.Value, f *field, typ reflect.Type) (interface{}, bool) {
+	fv, err := v.FieldByIndexErr(f.index)
+	if err == nil && fv.Kind() == reflect.Ptr && !fv.IsNil() {
+		fv = fv.Elem()
+	}
+	if err != nil || fv.IsZero() || !fv.Type().Comparable() {
+		return nil, false
+	}
+	if typ != nil && fv.Type() != typ {
+		if !fv.CanConvert(typ) {
+			return nil, false
+		}
+		fv = fv.Convert(typ)
+	}
+	return fv.Interface(), true
+}
+
+// setRelated stores the related values, pointers to structs, in the relation field fv.
+func setRelated(fv reflect.Value, related []reflect.Value) {
+	switch {
+	case fv.Kind() == reflect.Slice:
+		for _, c := range related {
+			if fv.Type().Elem().Kind() != reflect.Ptr {
+				c = c.Elem()
+			}
+			fv.Set(reflect.Append(fv, c))
+		}
+	case len(related) == 0:
+	case fv.Kind() == reflect.Ptr:
+		fv.Set(related[0])
+	default:
+		fv.Set(related[0].Elem())
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type relOrder struct {
+	Id     int    `sql:"id,pk"`
+	UserId int64  `sql:"user_id"`
+	Item   string `sql:"item"`
+}
+
+func (relOrder) TableName() string { return "orders" }
+
+type relTeam struct {
+	Id   int    `sql:"id,pk"`
+	Name string `sql:"name"`
+}
+
+func (relTeam) TableName() string { return "teams" }
+
+type relUser struct {
+	Id     int         `sql:"id,pk"`
+	TeamId int         `sql:"team_id"`
+	Orders []relOrder  `sql:"-" rel:"has_many,fk=user_id"`
+	First  *relOrder   `sql:"-" rel:"has_one,fk=user_id"`
+	Team   *relTeam    `sql:"-" rel:"belongs_to,fk=team_id"`
+	Bad    []*relOrder `sql:"-" rel:"has_many"`
+}
+
+func TestLoad(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "item", "user_id"},
+		[]driver.Value{int64(10), "x", int64(1)},
+		[]driver.Value{int64(11), "y", int64(2)},
+		[]driver.Value{int64(12), "z", int64(1)},
+	)
+	ctx := context.Background()
+
+	users := []relUser{{Id: 1, TeamId: 5}, {Id: 2, TeamId: 5}, {Id: 3}}
+	if err := Load(ctx, &users, "Orders"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "SELECT id, item, user_id FROM orders WHERE user_id IN (?, ?, ?) ORDER BY id"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	expected := [][]relOrder{
+		{{10, 1, "x"}, {12, 1, "z"}},
+		{{11, 2, "y"}},
+		nil,
+	}
+	for i, u := range users {
+		if !reflect.DeepEqual(u.Orders, expected[i]) {
+			t.Errorf("expected orders %v for user %d got %v", expected[i], u.Id, u.Orders)
+		}
+	}
+
+	var user relUser
+	user.Id = 2
+	if err := Load(ctx, &user, "First"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if user.First == nil || user.First.Id != 11 {
+		t.Errorf("unexpected has_one result %v", user.First)
+	}
+
+	fake.queries = nil
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(5), "team"})
+	ptrs := []*relUser{&users[0], nil, &users[1], &users[2]}
+	if err := Load(ctx, &ptrs, "Team"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "SELECT id, name FROM teams WHERE id IN (?)"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	if users[0].Team == nil || users[0].Team.Name != "team" || users[1].Team == nil || users[2].Team != nil {
+		t.Errorf("unexpected belongs_to result %v %v %v", users[0].Team, users[1].Team, users[2].Team)
+	}
+
+	defer func(n int) { MaxParams = n }(MaxParams)
+	MaxParams = 2
+	fake.queries, fake.args = nil, nil
+	fake.setRows([]string{"id", "item", "user_id"}, []driver.Value{int64(13), "w", int64(3)})
+	users = []relUser{{Id: 1}, {Id: 2}, {Id: 3}}
+	if err := Load(ctx, &users, "First"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(fake.queries) != 2 || !reflect.DeepEqual(fake.args, [][]interface{}{{int64(1), int64(2)}, {int64(3)}}) {
+		t.Errorf("expected keys split into two queries got %q with %v", fake.queries, fake.args)
+	}
+	if users[2].First == nil || users[2].First.Id != 13 {
+		t.Errorf("unexpected has_one result %v", users[2].First)
+	}
+
+	for _, field := range []string{"Bad", "Missing", "Id"} {
+		if err := Load(ctx, &users, field); err == nil {
+			t.Errorf("expected error for field %s", field)
+		}
+	}
+}
+
+type relNote struct {
+	Id     int64  `sql:"id,pk"`
+	UserId *int64 `sql:"user_id"`
+	Text   string `sql:"text"`
+}
+
+func (relNote) TableName() string { return "notes" }
+
+type relAuthor struct {
+	Id    int64     `sql:"id,pk"`
+	Notes []relNote `sql:"-" rel:"has_many,fk=user_id"`
+}
+
+type relPost struct {
+	Id       int64      `sql:"id,pk"`
+	AuthorId *int64     `sql:"author_id"`
+	Author   *relAuthor `sql:"-" rel:"belongs_to,fk=author_id"`
+}
+
+func TestLoadNullableKey(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	fake.setRows([]string{"id", "text", "user_id"},
+		[]driver.Value{int64(10), "x", int64(1)},
+		[]driver.Value{int64(11), "y", nil},
+	)
+
+	authors := []relAuthor{{Id: 1}, {Id: 2}}
+	if err := Load(ctx, &authors, "Notes"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(authors[0].Notes) != 1 || authors[0].Notes[0].Id != 10 || authors[1].Notes != nil {
+		t.Errorf("unexpected has_many result %v", authors)
+	}
+
+	fake.queries, fake.args = nil, nil
+	fake.setRows([]string{"id"}, []driver.Value{int64(1)})
+	one := int64(1)
+	posts := []relPost{{Id: 5, AuthorId: &one}, {Id: 6}}
+	if err := Load(ctx, &posts, "Author"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(fake.args, [][]interface{}{{int64(1)}}) {
+		t.Errorf("expected the non-nil key only got %v", fake.args)
+	}
+	if posts[0].Author == nil || posts[0].Author.Id != 1 || posts[1].Author != nil {
+		t.Errorf("unexpected belongs_to result %v %v", posts[0].Author, posts[1].Author)
+	}
+}
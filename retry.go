@@ -0,0 +1,140 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"time"
+)
+
+// RetryPolicy retries the queries and statements which fail with transient errors,
+// such as deadlocks, serialization failures and dropped connections. For example:
+//
+//	h := sqlstruct.New(db)
+//	h.Retry = &sqlstruct.RetryPolicy{MaxAttempts: 3}
+//
+// Only the queries and statements run on the database of a Handle are retried, not
+// those run in a transaction or on a Queryer given with the Executor option: after a
+// deadlock or serialization failure the whole transaction must be retried, which
+// WithTx cannot do for its caller. Queries are only retried if they fail before
+// returning rows, so that no row is scanned twice.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a statement is run before its error is
+	// returned, including the first. Values below 2 disable retrying.
+	MaxAttempts int
+
+	// Backoff returns the delay before the next attempt, after attempt attempts have
+	// failed. If nil, the delay starts at 10ms and doubles after each attempt up to
+	// one second, with random jitter.
+	Backoff func(attempt int) time.Duration
+
+	// Retryable reports whether a statement failing with err should be run again. If
+	// nil, IsTransient is used.
+	Retryable func(err error) bool
+}
+
+// Retry is the RetryPolicy used by the Handles without their own, including the
+// package-level functions. It is nil by default, so statements are not retried.
+var Retry *RetryPolicy
+
+// retryPolicy returns the RetryPolicy of h, or the package-level one.
+func (h *Handle) retryPolicy() *RetryPolicy {
+	if h.Retry != nil {
+		return h.Retry
+	}
+	return Retry
+}
+
+// do calls fn until it succeeds, returns an error which should not be retried, or
+// MaxAttempts is reached. fn also reports whether running it again is safe. do stops
+// waiting and returns the last error if ctx is done.
+func (p *RetryPolicy) do(ctx context.Context, fn func() (bool, error)) error {
+	for attempt := 1; ; attempt++ {
+		safe, err := fn()
+		if err == nil || p == nil || !safe || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+		t := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(attempt)
+	}
+	d := 10 * time.Millisecond << min(attempt-1, 7)
+	d = min(d, time.Second)
+	return d/2 + rand.N(d/2)
+}
+
+// transientStates lists the SQLSTATE codes of transient errors: serialization
+// failures, which MySQL also reports for deadlocks, and Postgres deadlocks.
+var transientStates = map[string]bool{
+	"40001": true,
+	"40P01": true,
+}
+
+// IsTransient reports whether err is likely to go away if the statement that caused
+// it is run again: driver.ErrBadConn, or a database error with the SQLSTATE of a
+// serialization failure (40001) or deadlock (40P01). The SQLSTATE is read from errors
+// with a SQLState method, as those of pgx and lib/pq, or a SQLState field, as those of
+// the MySQL driver. Context errors are never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if transientStates[sqlState(err)] {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlState returns the SQLSTATE code held by err itself, or an empty string.
+func sqlState(err error) string {
+	if s, ok := err.(interface{ SQLState() string }); ok {
+		return s.SQLState()
+	}
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	f := v.FieldByName("SQLState")
+	switch {
+	case f.Kind() == reflect.String:
+		return f.String()
+	case f.Kind() == reflect.Array && f.Type().Elem().Kind() == reflect.Uint8:
+		b := make([]byte, f.Len())
+		for i := range b {
+			b[i] = byte(f.Index(i).Uint())
+		}
+		return string(b)
+	}
+	return ""
+}
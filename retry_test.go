@@ -0,0 +1,92 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// stateError is a database error with a SQLSTATE code.
+type stateError string
+
+func (e stateError) Error() string    { return "error " + string(e) }
+func (e stateError) SQLState() string { return string(e) }
+
+// mysqlError has the shape of the errors of the MySQL driver.
+type mysqlError struct {
+	Number   uint16
+	SQLState [5]byte
+}
+
+func (e *mysqlError) Error() string { return fmt.Sprintf("Error %d", e.Number) }
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{driver.ErrBadConn, true},
+		{stateError("40001"), true},
+		{fmt.Errorf("wrapped: %w", stateError("40P01")), true},
+		{stateError("23505"), false},
+		{&mysqlError{1213, [5]byte{'4', '0', '0', '0', '1'}}, true},
+		{&mysqlError{1062, [5]byte{'2', '3', '0', '0', '0'}}, false},
+		{context.DeadlineExceeded, false},
+	} {
+		if IsTransient(tc.err) != tc.transient {
+			t.Errorf("%v: expected transient %v", tc.err, tc.transient)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	h := New(sqldb)
+	var delays []int
+	h.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: func(attempt int) time.Duration {
+		delays = append(delays, attempt)
+		return 0
+	}}
+
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	fake.failures = []error{stateError("40001"), stateError("40P01")}
+	var users []testUser
+	if err := h.Query(&users, "SELECT * FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 1 || len(fake.queries) != 3 || len(delays) != 2 || delays[1] != 2 {
+		t.Errorf("expected 3 attempts, got users %v, queries %d, delays %v", users, len(fake.queries), delays)
+	}
+
+	fake.failures = []error{stateError("40001"), stateError("40001"), stateError("40001")}
+	if _, err := Insert(context.Background(), &testUser{Name: "b"}, WithHandle(h)); err == nil {
+		t.Error("expected error after MaxAttempts")
+	}
+	if len(fake.queries) != 6 {
+		t.Errorf("expected 3 more attempts got %d", len(fake.queries)-3)
+	}
+
+	fake.failures = []error{errors.New("constraint")}
+	if _, err := Insert(context.Background(), &testUser{Name: "b"}, WithHandle(h)); err == nil {
+		t.Error("expected error")
+	}
+	if len(fake.queries) != 7 {
+		t.Errorf("expected non-transient errors not to be retried, got %d attempts", len(fake.queries)-6)
+	}
+
+	fake.failures = []error{stateError("40001")}
+	if _, err := Insert(context.Background(), &testUser{Name: "b"}, Executor(sqldb), WithHandle(h)); err == nil {
+		t.Error("expected error")
+	}
+	if len(fake.queries) != 8 {
+		t.Errorf("expected statements on an Executor not to be retried, got %d attempts", len(fake.queries)-7)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaError is returned by ValidateSchema when the columns of a table do not match
+// the fields of its struct type.
+type SchemaError struct {
+	Type       reflect.Type // the struct type
+	Table      string       // the name of its table
+	Missing    []string     // columns of fields which are missing from the table
+	Unmapped   []string     // columns of the table not mapped to any field
+	Mismatched []string     // descriptions of columns whose type cannot be scanned into their field
+}
+
+func (e *SchemaError) Error() string {
+	var msg []string
+	if len(e.Missing) > 0 {
+		msg = append(msg, fmt.Sprintf("columns %s are missing from the table", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Unmapped) > 0 {
+		msg = append(msg, fmt.Sprintf("columns %s are not mapped to any field", strings.Join(e.Unmapped, ", ")))
+	}
+	msg = append(msg, e.Mismatched...)
+	return fmt.Sprintf("sqlstruct: table %s does not match %s: %s", e.Table, e.Type, strings.Join(msg, "; "))
+}
+
+// ValidateSchema compares the fields of T with the columns of its table in the
+// database q and returns a *SchemaError describing any difference: columns of fields
+// missing from the table, columns of the table with no field, and columns whose type
+// cannot be scanned into the type of their field. It is meant to be run at startup or
+// in tests, to catch schema drift before it shows up as errors or silently missing
+// values. For example:
+//
+//	if err := sqlstruct.ValidateSchema[User](ctx, db); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// The columns are read from information_schema.columns, from pragma_table_info for
+// SQLite and from user_tab_columns for Oracle, according to the dialect in use. Types
+// are only compared for fields of the basic types, time.Time, []byte, pointers to
+// them and the Null types of database/sql, and not for string fields, into which any
+// column can be scanned.
+func ValidateSchema[T any](ctx context.Context, q Queryer, opts ...Option) error {
+	o := applyOptions(append(opts[:len(opts):len(opts)], Executor(q)))
+	h := o.handleOf()
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return err
+	}
+
+	table := h.tableName(typ)
+	columns, err := o.tableColumns(ctx, table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("sqlstruct: table %s of %s does not exist or has no columns", table, typ)
+	}
+
+	e := &SchemaError{Type: typ, Table: table}
+	for _, name := range fields.names() {
+		col, ok := columns[strings.ToLower(name)]
+		if !ok {
+			e.Missing = append(e.Missing, name)
+			continue
+		}
+		if !scannableColumn(fields[name], col.dataType) {
+			e.Mismatched = append(e.Mismatched, fmt.Sprintf("column %s of type %s cannot be scanned into %s", name, col.dataType, fields[name].typ))
+		}
+	}
+	mapped := make(map[string]bool, len(fields))
+	for name := range fields {
+		mapped[strings.ToLower(name)] = true
+	}
+	for _, name := range sortedKeys(columns) {
+		if !mapped[name] {
+			e.Unmapped = append(e.Unmapped, name)
+		}
+	}
+	if len(e.Missing) > 0 || len(e.Unmapped) > 0 || len(e.Mismatched) > 0 {
+		return e
+	}
+	return nil
+}
+
+// columnInfo describes a column of a table in the database.
+type columnInfo struct {
+	dataType string // lowercase name of the type
+	nullable bool
+}
+
+// splitTable splits a table name qualified by a schema into both parts.
+func splitTable(table string) (schema, name string) {
+	if i := strings.LastIndex(table, "."); i != -1 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// tableColumns returns the columns of table, keyed by their lowercase names.
+func (o options) tableColumns(ctx context.Context, table string) (map[string]columnInfo, error) {
+	schema, name := splitTable(table)
+
+	var query string
+	args := []interface{}{name}
+	switch o.dialectOf() {
+	case SQLite:
+		query = `SELECT name, type, "notnull" = 0 FROM pragma_table_info(?)`
+		if schema != "" {
+			query = `SELECT name, type, "notnull" = 0 FROM pragma_table_info(?, ?)`
+			args = append(args, schema)
+		}
+	case Oracle:
+		query = "SELECT column_name, data_type, nullable FROM user_tab_columns WHERE table_name = ?"
+		args[0] = strings.ToUpper(name)
+		if schema != "" {
+			query = "SELECT column_name, data_type, nullable FROM all_tab_columns WHERE table_name = ? AND owner = ?"
+			args = append(args, strings.ToUpper(schema))
+		}
+	default:
+		current := map[Dialect]string{Postgres: "current_schema()", MySQL: "DATABASE()", SQLServer: "SCHEMA_NAME()"}[o.dialectOf()]
+		query = "SELECT column_name, data_type, is_nullable FROM information_schema.columns WHERE table_name = ?"
+		switch {
+		case schema != "":
+			query += " AND table_schema = ?"
+			args = append(args, schema)
+		case current != "":
+			query += " AND table_schema = " + current
+		}
+	}
+
+	columns := make(map[string]columnInfo)
+	err := o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		var n int64
+		for rows.Next() {
+			var name, dataType, nullable string
+			if err := rows.Scan(&name, &dataType, &nullable); err != nil {
+				return n, err
+			}
+			nullable = strings.ToLower(nullable)
+			columns[strings.ToLower(name)] = columnInfo{
+				dataType: strings.ToLower(dataType),
+				nullable: nullable == "yes" || nullable == "y" || nullable == "1" || nullable == "true",
+			}
+			n++
+		}
+		return n, rows.Err()
+	})
+	return columns, err
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// columnKinds classifies the data types reported by databases, without their length
+// or precision, into the kinds of values they hold.
+var columnKinds = map[string]string{
+	"integer": "int", "int": "int", "bigint": "int", "smallint": "int", "tinyint": "int",
+	"mediumint": "int", "int2": "int", "int4": "int", "int8": "int",
+	"real": "float", "float": "float", "double": "float", "double precision": "float",
+	"float4": "float", "float8": "float", "binary_double": "float", "binary_float": "float",
+	"numeric": "decimal", "decimal": "decimal", "number": "decimal", "money": "decimal",
+	"boolean": "bool", "bool": "bool", "bit": "bool",
+	"text": "text", "varchar": "text", "character varying": "text", "char": "text",
+	"character": "text", "nvarchar": "text", "nchar": "text", "ntext": "text",
+	"varchar2": "text", "nvarchar2": "text", "clob": "text", "nclob": "text",
+	"tinytext": "text", "mediumtext": "text", "longtext": "text",
+	"date": "time", "datetime": "time", "datetime2": "time", "datetimeoffset": "time",
+	"smalldatetime": "time", "timestamp": "time", "timestamptz": "time",
+	"timestamp with time zone": "time", "timestamp without time zone": "time",
+	"bytea": "bytes", "blob": "bytes", "binary": "bytes", "varbinary": "bytes",
+	"tinyblob": "bytes", "mediumblob": "bytes", "longblob": "bytes", "image": "bytes", "raw": "bytes",
+}
+
+// compatibleKinds lists, for each kind of field, the kinds of columns which can be
+// scanned into it.
+var compatibleKinds = map[string][]string{
+	"bool":  {"bool", "int"},
+	"int":   {"int", "decimal"},
+	"float": {"float", "decimal", "int"},
+	"time":  {"time"},
+	"bytes": {"bytes", "text"},
+}
+
+// scannableColumn reports whether a column of the given data type can be scanned into
+// the field f. Types which are unknown, or fields of types which are not checked, are
+// assumed to be compatible.
+func scannableColumn(f *field, dataType string) bool {
+	if i, j := strings.IndexByte(dataType, '('), strings.IndexByte(dataType, ')'); i != -1 && j > i {
+		// Oracle reports types such as timestamp(6) with time zone.
+		dataType = strings.TrimSpace(dataType[:i] + dataType[j+1:])
+	}
+	dataType = strings.TrimSuffix(dataType, " unsigned")
+	colKind, ok := columnKinds[dataType]
+	if !ok || f.split != "" || f.timeFormat != "" || f.conv != nil || f.ip || f.array {
+		return true
+	}
+
+	typ, _ := columnGoType(f.typ)
+	if isScanner(typ) {
+		return true
+	}
+	var kind string
+	switch {
+	case typ == timeType:
+		kind = "time"
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		kind = "bytes"
+	case isIntKind(typ.Kind()):
+		kind = "int"
+	case typ.Kind() == reflect.Bool:
+		kind = "bool"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		kind = "float"
+	default:
+		return true
+	}
+	return containsString(compatibleKinds[kind], colKind)
+}
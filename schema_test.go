@@ -0,0 +1,69 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	db, fake := newFakeDB(t)
+	ctx := context.Background()
+
+	fake.setRows([]string{"column_name", "data_type", "is_nullable"},
+		[]driver.Value{"id", "bigint", "NO"},
+		[]driver.Value{"name", "character varying", "NO"},
+		[]driver.Value{"avatar", "text", "YES"},
+		[]driver.Value{"created", "timestamp(6) with time zone", "NO"},
+		[]driver.Value{"score", "integer", "YES"},
+		[]driver.Value{"tags", "text", "NO"},
+	)
+	type account struct {
+		Id      int64    `sql:"id,pk,auto"`
+		Name    string   `sql:"name,size=100"`
+		Avatar  []byte   `sql:"avatar"`
+		Created string   `sql:"created"`
+		Score   *int32   `sql:"score"`
+		Tags    []string `sql:"tags,split=;"`
+	}
+	if err := ValidateSchema[account](ctx, db, WithDialect(Postgres)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if q := "SELECT column_name, data_type, is_nullable FROM information_schema.columns WHERE table_name = $1 AND table_schema = current_schema()"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+
+	type drifted struct {
+		Id      int64 `sql:"id,pk"`
+		Name    int   `sql:"name"`
+		Score   *bool `sql:"score"`
+		Deleted bool  `sql:"deleted"`
+	}
+	err := ValidateSchema[drifted](ctx, db, WithDialect(SQLite))
+	var serr *SchemaError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *SchemaError got %v", err)
+	}
+	if q := `SELECT name, type, "notnull" = 0 FROM pragma_table_info(?)`; fake.queries[1] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[1])
+	}
+	if !reflect.DeepEqual(serr.Missing, []string{"deleted"}) {
+		t.Errorf("expected missing [deleted] got %v", serr.Missing)
+	}
+	if !reflect.DeepEqual(serr.Unmapped, []string{"avatar", "created", "tags"}) {
+		t.Errorf("expected unmapped [avatar created tags] got %v", serr.Unmapped)
+	}
+	if len(serr.Mismatched) != 1 {
+		t.Errorf("expected the name column to be mismatched got %v", serr.Mismatched)
+	}
+
+	fake.setRows([]string{"column_name", "data_type", "is_nullable"})
+	if err := ValidateSchema[drifted](ctx, db); err == nil || errors.As(err, &serr) {
+		t.Errorf("expected error for missing table got %v", err)
+	}
+}
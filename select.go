@@ -0,0 +1,165 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SelectOption configures a statement generated by BuildSelect.
+type SelectOption func(*selectStmt)
+
+// selectStmt holds the parts of a SELECT statement collected from SelectOptions.
+type selectStmt struct {
+	with  []CTE
+	from  string
+	where []string
+	args  []interface{}
+	asOf  *time.Time
+
+	withDeleted bool
+}
+
+// Where adds a condition to the WHERE clause of the statement. Conditions from
+// multiple Where options are combined with AND. Placeholders in clause are bound
+// to args.
+func Where(clause string, args ...interface{}) SelectOption {
+	return func(s *selectStmt) {
+		s.where = append(s.where, "("+clause+")")
+		s.args = append(s.args, args...)
+	}
+}
+
+// WithDeleted includes the rows which are soft deleted, as described for Delete. By
+// default the statement only selects the rows whose field tagged with the
+// "softdelete" option is NULL.
+func WithDeleted() SelectOption {
+	return func(s *selectStmt) {
+		s.withDeleted = true
+	}
+}
+
+// From selects from table instead of the table of the result type, for example
+// from a common table expression added with With.
+func From(table string) SelectOption {
+	return func(s *selectStmt) {
+		s.from = table
+	}
+}
+
+// CTE is a common table expression, a named query which can be referred to by the
+// statement following a WITH clause.
+type CTE struct {
+	Name    string
+	Columns []string // optional list of the names of the columns of the query
+	Statement
+}
+
+// SelectCTE returns a CTE named name which selects the columns of T. The statement is
+// built as with BuildSelect and the columns of T are used as its column list.
+func SelectCTE[T any](name string, opts ...SelectOption) CTE {
+	query, args := BuildSelect[T](opts...)
+	fields, _ := getFieldInfo(reflect.TypeOf((*T)(nil)).Elem())
+	return CTE{Name: name, Columns: fields.columns(), Statement: Statement{query, args}}
+}
+
+// With adds common table expressions to the WITH clause of the statement, so that
+// multi-step queries can be composed from typed parts. For example:
+//
+//	query, args := sqlstruct.BuildSelect[User](
+//		sqlstruct.With(sqlstruct.SelectCTE[Order]("recent", sqlstruct.Where("created > ?", since))),
+//		sqlstruct.Where("id IN (SELECT user_id FROM recent)"),
+//	)
+func With(ctes ...CTE) SelectOption {
+	return func(s *selectStmt) {
+		s.with = append(s.with, ctes...)
+	}
+}
+
+// AsOf selects the rows as they were at time t.
+//
+// By default the statement uses the FOR SYSTEM_TIME AS OF clause understood by
+// SQL Server and MariaDB system-versioned tables. If the type implements Historian
+// the rows are instead read from its history table using the valid-time columns.
+func AsOf(t time.Time) SelectOption {
+	return func(s *selectStmt) {
+		s.asOf = &t
+	}
+}
+
+// HistoryTable describes a table holding past versions of rows, where each row is
+// valid from ValidFrom up to but not including ValidTo. A NULL ValidTo marks the
+// current version.
+type HistoryTable struct {
+	Name      string
+	ValidFrom string
+	ValidTo   string
+}
+
+// Historian is implemented by struct types whose history is kept in a separate table.
+type Historian interface {
+	HistoryTable() HistoryTable
+}
+
+// BuildSelect returns a SELECT statement for the columns and table of T, along with
+// the arguments for its placeholders. It panics if T cannot be mapped to columns.
+//
+// For example:
+//
+//	query, args := sqlstruct.BuildSelect[User](sqlstruct.Where("id = ?", 1), sqlstruct.AsOf(t))
+//	rows, err := db.Query(query, args...)
+func BuildSelect[T any](opts ...SelectOption) (string, []interface{}) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	var s selectStmt
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	var args []interface{}
+	var with []string
+	for _, cte := range s.with {
+		def := cte.Name
+		if len(cte.Columns) > 0 {
+			def += " (" + strings.Join(cte.Columns, ", ") + ")"
+		}
+		with = append(with, def+" AS ("+cte.Query+")")
+		args = append(args, cte.Args...)
+	}
+
+	from := s.from
+	if from == "" {
+		from = defaultHandle.quotedTable(typ)
+	}
+	where := s.where
+	if fields, err := getFieldInfo(typ); err == nil && !s.withDeleted {
+		if live := defaultHandle.notDeleted(fields); live != "" {
+			where = append([]string{live}, where...)
+		}
+	}
+	if s.asOf != nil {
+		if h, ok := reflect.Zero(typ).Interface().(Historian); ok {
+			hist := h.HistoryTable()
+			from = hist.Name
+			where = append([]string{hist.ValidFrom + " <= ?", "(" + hist.ValidTo + " IS NULL OR " + hist.ValidTo + " > ?)"}, where...)
+			args = append(args, *s.asOf, *s.asOf)
+		} else {
+			from += " FOR SYSTEM_TIME AS OF ?"
+			args = append(args, *s.asOf)
+		}
+	}
+	args = append(args, s.args...)
+
+	query := "SELECT " + defaultHandle.mustColumnList(typ) + " FROM " + from
+	if len(with) > 0 {
+		query = "WITH " + strings.Join(with, ", ") + " " + query
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	return query, args
+}
@@ -0,0 +1,73 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"testing"
+	"time"
+)
+
+type testVersionedUser struct {
+	Id   int    `sql:"id,pk"`
+	Name string `sql:"name"`
+}
+
+func (testVersionedUser) TableName() string { return "users" }
+
+func (testVersionedUser) HistoryTable() HistoryTable {
+	return HistoryTable{Name: "users_history", ValidFrom: "valid_from", ValidTo: "valid_to"}
+}
+
+func TestBuildSelect(t *testing.T) {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		args  int
+		opts  []SelectOption
+		hist  bool
+	}{
+		{"SELECT id, name FROM users", 0, nil, false},
+		{"SELECT id, name FROM users WHERE (id = ?) AND (name = ?)", 2, []SelectOption{Where("id = ?", 1), Where("name = ?", "a")}, false},
+		{"SELECT id, name FROM users FOR SYSTEM_TIME AS OF ? WHERE (id = ?)", 2, []SelectOption{Where("id = ?", 1), AsOf(at)}, false},
+		{"SELECT id, name FROM users_history WHERE valid_from <= ? AND (valid_to IS NULL OR valid_to > ?) AND (id = ?)", 3, []SelectOption{AsOf(at), Where("id = ?", 1)}, true},
+	}
+	for _, test := range tests {
+		var query string
+		var args []interface{}
+		if test.hist {
+			query, args = BuildSelect[testVersionedUser](test.opts...)
+		} else {
+			query, args = BuildSelect[testUser](test.opts...)
+		}
+		if query != test.query {
+			t.Errorf("expected %q got %q", test.query, query)
+		}
+		if len(args) != test.args {
+			t.Errorf("expected %d args got %v", test.args, args)
+		}
+	}
+}
+
+func TestBuildSelectWith(t *testing.T) {
+	query, args := BuildSelect[testUser](
+		With(SelectCTE[testVersionedUser]("recent", Where("id > ?", 10))),
+		Where("id IN (SELECT id FROM recent)"),
+		Where("name = ?", "a"),
+	)
+
+	expected := "WITH recent (id, name) AS (SELECT id, name FROM users WHERE (id > ?)) " +
+		"SELECT id, name FROM users WHERE (id IN (SELECT id FROM recent)) AND (name = ?)"
+	if query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+	if len(args) != 2 || args[0] != 10 || args[1] != "a" {
+		t.Errorf("expected args [10 a] got %v", args)
+	}
+
+	query, _ = BuildSelect[testUser](With(CTE{Name: "top", Statement: Statement{Query: "SELECT 1"}}), From("top"))
+	if expected := "WITH top AS (SELECT 1) SELECT id, name FROM top"; query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+}
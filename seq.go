@@ -0,0 +1,105 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iter"
+	"reflect"
+)
+
+// QuerySeq returns an iterator over the rows selected by query, scanned into values
+// of T one at a time, so that large results need not be held in memory at once. The
+// query is run when the iteration starts, and QueryReplace and Options are handled as
+// by Query. For example:
+//
+//	for user, err := range sqlstruct.QuerySeq[User](ctx, "SELECT * FROM users") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// An error ends the iteration and is yielded along with the zero value of T. The rows
+// are closed when the iteration ends, including when the loop is exited early.
+func QuerySeq[T any](ctx context.Context, query string, args ...interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		args, opts := splitOptions(args)
+		o := applyOptions(opts)
+
+		query, err := o.expand(query, reflect.TypeOf((*T)(nil)).Elem())
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		stopped := false
+		err = o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+			var n int64
+			for rows.Next() {
+				if err := ctx.Err(); err != nil {
+					return n, err
+				}
+				var t T
+				if err := o.scan(ctx, &t, rows); err != nil {
+					return n, err
+				}
+				n++
+				if !yield(t, nil) {
+					stopped = true
+					return n, nil
+				}
+			}
+			return n, rows.Err()
+		})
+		if err != nil && !stopped {
+			yield(zero, err)
+		}
+	}
+}
+
+// Stop can be returned by the function passed to ForEach to stop the iteration
+// without an error.
+var Stop = errors.New("sqlstruct: stop iteration")
+
+// ForEach executes query and calls fn with each of its rows scanned into a T, one row
+// at a time, so that only a single row is held in memory. QueryReplace and Options are
+// handled as by Query. If fn returns an error, the iteration stops and ForEach returns
+// that error, unless it is or wraps Stop, in which case ForEach returns nil.
+func ForEach[T any](ctx context.Context, query string, fn func(T) error, args ...interface{}) error {
+	args, opts := splitOptions(args)
+	o := applyOptions(opts)
+
+	query, err := o.expand(query, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+	err = o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		var n int64
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				return n, err
+			}
+			if o.maxRows > 0 && n >= int64(o.maxRows) {
+				return n, ErrMaxRows
+			}
+			var t T
+			if err := o.scan(ctx, &t, rows); err != nil {
+				return n, err
+			}
+			n++
+			if err := fn(t); err != nil {
+				return n, err
+			}
+		}
+		return n, rows.Err()
+	})
+	if errors.Is(err, Stop) {
+		return nil
+	}
+	return err
+}
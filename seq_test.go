@@ -0,0 +1,73 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestQuerySeq(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"}, []driver.Value{int64(3), "c"})
+
+	var users []testUser
+	for user, err := range QuerySeq[testUser](context.Background(), "SELECT * FROM users") {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		users = append(users, user)
+		if len(users) == 2 {
+			break
+		}
+	}
+	if len(users) != 2 || users[1] != (testUser{2, "b"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+
+	fake.err = errors.New("boom")
+	for _, err := range QuerySeq[testUser](context.Background(), "SELECT * FROM users") {
+		if err != fake.err {
+			t.Errorf("expected %v got %v", fake.err, err)
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	fake := setFakeDatabase(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"}, []driver.Value{int64(3), "c"})
+
+	var names []string
+	err := ForEach(context.Background(), "SELECT * FROM users", func(u testUser) error {
+		names = append(names, u.Name)
+		if u.Id == 2 {
+			return Stop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(names) != 2 || names[1] != "b" {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	boom := errors.New("boom")
+	err = ForEach(context.Background(), "SELECT * FROM users", func(u testUser) error {
+		return boom
+	})
+	if err != boom {
+		t.Errorf("expected %v got %v", boom, err)
+	}
+
+	err = ForEach(context.Background(), "SELECT * FROM users", func(u testUser) error {
+		return fmt.Errorf("done at %d: %w", u.Id, Stop)
+	})
+	if err != nil {
+		t.Errorf("expected wrapped Stop to end the iteration without error got %v", err)
+	}
+}
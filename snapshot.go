@@ -0,0 +1,116 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+// StructSnapshot holds the column values of a struct at the time it was taken with
+// Snapshot, for UpdateChanged to find which fields have changed since.
+type StructSnapshot struct {
+	typ    reflect.Type
+	values map[string]interface{}
+	err    error
+}
+
+// Snapshot records the column values of v, a struct or a pointer to one, typically
+// right after it was read from the database:
+//
+//	user, err := sqlstruct.QueryRow[User]("SELECT * FROM users WHERE id = ?", id)
+//	snap := sqlstruct.Snapshot(&user)
+//	user.Name = "gedi"
+//	n, err := sqlstruct.UpdateChanged(ctx, &user, snap)
+//
+// The options should include the WithHandle option given to UpdateChanged, if any, so
+// that the columns are mapped in the same way. If v cannot be mapped to columns, the
+// error is returned by UpdateChanged.
+func Snapshot(v interface{}, opts ...Option) *StructSnapshot {
+	s := new(StructSnapshot)
+	rv, err := structOf(v)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	fields, err := applyOptions(opts).handleOf().getFieldInfo(rv.Type())
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.typ = rv.Type()
+	s.values, s.err = snapshotValues(fields, rv)
+	return s
+}
+
+// snapshotValues returns the values of the columns of the struct rv. Values are
+// converted as they would be for the database and copied, so that later changes to
+// slices held by the struct are noticed.
+func snapshotValues(fields fieldInfo, rv reflect.Value) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(fields))
+	for name, f := range fields {
+		v := f.value(rv)
+		if d, ok := v.(driver.Valuer); ok {
+			var err error
+			if v, err = d.Value(); err != nil {
+				return nil, fmt.Errorf("sqlstruct: cannot convert column %s: %w", name, err)
+			}
+		}
+		if b, ok := v.([]byte); ok {
+			v = append([]byte(nil), b...)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// UpdateChanged works like Update but only sets the columns whose fields have changed
+// since snap was taken of v. If no field has changed, no statement is executed and
+// UpdateChanged returns 0. Only writing the changed columns avoids overwriting the
+// columns updated concurrently by others, and keeps the statements small for wide
+// rows. After a successful update snap holds the new values, so that it can be used
+// again for the next update of v. v should be a pointer if it has a version field, so
+// that the incremented version is kept.
+func UpdateChanged[T any](ctx context.Context, v T, snap *StructSnapshot, opts ...Option) (int64, error) {
+	if snap.err != nil {
+		return 0, snap.err
+	}
+	rv, err := structOf(v)
+	if err != nil {
+		return 0, err
+	}
+	if rv.Type() != snap.typ {
+		return 0, fmt.Errorf("sqlstruct: snapshot of %s cannot be used to update %s", snap.typ, rv.Type())
+	}
+	fields, err := applyOptions(opts).handleOf().getFieldInfo(rv.Type())
+	if err != nil {
+		return 0, err
+	}
+	values, err := snapshotValues(fields, rv)
+	if err != nil {
+		return 0, err
+	}
+
+	var changed []string
+	for _, name := range fields.names() {
+		f := fields[name]
+		if f.pk || f.readOnly || f.version || reflect.DeepEqual(values[name], snap.values[name]) {
+			continue
+		}
+		changed = append(changed, name)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	n, err := Update(ctx, v, append(opts[:len(opts):len(opts)], UpdateColumns(changed...))...)
+	if err == nil {
+		// Update may have incremented the version field of v.
+		snap.values, err = snapshotValues(fields, rv)
+	}
+	return n, err
+}
@@ -0,0 +1,79 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateChanged(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	type account struct {
+		Id      int      `sql:"id,pk"`
+		Name    string   `sql:"name"`
+		Email   string   `sql:"email"`
+		Tags    []string `sql:"tags,split=;"`
+		Version int      `sql:"version,version"`
+	}
+
+	a := &account{Id: 1, Name: "a", Email: "a@example.com", Tags: []string{"x"}, Version: 1}
+	snap := Snapshot(a)
+	if n, err := UpdateChanged(ctx, a, snap); err != nil || n != 0 || len(fake.queries) != 0 {
+		t.Fatalf("expected no update got %d, %v, %q", n, err, fake.queries)
+	}
+
+	a.Email = "b@example.com"
+	a.Tags[0] = "y"
+	if _, err := UpdateChanged(ctx, a, snap); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "UPDATE account SET email = ?, tags = ?, version = version + 1 WHERE id = ? AND version = ?"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	if args := []interface{}{"b@example.com", "y", int64(1), int64(1)}; !reflect.DeepEqual(fake.args[0], args) {
+		t.Errorf("expected args %v got %v", args, fake.args[0])
+	}
+
+	a.Name = "b"
+	if _, err := UpdateChanged(ctx, a, snap); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "UPDATE account SET name = ?, version = version + 1 WHERE id = ? AND version = ?"; fake.queries[1] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[1])
+	}
+
+	if _, err := UpdateChanged(ctx, &testUser{}, snap); err == nil {
+		t.Error("expected error for snapshot of another type")
+	}
+	if _, err := UpdateChanged(ctx, a, Snapshot(1)); err == nil {
+		t.Error("expected error for snapshot of a non-struct value")
+	}
+}
+
+func TestUpdateChangedHandle(t *testing.T) {
+	db, fake := newFakeDB(t)
+	h := New(db)
+	h.NameMapper = ToSnakeCase
+	ctx := context.Background()
+
+	type profile struct {
+		Id       int `sql:"id,pk"`
+		FullName string
+		Bio      string
+	}
+
+	p := &profile{Id: 1, FullName: "a", Bio: "x"}
+	snap := Snapshot(p, WithHandle(h))
+	p.Bio = "y"
+	if _, err := UpdateChanged(ctx, p, snap, WithHandle(h)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "UPDATE profile SET bio = ? WHERE id = ?"; len(fake.queries) != 1 || fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries)
+	}
+}
@@ -30,6 +30,20 @@ For example:
 
     err = rows.Err() // get any errors encountered during iteration
 
+Embedded structs are flattened into the columns of their fields. Other struct fields
+are flattened the same way when tagged with the "prefix" option, with the column
+name of the field and an underscore prefixed to each of their columns:
+
+    type Address struct {
+        City   string `sql:"city"`
+        Street string `sql:"street"`
+    }
+
+    type Customer struct {
+        Name string  `sql:"name"`
+        Home Address `sql:"home,prefix"` // columns home_city and home_street
+    }
+
 Aliased tables in a SQL statement may be scanned into a specific structure identified
 by the same alias, using the ColumnsAliased and ScanAliased functions:
 
@@ -78,17 +92,27 @@ by the same alias, using the ColumnsAliased and ScanAliased functions:
     fmt.Printf("%+v", *user.HomeAddress)
     // output: "{Id:2 City:Vilnius Street:Plento 34}"
 
+Queries may also be executed and scanned in a single step once a database has been
+set with SetDatabase. The * in the query is replaced by the columns of the result
+type, see QueryReplace:
+
+    sqlstruct.SetDatabase(db)
+    users, err := sqlstruct.Query[User]("SELECT * FROM users WHERE name = ?", "gedi")
+
 */
 package sqlstruct
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"sort"
 	"strings"
-	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // NameMapper is the function used to convert struct fields which do not have sql tags
@@ -102,53 +126,318 @@ import (
 // Alternatively for a custom mapping, any func(string) string can be used instead.
 var NameMapper func(string) string = strings.ToLower
 
-// A cache of fieldInfos to save reflecting every time. Inspried by encoding/xml
-var finfos map[reflect.Type]fieldInfo
-var finfoLock sync.RWMutex
-
 // TagName is the name of the tag to use on struct fields
 var TagName = "sql"
 
-// fieldInfo is a mapping of field tag values to their indices
-type fieldInfo map[string][]int
+// TagNames, if not empty, replaces TagName with a list of tag names consulted in order
+// for each field, so that structs tagged for other libraries can be mapped as well.
+// The first of the tags present on a field gives its column name and options. For
+// example, to prefer sql tags but fall back to the db tags used by sqlx:
+//
+//	sqlstruct.TagNames = []string{"sql", "db"}
+var TagNames []string
+
+// field describes a single struct field mapped to a column.
+type field struct {
+	name  string // column name
+	index []int  // index sequence for reflect.Value.FieldByIndex
+	typ   reflect.Type
+	opts  tagOptions
+
+	pk         bool   // part of the primary key
+	auto       bool   // generated by the database
+	omitEmpty  bool   // not written when zero
+	readOnly   bool   // never written
+	writeOnly  bool   // never read
+	version    bool   // incremented by each update, for optimistic locking
+	softDelete bool   // set when the row is deleted, NULL while it is live
+	intern     bool   // scan through the string intern table
+	raw        bool   // store the driver's bytes without conversion
+	timeFormat string // layout of times stored as text
+	split      string // separator of slices stored as delimited text
+	array      bool   // convert Postgres arrays
+	ip         bool   // convert IP addresses and networks
+	nullable   bool   // convert the element of a pointer field, storing NULL as nil
+	conv       Converter
+	def        reflect.Value  // scanned for NULL or missing columns, from the "default" option
+	layouts    []string       // layouts of times scanned from text, from the Handle
+	loc        *time.Location // location of scanned times, from the Handle
+}
+
+// newField returns a field for the column name with the given tag options.
+func newField(name string, index []int, typ reflect.Type, opts tagOptions) *field {
+	f := &field{
+		name:   name,
+		index:  index,
+		typ:    typ,
+		opts:   opts,
+		pk:     opts.Contains("pk"),
+		auto:   opts.Contains("auto"),
+		intern: opts.Contains("intern"),
+		raw:    opts.Contains("raw"),
+	}
+	f.omitEmpty = opts.Contains("omitempty")
+	f.readOnly = opts.Contains("readonly")
+	f.writeOnly = opts.Contains("writeonly")
+	f.version = opts.Contains("version")
+	f.softDelete = opts.Contains("softdelete")
+	f.timeFormat, _ = opts.Value("timeformat")
+	f.split, _ = opts.Value("split")
+	f.array = opts.Contains("array")
+	f.ip = (isIPType(typ) || typ.Kind() == reflect.Ptr && isIPType(typ.Elem())) && !f.raw
+	if f.conv = converterFor(typ); f.conv == nil && typ.Kind() == reflect.Ptr {
+		if f.conv = converterFor(typ.Elem()); f.conv != nil {
+			f.nullable = true
+		}
+	}
+	f.nullable = f.nullable || typ.Kind() == reflect.Ptr && !isIPType(typ) && (f.intern || f.ip || f.split != "")
+	if value, ok := opts.Value("default"); ok {
+		f.def = f.parseDefault(value)
+	}
+	return f
+}
+
+// parseDefault returns the value of the "default" option of f converted to the type of
+// f, or an invalid value if it cannot be converted. Values quoted as SQL strings, as in
+// default='active', are unquoted first. Defaults which are SQL expressions, such as
+// CURRENT_TIMESTAMP for a time.Time field, only apply to CREATE TABLE statements.
+func (f *field) parseDefault(value string) reflect.Value {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	v := reflect.New(f.typ).Elem()
+	if err := assign(f.wrap(v), value); err != nil {
+		return reflect.Value{}
+	}
+	return v
+}
+
+// setDefault sets the field value fv to a copy of the default value of f.
+func (f *field) setDefault(fv reflect.Value) {
+	switch f.def.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(f.def.Type().Elem())
+		p.Elem().Set(f.def.Elem())
+		fv.Set(p)
+	case reflect.Slice:
+		fv.Set(reflect.AppendSlice(reflect.MakeSlice(f.def.Type(), 0, f.def.Len()), f.def))
+	default:
+		fv.Set(f.def)
+	}
+}
+
+// dest returns the destination passed to Rows.Scan for the field f of the struct v.
+// Nil pointers to embedded or nested structs on the way to the field are allocated.
+// If nullToZero is true, NULL is scanned as its zero value into a field which cannot
+// hold nil and is not a sql.Scanner, which handles NULL itself.
+func (f *field) dest(v reflect.Value, nullToZero bool) interface{} {
+	fv := f.settable(v)
+	if f.def.IsValid() || nullToZero && !canBeNil(fv.Kind()) && !isScanner(fv.Type()) {
+		return &defaultValue{fv, f, f.wrap(fv)}
+	}
+	return f.wrap(fv)
+}
+
+// canBeNil reports whether values of kind k can be nil.
+func canBeNil(k reflect.Kind) bool {
+	switch k {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// settable returns the value of the field f of the struct v, allocating nil pointers
+// to embedded or nested structs on the way to the field.
+func (f *field) settable(v reflect.Value) reflect.Value {
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// lookup returns the value of the field f of the struct v, or false if a pointer to an
+// embedded or nested struct on the way to the field is nil.
+func (f *field) lookup(v reflect.Value) (reflect.Value, bool) {
+	fv, err := v.FieldByIndexErr(f.index)
+	return fv, err == nil
+}
+
+// wrap returns the destination passed to Rows.Scan for the field value fv.
+func (f *field) wrap(fv reflect.Value) interface{} {
+	if f.nullable {
+		return &nullValue{fv, f.convert}
+	}
+	if s := f.convert(fv); s != nil {
+		return s
+	}
+	return fv.Addr().Interface()
+}
+
+// convert returns the sql.Scanner converting column values for the field value fv
+// according to the options of f, or nil if the value needs no conversion.
+func (f *field) convert(fv reflect.Value) sql.Scanner {
+	switch {
+	case f.intern:
+		return &internScanner{fv}
+	case f.raw:
+		return &rawScanner{fv}
+	case f.timeFormat != "":
+		return &timeFormatValue{fv, f.timeFormat}
+	case f.layouts != nil:
+		return &timeValue{fv, f.layouts, f.loc}
+	case f.split != "":
+		return &splitValue{fv, f.split}
+	case f.array:
+		return &arrayValue{fv}
+	case f.ip:
+		return &ipValue{fv}
+	case f.conv != nil:
+		return &convertedValue{fv, f.conv}
+	}
+	return nil
+}
+
+// value returns the argument passed to the database for the field f of the struct v.
+// Fields of nil embedded or nested structs are NULL.
+func (f *field) value(v reflect.Value) interface{} {
+	fv, err := v.FieldByIndexErr(f.index)
+	if err != nil {
+		return nil
+	}
+	if d, ok := f.wrap(fv).(driver.Valuer); ok {
+		return d
+	}
+	return fv.Interface()
+}
+
+// zero reports whether the field f of the struct v holds its zero value. Fields of
+// nil embedded or nested structs are zero.
+func (f *field) zero(v reflect.Value) bool {
+	fv, err := v.FieldByIndexErr(f.index)
+	return err != nil || fv.IsZero()
+}
+
+// fieldInfo is a mapping of column names to struct fields
+type fieldInfo map[string]*field
 
-func init() {
-	finfos = make(map[reflect.Type]fieldInfo)
+// discard is the scan destination for columns which are not mapped to any field.
+// It is shared by all scans since it never holds on to the value.
+var discard = new(discardScanner)
+
+type discardScanner struct{}
+
+func (*discardScanner) Scan(interface{}) error { return nil }
+
+// Scannable is implemented by types which can scan a row into a list of destinations,
+// such as the sql.Row and sql.Rows types from the standard library.
+type Scannable interface {
+	Scan(...interface{}) error
 }
 
 // Rows defines the interface of types that are scannable with the Scan function.
 // It is implemented by the sql.Rows type from the standard library
 type Rows interface {
-	Scan(...interface{}) error
+	Scannable
 	Columns() ([]string, error)
 }
 
+// RowsIter is implemented by row sources which can be iterated and closed, such as
+// the sql.Rows type from the standard library. It is accepted by SliceFromRows and
+// the other functions consuming whole results, so that they can also be used with
+// wrapped rows or rows from other database libraries.
+type RowsIter interface {
+	Rows
+	Next() bool
+	Err() error
+	Close() error
+}
+
+// columnRows is a Rows whose columns are supplied by the caller.
+type columnRows struct {
+	Scannable
+	columns []string
+}
+
+func (r columnRows) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+// getFieldInfo returns the fieldInfo of typ under the package-level configuration.
+func getFieldInfo(typ reflect.Type) (fieldInfo, error) {
+	return defaultHandle.getFieldInfo(typ)
+}
+
 // getFieldInfo creates a fieldInfo for the provided type. Fields that are not tagged
-// with the "sql" tag and unexported fields are not included.
-func getFieldInfo(typ reflect.Type) fieldInfo {
-	finfoLock.RLock()
-	finfo, ok := finfos[typ]
-	finfoLock.RUnlock()
+// with the "sql" tag and unexported fields are not included. An error is returned
+// if the type has a field which cannot be mapped to a column.
+func (h *Handle) getFieldInfo(typ reflect.Type) (fieldInfo, error) {
+	return h.nestedFieldInfo(typ, nil)
+}
+
+// MaxNestingDepth limits how deeply structs may be embedded in, or nested with the
+// "prefix" option in, the struct types mapped to columns. Mapping a deeper struct
+// returns an error. It should be set before any struct type is mapped, as mappings are
+// cached.
+var MaxNestingDepth = 32
+
+// nestedFieldInfo returns the fieldInfo of typ, which is embedded or nested in the
+// struct types outer, outermost first. It returns an error if typ is one of outer,
+// which would otherwise recurse forever, or if outer is deeper than MaxNestingDepth.
+func (h *Handle) nestedFieldInfo(typ reflect.Type, outer []reflect.Type) (fieldInfo, error) {
+	key := h.fieldKey(typ)
+	cached, ok := h.finfos.Load(key)
+	h.fieldCacheLookup(ok)
 	if ok {
-		return finfo
+		return cached.(fieldInfo), nil
 	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqlstruct: %v is not a struct type", typ)
+	}
+	for i, o := range outer {
+		if o == typ {
+			path := make([]string, 0, len(outer)-i+1)
+			for _, t := range outer[i:] {
+				path = append(path, t.String())
+			}
+			return nil, fmt.Errorf("sqlstruct: %v contains itself: %s -> %v", typ, strings.Join(path, " -> "), typ)
+		}
+	}
+	if len(outer) > MaxNestingDepth {
+		return nil, fmt.Errorf("sqlstruct: %v is nested more than %d levels deep in %v", typ, MaxNestingDepth, outer[0])
+	}
+	outer = append(outer[:len(outer):len(outer)], typ)
 
-	finfo = make(fieldInfo)
+	finfo := make(fieldInfo)
+	tagNames, nameMapper := h.tagNames(), h.mapperFor(typ)
 
 	n := typ.NumField()
 	for i := 0; i < n; i++ {
 		f := typ.Field(i)
-		tag := f.Tag.Get(TagName)
+		tag, opts := parseTag(lookupTag(f.Tag, tagNames))
 
 		// Skip unexported fields or fields marked with "-"
 		if f.PkgPath != "" || tag == "-" {
 			continue
 		}
 
-		// Handle embedded structs
-		if f.Anonymous && f.Type.Kind() == reflect.Struct {
-			for k, v := range getFieldInfo(f.Type) {
-				finfo[k] = append([]int{i}, v...)
+		// Handle embedded structs and pointers to structs. Those which scan themselves,
+		// such as sql.NullString or sql.Null[T], are mapped to a single column instead.
+		if f.Anonymous && structType(f.Type) != nil && !isScanner(f.Type) {
+			efinfo, err := h.nestedFieldInfo(structType(f.Type), outer)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range efinfo {
+				embedded := *v
+				embedded.index = append([]int{i}, v.index...)
+				finfo[k] = &embedded
 			}
 			continue
 		}
@@ -157,65 +446,506 @@ func getFieldInfo(typ reflect.Type) fieldInfo {
 		if tag == "" {
 			tag = f.Name
 		}
-		tag = NameMapper(tag)
+		tag = nameMapper(tag)
+
+		if err := checkField(typ, f, opts); err != nil {
+			return nil, err
+		}
+
+		// Flatten nested structs tagged with the "prefix" option
+		if opts.Contains("prefix") {
+			nfinfo, err := h.nestedFieldInfo(structType(f.Type), outer)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range nfinfo {
+				nested := *v
+				nested.name = tag + "_" + k
+				nested.index = append([]int{i}, v.index...)
+				finfo[nested.name] = &nested
+			}
+			continue
+		}
+
+		nf := newField(tag, []int{i}, f.Type, opts)
+		if elemType(nf.typ) == timeType && nf.timeFormat == "" && nf.conv == nil {
+			nf.layouts, nf.loc = h.timeLayouts()
+		}
+		finfo[tag] = nf
+	}
+
+	cached, _ = h.finfos.LoadOrStore(key, finfo)
+	return cached.(fieldInfo), nil
+}
+
+// lookupTag returns the value of the first of the tags names present in tag.
+func lookupTag(tag reflect.StructTag, names []string) string {
+	for _, name := range names {
+		if value, ok := tag.Lookup(name); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isScanner reports whether typ, or a pointer to it, implements sql.Scanner.
+func isScanner(typ reflect.Type) bool {
+	return typ.Implements(scannerType) || reflect.PointerTo(typ).Implements(scannerType)
+}
+
+// structType returns typ if it is a struct type, or its element type if it is a
+// pointer to a struct type, and nil otherwise.
+func structType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
 
-		finfo[tag] = []int{i}
+// checkField returns an error if the field f of the struct type typ cannot be
+// scanned from a column with the given tag options.
+func checkField(typ reflect.Type, f reflect.StructField, opts tagOptions) error {
+	switch f.Type.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return fmt.Errorf("sqlstruct: field %s.%s has unsupported type %s", typ, f.Name, f.Type)
 	}
+	if opts.Contains("readonly") && opts.Contains("writeonly") {
+		return fmt.Errorf("sqlstruct: field %s.%s cannot be both readonly and writeonly", typ, f.Name)
+	}
+	if err := opts.check(f.Type); err != nil {
+		return fmt.Errorf("sqlstruct: field %s.%s: %v", typ, f.Name, err)
+	}
+	return nil
+}
 
-	finfoLock.Lock()
-	finfos[typ] = finfo
-	finfoLock.Unlock()
+// Register computes and caches the column mapping of the types of the given values
+// so that later calls don't pay the cost of reflection. It is intended to be called
+// during program initialization, where it also reports types which cannot be used
+// with this package. Each value must be a struct or a pointer to a struct.
+func Register(values ...interface{}) error {
+	return defaultHandle.Register(values...)
+}
 
-	return finfo
+// Register works like the package-level Register for the configuration of h.
+func (h *Handle) Register(values ...interface{}) error {
+	for _, v := range values {
+		typ := reflect.TypeOf(v)
+		if typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			return fmt.Errorf("sqlstruct: cannot register %T: not a struct or pointer to struct", v)
+		}
+		if _, err := h.columnList(typ); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Scan scans the next row from rows in to a struct pointed to by dest. The struct type
 // should have exported fields tagged with the "sql" tag. Columns from row which are not
 // mapped to any struct fields are ignored. Struct fields which have no matching column
-// in the result set are left unchanged.
+// in the result set are left unchanged. Pointer fields, such as *string or *time.Time,
+// are set to nil for NULL columns and to a newly allocated value otherwise.
+//
+// Fields tagged with the "default" option, as in `sql:"status,default='active'"`, are
+// set to their default instead when their column is NULL, and when their column is
+// missing from the result set and they hold the zero value, as for the columns of a
+// LEFT JOIN. String defaults are quoted as in SQL, so that CreateTableSQL can use them.
+//
+// An error is returned if dest is not a non-nil pointer to a struct. Scan is the same
+// as ScanInto.
 func Scan(dest interface{}, rows Rows) error {
-	return doScan(dest, rows, "")
+	return ScanInto(dest, rows)
+}
+
+// MustScan is like Scan but panics if the row cannot be scanned.
+func MustScan(dest interface{}, rows Rows) {
+	if err := Scan(dest, rows); err != nil {
+		panic(err)
+	}
+}
+
+// ScanStrict works like Scan, except that it returns a *MappingError if the result has
+// columns which are not mapped to any field of dest, or if dest has fields with no
+// matching column. Nothing is scanned in that case. It is meant to catch mistyped tags
+// and queries which have drifted from their struct types, for example in tests.
+func ScanStrict(dest interface{}, rows Rows) error {
+	return defaultHandle.doScan(context.Background(), dest, rows, "", true, false)
+}
+
+// MappingError is returned by ScanStrict and the Strict option when the columns of a
+// result do not match the fields of the destination exactly.
+type MappingError struct {
+	Type     reflect.Type // the type of the destination struct
+	Unmapped []string     // columns not mapped to any field
+	Unfilled []string     // columns of fields missing from the result
+}
+
+func (e *MappingError) Error() string {
+	var msg []string
+	if len(e.Unmapped) > 0 {
+		msg = append(msg, fmt.Sprintf("columns %s are not mapped to any field", strings.Join(e.Unmapped, ", ")))
+	}
+	if len(e.Unfilled) > 0 {
+		msg = append(msg, fmt.Sprintf("fields for columns %s have no column in the result", strings.Join(e.Unfilled, ", ")))
+	}
+	return fmt.Sprintf("sqlstruct: scanning into %s: %s", e.Type, strings.Join(msg, "; "))
+}
+
+// ScanColumns works like Scan for row sources which cannot report their columns, such
+// as *sql.Row. The names of the columns in the row are given by columns instead.
+//
+// For example:
+//
+//	row := db.QueryRow("SELECT id, name FROM users WHERE id = ?", 1)
+//	err := sqlstruct.ScanColumns(&user, row, []string{"id", "name"})
+func ScanColumns(dest interface{}, row Scannable, columns []string) error {
+	return defaultHandle.doScan(context.Background(), dest, columnRows{row, columns}, "", false, false)
 }
 
 // ScanAliased works like scan, except that it expects the results in the query to be
-// prefixed by the given alias.
+// prefixed by the given alias and AliasSeparator.
 //
 // For example, if scanning to a field named "name" with an alias of "user" it will
-// expect to find the result in a column named "user_name".
+// expect to find the result in a column named "user_name". Columns without the prefix
+// are matched to fields by their whole name.
 //
 // See ColumnAliased for a convenient way to generate these queries.
 func ScanAliased(dest interface{}, rows Rows, alias string) error {
-	return doScan(dest, rows, alias)
+	return ScanAliasedWith(dest, rows, alias, AliasFormat{})
+}
+
+// ScanAliasedWith works like ScanAliased for columns named with f, as generated by
+// ColumnsAliasedWith.
+func ScanAliasedWith(dest interface{}, rows Rows, alias string, f AliasFormat) error {
+	return defaultHandle.doScan(context.Background(), dest, rows, f.prefix(alias), false, false)
+}
+
+// FieldIndexByColumn returns the index sequence of the field of the struct type T that
+// Scan stores the given column in, suitable for reflect.Value.FieldByIndex. Fields of
+// embedded structs have an index sequence of more than one element. The second result
+// is false if no field is mapped to the column.
+func FieldIndexByColumn[T any](column string) ([]int, bool) {
+	fields, err := getFieldInfo(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, false
+	}
+	f, ok := fields[strings.ToLower(column)]
+	if !ok {
+		return nil, false
+	}
+	return append([]int(nil), f.index...), true
+}
+
+// PrimaryKeyColumns returns the sorted names of the columns of the fields of T tagged
+// with the "pk" option, or nil if there are none. Like Columns, it panics if T cannot
+// be mapped to columns.
+func PrimaryKeyColumns[T any]() []string {
+	fields, err := getFieldInfo(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		panic(err)
+	}
+	var cols []string
+	for _, f := range fields.primaryKeys() {
+		cols = append(cols, f.name)
+	}
+	return cols
 }
 
 // Columns returns a string containing a sorted, comma-separated list of column names as
 // defined by the type s. s must be a struct that has exported fields tagged with the "sql" tag.
+// Columns panics if s has a field which cannot be mapped to a column.
 func Columns(s interface{}) string {
-	return strings.Join(cols(s), ", ")
+	return defaultHandle.Columns(s)
+}
+
+// MustColumns returns the columns of the struct type T, as Columns does for a value
+// of T. Like Columns, it panics if T cannot be mapped to columns.
+func MustColumns[T any]() string {
+	return defaultHandle.mustColumnList(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// ColumnsOf works like Columns for a type known only at runtime. t must be a struct
+// type or a pointer to one.
+func ColumnsOf(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return defaultHandle.mustColumnList(t)
+}
+
+// ScanInto scans the next row from rows into the struct pointed to by dest, as
+// described for Scan, for callers whose types are only known at runtime, such as
+// plugins or admin tools. Together with ColumnsOf it works without instantiating any
+// generic function:
+//
+//	query := "SELECT " + sqlstruct.ColumnsOf(t) + " FROM " + table
+//	...
+//	dest := reflect.New(t)
+//	err := sqlstruct.ScanInto(dest.Interface(), rows)
+func ScanInto(dest interface{}, rows Rows) error {
+	return defaultHandle.Scan(dest, rows)
+}
+
+// ColumnsList returns the sorted column names of the struct type T, as listed by
+// Columns. Like Columns, it panics if T cannot be mapped to columns.
+func ColumnsList[T any]() []string {
+	fields, err := getFieldInfo(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		panic(err)
+	}
+	return fields.columns()
+}
+
+// Values returns the values of the fields of v, a struct or a pointer to one, in the
+// order of the columns listed by Columns, for building statements by hand:
+//
+//	cols, values := sqlstruct.ColumnsAndValues(user)
+//	marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+//	_, err := db.Exec("INSERT INTO users ("+cols+") VALUES ("+marks+")", values...)
+//
+// Fields implementing driver.Valuer, including those converted by tag options or
+// registered Converters, are replaced by their values. A Valuer which fails is kept as
+// is, so that its error is reported when the values are used as arguments. Like
+// Columns, Values panics if v cannot be mapped to columns.
+func Values[T any](v T) []interface{} {
+	_, values := ColumnsAndValues(v)
+	return values
+}
+
+// ColumnsAndValues returns both the columns of v, as Columns does, and their values,
+// as Values does.
+func ColumnsAndValues[T any](v T) (string, []interface{}) {
+	rv, err := structOf(v)
+	if err != nil {
+		panic(err)
+	}
+	fields, err := getFieldInfo(rv.Type())
+	if err != nil {
+		panic(err)
+	}
+	names := fields.columns()
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = fields[name].value(rv)
+		if d, ok := values[i].(driver.Valuer); ok {
+			if dv, err := d.Value(); err == nil {
+				values[i] = dv
+			}
+		}
+	}
+	return defaultHandle.mustColumnList(rv.Type()), values
+}
+
+// ColumnsExcept works like Columns for the struct type T but leaves out the given
+// columns, for example to avoid selecting large blobs:
+//
+//	query := "SELECT " + sqlstruct.ColumnsExcept[Document]("body") + " FROM documents"
+//
+// It panics if one of names is not a column of T.
+func ColumnsExcept[T any](names ...string) string {
+	cols := ColumnsList[T]()
+	except := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if !containsString(cols, name) {
+			panic(fmt.Errorf("sqlstruct: %v has no column %q", reflect.TypeOf((*T)(nil)).Elem(), name))
+		}
+		except[name] = true
+	}
+	kept := cols[:0]
+	for _, col := range cols {
+		if !except[col] {
+			kept = append(kept, col)
+		}
+	}
+	return strings.Join(defaultHandle.quoteAll(kept), ", ")
+}
+
+// ColumnsOnly works like Columns for the struct type T but lists only the columns of
+// the named fields, in the order given. Fields of embedded structs are named as
+// promoted fields, and those of nested structs tagged with the "prefix" option by
+// their path, such as "Home.City". This lets one type serve queries selecting only
+// part of its fields:
+//
+//	query := "SELECT " + sqlstruct.ColumnsOnly[User]("ID", "Name") + " FROM users"
+//
+// Scan leaves the fields of T without a column in the result unchanged. The Only
+// option selects the same columns in the queries run by Query and QueryRow.
+// ColumnsOnly panics if one of fields is not a field of T mapped to a column.
+func ColumnsOnly[T any](fields ...string) string {
+	cols, err := defaultHandle.fieldColumns(reflect.TypeOf((*T)(nil)).Elem(), fields)
+	if err != nil {
+		panic(err)
+	}
+	return strings.Join(defaultHandle.quoteAll(cols), ", ")
+}
+
+// fieldColumns returns the columns of the named fields of the struct type typ.
+func (h *Handle) fieldColumns(typ reflect.Type, fields []string) ([]string, error) {
+	finfo, err := h.getFieldInfo(typ)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string, len(finfo))
+	for name, f := range finfo {
+		if !f.writeOnly {
+			paths[fieldPath(typ, f.index)] = name
+		}
+	}
+	cols := make([]string, len(fields))
+	for i, name := range fields {
+		col, ok := paths[name]
+		if !ok {
+			return nil, fmt.Errorf("sqlstruct: %v has no field %q mapped to a column", typ, name)
+		}
+		cols[i] = col
+	}
+	return cols, nil
+}
+
+// fieldPath returns the name of the field of the struct type typ with the given
+// index sequence, leaving out embedded structs as for promoted fields.
+func fieldPath(typ reflect.Type, index []int) string {
+	parts := make([]string, 0, len(index))
+	for i, x := range index {
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		f := typ.Field(x)
+		if !f.Anonymous || i == len(index)-1 {
+			parts = append(parts, f.Name)
+		}
+		typ = f.Type
+	}
+	return strings.Join(parts, ".")
+}
+
+// containsString reports whether s is one of list.
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AliasSeparator separates the alias from the column name in the result columns named
+// by ColumnsAliased and expected by ScanAliased. A separator which does not occur in
+// column names, such as "__", avoids ambiguities with columns whose names contain
+// the alias and an underscore.
+var AliasSeparator = "_"
+
+// AliasFormat configures the naming of aliased columns by ColumnsAliasedWith and
+// ScanAliasedWith. The zero AliasFormat names them as ColumnsAliased does.
+type AliasFormat struct {
+	// Separator separates the alias from the column name in the result columns. If
+	// empty, AliasSeparator is used.
+	Separator string
+
+	// Quote quotes the alias and the result column names with Quoter, in addition to
+	// the column names, which are always quoted.
+	Quote bool
+}
+
+// prefix returns the prefix of the result columns aliased with alias, or an empty
+// string if alias is empty.
+func (f AliasFormat) prefix(alias string) string {
+	if alias == "" {
+		return ""
+	}
+	if f.Separator == "" {
+		return alias + AliasSeparator
+	}
+	return alias + f.Separator
+}
+
+// aliasPrefix returns the prefix of the columns aliased with alias, or an empty string
+// if alias is empty.
+func aliasPrefix(alias string) string {
+	return AliasFormat{}.prefix(alias)
 }
 
 // ColumnsAliased works like Columns except it prefixes the resulting column name with the
-// given alias.
+// given alias and AliasSeparator.
 //
 // For each field in the given struct it will generate a statement like:
 //    alias.field AS alias_field
 //
 // It is intended to be used in conjunction with the ScanAliased function.
 func ColumnsAliased(s interface{}, alias string) string {
+	return ColumnsAliasedWith(s, alias, AliasFormat{})
+}
+
+// ColumnsAliasedWith works like ColumnsAliased with the naming given by f. For
+// example, with a Quoter for Postgres and AliasFormat{Separator: "__", Quote: true}
+// it generates statements like:
+//
+//	"alias"."field" AS "alias__field"
+//
+// The rows are scanned with ScanAliasedWith and the same f.
+func ColumnsAliasedWith(s interface{}, alias string, f AliasFormat) string {
 	names := cols(s)
+	qualifier, prefix := alias, f.prefix(alias)
+	if f.Quote {
+		qualifier = defaultHandle.quote(alias)
+	}
 	aliased := make([]string, 0, len(names))
 	for _, n := range names {
-		aliased = append(aliased, alias+"."+n+" AS "+alias+"_"+n)
+		as := prefix + n
+		if f.Quote {
+			as = defaultHandle.quote(as)
+		}
+		aliased = append(aliased, qualifier+"."+defaultHandle.quote(n)+" AS "+as)
 	}
 	return strings.Join(aliased, ", ")
 }
 
 func cols(s interface{}) []string {
 	v := reflect.ValueOf(s)
-	fields := getFieldInfo(v.Type())
+	fields, err := defaultHandle.getFieldInfo(v.Type())
+	if err != nil {
+		panic(err)
+	}
+	return fields.columns()
+}
+
+// columnList returns the comma-separated column names of the struct type typ.
+func (h *Handle) columnList(typ reflect.Type) (string, error) {
+	key := columnKey{h.fieldKey(typ), funcID(h.quoter())}
+	if cols, ok := h.columnLists.Load(key); ok {
+		return cols.(string), nil
+	}
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return "", err
+	}
+	cols := strings.Join(h.quoteAll(fields.columns()), ", ")
+	h.columnLists.Store(key, cols)
+	return cols, nil
+}
 
-	names := make([]string, 0, len(fields))
-	for f := range fields {
+// mustColumnList is like columnList but panics if typ cannot be mapped to columns.
+func (h *Handle) mustColumnList(typ reflect.Type) string {
+	cols, err := h.columnList(typ)
+	if err != nil {
+		panic(err)
+	}
+	return cols
+}
+
+// names returns the sorted column names of the fields in fi.
+func (fi fieldInfo) names() []string {
+	names := make([]string, 0, len(fi))
+	for f := range fi {
 		names = append(names, f)
 	}
 
@@ -223,59 +953,245 @@ func cols(s interface{}) []string {
 	return names
 }
 
-func doScan(dest interface{}, rows Rows, alias string) error {
-	destv := reflect.ValueOf(dest)
-	typ := destv.Type()
+// columns returns the sorted column names of the fields in fi which are read from
+// query results, that is all fields except those tagged with the "writeonly" option.
+func (fi fieldInfo) columns() []string {
+	names := make([]string, 0, len(fi))
+	for _, name := range fi.names() {
+		if !fi[name].writeOnly {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// versionField returns the field tagged with the "version" option, or nil if there is
+// none.
+func (fi fieldInfo) versionField() *field {
+	for _, name := range fi.names() {
+		if f := fi[name]; f.version {
+			return f
+		}
+	}
+	return nil
+}
+
+// needsPlan reports whether some of the fields of fi have a default value or are
+// times parsed with the TimeLayouts of the Handle, which the methods of a
+// ColumnMapper do not apply.
+func (fi fieldInfo) needsPlan() bool {
+	for _, f := range fi {
+		if f.def.IsValid() || f.layouts != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// softDeleteField returns the field tagged with the "softdelete" option, or nil if
+// there is none.
+func (fi fieldInfo) softDeleteField() *field {
+	for _, name := range fi.names() {
+		if f := fi[name]; f.softDelete {
+			return f
+		}
+	}
+	return nil
+}
+
+// notDeleted returns the condition selecting the rows of fi which are not soft
+// deleted, or an empty string if fi has no field tagged with the "softdelete" option.
+func (h *Handle) notDeleted(fi fieldInfo) string {
+	if f := fi.softDeleteField(); f != nil {
+		return h.quote(f.name) + " IS NULL"
+	}
+	return ""
+}
 
-	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
-		panic(fmt.Errorf("dest must be pointer to struct; got %T", destv))
+// primaryKeys returns the fields tagged with the "pk" option, sorted by column name.
+func (fi fieldInfo) primaryKeys() []*field {
+	var pks []*field
+	for _, name := range fi.names() {
+		if f := fi[name]; f.pk {
+			pks = append(pks, f)
+		}
 	}
-	fieldInfo := getFieldInfo(typ.Elem())
+	return pks
+}
 
-	elem := destv.Elem()
-	var values []interface{}
+// doScan implements Scan and its variants. prefix is removed from the names of the
+// columns of aliased results. If strict is true, columns which are not
+// mapped to any field and fields with no column are reported as a *MappingError
+// instead of being ignored.
+func (h *Handle) doScan(ctx context.Context, dest interface{}, rows Rows, prefix string, strict, nullToZero bool) error {
+	err := h.scanFields(dest, rows, prefix, strict, nullToZero)
+	if err == nil {
+		err = afterScan(ctx, dest)
+	}
+	if typ := reflect.TypeOf(dest); err != nil && typ != nil {
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		h.scanFailed(typ, err)
+	}
+	return err
+}
+
+func (h *Handle) scanFields(dest interface{}, rows Rows, prefix string, strict, nullToZero bool) error {
+	destv, err := structDest(dest)
+	if err != nil {
+		return err
+	}
+	typ := destv.Type()
+	fieldInfo, err := h.getFieldInfo(typ.Elem())
+	if err != nil {
+		return err
+	}
+	nullToZero = nullToZero || h.NullToZero
+	if m, ok := dest.(ColumnMapper); ok && !strict && !nullToZero && !fieldInfo.needsPlan() {
+		err := scanMapper(m, rows, prefix)
+		if err != nil {
+			if cols, cerr := rows.Columns(); cerr == nil {
+				err = h.scanPlan(typ.Elem(), fieldInfo, cols, prefix).scanErr(typ.Elem(), cols, err)
+			}
+		}
+		return err
+	}
 
 	cols, err := rows.Columns()
 	if err != nil {
 		return err
 	}
 
+	plan := h.scanPlan(typ.Elem(), fieldInfo, cols, prefix)
+	if strict && plan.err != nil {
+		return plan.err
+	}
+	vp := getValues(len(cols))
+	defer putValues(vp)
+	plan.fill(*vp, destv.Elem(), nullToZero)
+	if err := rows.Scan(*vp...); err != nil {
+		return plan.scanErr(typ.Elem(), cols, err)
+	}
+	return nil
+}
+
+// structDest returns the value of dest, which must be a non-nil pointer to a struct.
+func structDest(dest interface{}) (reflect.Value, error) {
+	destv := reflect.ValueOf(dest)
+	if !destv.IsValid() || destv.Kind() != reflect.Ptr || destv.Type().Elem().Kind() != reflect.Struct {
+		return destv, fmt.Errorf("sqlstruct: dest must be pointer to struct; got %T", dest)
+	}
+	if destv.IsNil() {
+		return destv, fmt.Errorf("sqlstruct: dest must be a non-nil pointer to struct; got nil %T", dest)
+	}
+	return destv, nil
+}
+
+// checkMapping returns a *MappingError if some of the columns of a result, or some
+// of the fields in fieldInfo, have no counterpart.
+func checkMapping(typ reflect.Type, fieldInfo fieldInfo, cols []string, prefix string, unmapped []string) error {
+	seen := make(map[string]bool, len(cols))
 	for _, name := range cols {
-		if len(alias) > 0 {
-			name = strings.Replace(name, alias+"_", "", 1)
-		}
-		idx, ok := fieldInfo[strings.ToLower(name)]
-		var v interface{}
-		if !ok {
-			// There is no field mapped to this column so we discard it
-			v = &sql.RawBytes{}
-		} else {
-			v = elem.FieldByIndex(idx).Addr().Interface()
+		seen[strings.ToLower(strings.TrimPrefix(name, prefix))] = true
+	}
+	var unfilled []string
+	for _, name := range fieldInfo.columns() {
+		if !seen[name] {
+			unfilled = append(unfilled, name)
 		}
-		values = append(values, v)
 	}
+	if len(unmapped) == 0 && len(unfilled) == 0 {
+		return nil
+	}
+	return &MappingError{Type: typ, Unmapped: unmapped, Unfilled: unfilled}
+}
 
-	return rows.Scan(values...)
+// Initialisms lists the initialisms known to ToSnakeCase and ToCamelCase, in upper
+// case. It may be extended, before any struct type is mapped, with the initialisms
+// used in field names, as field mappings are cached.
+var Initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "LHS": true, "QPS": true, "RAM": true, "RHS": true,
+	"RPC": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true,
+	"URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true, "XMPP": true,
+	"XSRF": true, "XSS": true,
 }
 
 // ToSnakeCase converts a string to snake case, words separated with underscores.
 // It's intended to be used with NameMapper to map struct field names to snake case database fields.
+//
+// A run of capitals is an acronym, except for its last letter if a lowercase letter
+// follows, so UserID becomes user_id and HTTPStatus becomes http_status. Digits belong
+// to the word before them, as in address2_line. The plural of an initialism listed in
+// Initialisms is kept as one word, so that UserIDs becomes user_ids.
 func ToSnakeCase(src string) string {
-	thisUpper := false
-	prevUpper := false
+	return strings.ToLower(strings.Join(splitWords(src), "_"))
+}
 
-	buf := bytes.NewBufferString("")
-	for i, v := range src {
-		if v >= 'A' && v <= 'Z' {
-			thisUpper = true
-		} else {
-			thisUpper = false
+// ToCamelCase converts a snake case string to camel case, the inverse of ToSnakeCase.
+// It is intended for tools which generate struct field names from column names. Words
+// listed in Initialisms, and their plurals, are written in capitals, so that user_id
+// becomes UserID and url_ids becomes URLIDs.
+func ToCamelCase(src string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(src, "_") {
+		if word == "" {
+			continue
 		}
-		if i > 0 && thisUpper && !prevUpper {
-			buf.WriteRune('_')
+		upper := strings.ToUpper(word)
+		switch {
+		case Initialisms[upper]:
+			b.WriteString(upper)
+		case len(word) > 1 && word[len(word)-1] == 's' && Initialisms[upper[:len(upper)-1]]:
+			b.WriteString(upper[:len(upper)-1] + "s")
+		default:
+			r, size := utf8.DecodeRuneInString(word)
+			b.WriteRune(unicode.ToUpper(r))
+			b.WriteString(word[size:])
+		}
+	}
+	return b.String()
+}
+
+// splitWords splits a mixed case name into words, as described for ToSnakeCase.
+// Underscores, spaces and hyphens also separate words.
+func splitWords(src string) []string {
+	runes := []rune(src)
+	var words []string
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(runes[start:end]))
+		}
+		start = end
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '_' || r == ' ' || r == '-':
+			flush(i)
+			start = i + 1
+		case unicode.IsUpper(r) && i > start:
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				flush(i)
+				break
+			}
+			if i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				// The end of an acronym: HTTPStatus splits before the S, unless
+				// the lowercase letter makes a plural initialism, as in IDs.
+				if runes[i+1] == 's' && (i+2 == len(runes) || !unicode.IsLower(runes[i+2])) &&
+					Initialisms[strings.ToUpper(string(runes[start:i+1]))] {
+					i++
+					break
+				}
+				flush(i)
+			}
 		}
-		prevUpper = thisUpper
-		buf.WriteRune(v)
 	}
-	return strings.ToLower(buf.String())
+	flush(len(runes))
+	return words
 }
@@ -4,8 +4,13 @@
 package sqlstruct
 
 import (
+	"bytes"
+	"database/sql"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 type EmbeddedType struct {
@@ -38,9 +43,13 @@ func (r testRows) Scan(dest ...interface{}) error {
 			panic("Not a pointer!")
 		}
 
-		switch dest[i].(type) {
+		switch d := dest[i].(type) {
 		case *string:
-			*(dest[i].(*string)) = r.values[i].(string)
+			*d = r.values[i].(string)
+		case sql.Scanner:
+			if err := d.Scan(r.values[i]); err != nil {
+				return err
+			}
 		default:
 			// Do nothing. We assume the tests only use strings here
 		}
@@ -67,6 +76,103 @@ func TestColumns(t *testing.T) {
 	}
 }
 
+func TestColumnsList(t *testing.T) {
+	expected := []string{"field_a", "field_c", "field_d", "field_e"}
+	if cols := ColumnsList[testType](); !reflect.DeepEqual(cols, expected) {
+		t.Errorf("expected %v got %v", expected, cols)
+	}
+}
+
+func TestValues(t *testing.T) {
+	v := testType{FieldA: "a", FieldB: "b", FieldC: "c", Field_D: "d", EmbeddedType: EmbeddedType{"e"}}
+	expected := []interface{}{"a", "c", "d", "e"}
+	if values := Values(v); !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v got %v", expected, values)
+	}
+
+	type note struct {
+		Id   int            `sql:"id"`
+		Body sql.NullString `sql:"body"`
+		Tags []string       `sql:"tags,split=;"`
+	}
+	cols, values := ColumnsAndValues(&note{1, sql.NullString{}, []string{"x", "y"}})
+	if cols != "body, id, tags" {
+		t.Errorf("expected columns %q got %q", "body, id, tags", cols)
+	}
+	if expected := []interface{}{nil, 1, "x;y"}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v got %v", expected, values)
+	}
+}
+
+func TestColumnsExcept(t *testing.T) {
+	if c, e := ColumnsExcept[testType]("field_a", "FIELD_D"), "field_c, field_e"; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c, e := ColumnsExcept[testType](), "field_a, field_c, field_d, field_e"; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unknown column")
+		}
+	}()
+	ColumnsExcept[testType]("missing")
+}
+
+func TestColumnsOnly(t *testing.T) {
+	if c, e := ColumnsOnly[testType]("FieldE", "FieldA"), "field_e, field_a"; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	type address struct {
+		City string `sql:"city"`
+	}
+	type customer struct {
+		Home *address `sql:"home,prefix"`
+	}
+	if c, e := ColumnsOnly[customer]("Home.City"), "home_city"; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	for _, field := range []string{"FieldB", "Missing", "EmbeddedType.FieldE"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for field %q", field)
+				}
+			}()
+			ColumnsOnly[testType](field)
+		}()
+	}
+}
+
+func TestColumnsOf(t *testing.T) {
+	e := "field_a, field_c, field_d, field_e"
+	for _, typ := range []reflect.Type{reflect.TypeOf(testType{}), reflect.TypeOf(&testType{})} {
+		if c := ColumnsOf(typ); c != e {
+			t.Errorf("expected %q got %q", e, c)
+		}
+	}
+}
+
+func TestScanInto(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_c", "c")
+
+	typ := reflect.TypeOf(testType{})
+	dest := reflect.New(typ)
+	if err := ScanInto(dest.Interface(), rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v := dest.Elem().Interface().(testType); v.FieldA != "a" || v.FieldC != "c" {
+		t.Errorf("unexpected result %+v", v)
+	}
+	if err := ScanInto(dest.Elem().Interface(), rows); err == nil {
+		t.Error("expected error for a struct which is not a pointer")
+	}
+}
+
 func TestColumnsAliased(t *testing.T) {
 	var t1 testType
 	var t2 testType2
@@ -108,6 +214,66 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestScanAliasedSeparator(t *testing.T) {
+	type event struct {
+		DataAt string `sql:"data_at"`
+		At     string `sql:"at"`
+	}
+	rows := testRows{}
+	rows.addValue("data_at", "x")
+	rows.addValue("a_at", "y")
+	var e event
+	if err := ScanAliased(&e, rows, "a"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := (event{"x", "y"}); e != expected {
+		t.Errorf("expected %v got %v", expected, e)
+	}
+
+	defer func() { AliasSeparator = "_" }()
+	AliasSeparator = "__"
+	if c, expected := ColumnsAliased(e, "a"), "a.at AS a__at, a.data_at AS a__data_at"; c != expected {
+		t.Errorf("expected %q got %q", expected, c)
+	}
+	rows = testRows{}
+	rows.addValue("a__data_at", "z")
+	rows.addValue("a_at", "w")
+	e = event{}
+	if err := ScanAliased(&e, rows, "a"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := (event{DataAt: "z"}); e != expected {
+		t.Errorf("expected %v got %v", expected, e)
+	}
+}
+
+func TestAliasFormat(t *testing.T) {
+	type event struct {
+		DataAt string `sql:"data_at"`
+		At     string `sql:"at"`
+	}
+	defer func(q func(string) string) { Quoter = q }(Quoter)
+	Quoter = func(s string) string { return `"` + s + `"` }
+	f := AliasFormat{Separator: "__", Quote: true}
+	if c, expected := ColumnsAliasedWith(event{}, "a", f), `"a"."at" AS "a__at", "a"."data_at" AS "a__data_at"`; c != expected {
+		t.Errorf("expected %q got %q", expected, c)
+	}
+	if c, expected := ColumnsAliasedWith(event{}, "a", AliasFormat{}), ColumnsAliased(event{}, "a"); c != expected {
+		t.Errorf("expected %q got %q", expected, c)
+	}
+
+	rows := testRows{}
+	rows.addValue("a__data_at", "x")
+	rows.addValue("a__at", "y")
+	var e event
+	if err := ScanAliasedWith(&e, rows, "a", f); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := (event{"x", "y"}); e != expected {
+		t.Errorf("expected %v got %v", expected, e)
+	}
+}
+
 func TestScanAliased(t *testing.T) {
 	rows := testRows{}
 	rows.addValue("t1_field_a", "a")
@@ -142,6 +308,58 @@ func TestScanAliased(t *testing.T) {
 	}
 }
 
+func TestScanIntern(t *testing.T) {
+	type internType struct {
+		Status string `sql:"status,intern"`
+	}
+
+	var a, b internType
+	for _, dest := range []*internType{&a, &b} {
+		rows := testRows{}
+		rows.addValue("status", []byte("active"))
+		if err := Scan(dest, rows); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if a.Status != "active" || b.Status != "active" {
+		t.Errorf("expected active got %q and %q", a.Status, b.Status)
+	}
+	if unsafe.StringData(a.Status) != unsafe.StringData(b.Status) {
+		t.Error("expected interned strings to share storage")
+	}
+}
+
+// upperBytes is a []byte type which converts values to upper case when scanned.
+type upperBytes []byte
+
+func (u *upperBytes) Scan(src interface{}) error {
+	*u = bytes.ToUpper(src.([]byte))
+	return nil
+}
+
+func TestScanRaw(t *testing.T) {
+	type rawType struct {
+		Converted upperBytes `sql:"converted"`
+		Raw       upperBytes `sql:"raw,raw"`
+	}
+
+	rows := testRows{}
+	rows.addValue("converted", []byte("abc"))
+	rows.addValue("raw", []byte("abc"))
+
+	var r rawType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(r.Converted) != "ABC" {
+		t.Errorf("expected ABC got %q", r.Converted)
+	}
+	if string(r.Raw) != "abc" {
+		t.Errorf("expected abc got %q", r.Raw)
+	}
+}
+
 func TestToSnakeCase(t *testing.T) {
 	var s string
 	s = ToSnakeCase("FirstName")
@@ -159,3 +377,477 @@ func TestToSnakeCase(t *testing.T) {
 		t.Errorf("expected first_name got %q", s)
 	}
 }
+
+func TestToSnakeCaseInitialisms(t *testing.T) {
+	for name, expected := range map[string]string{
+		"UserID":       "user_id",
+		"UserIDs":      "user_ids",
+		"HTTPStatus":   "http_status",
+		"URLsByHost":   "urls_by_host",
+		"Address2Line": "address2_line",
+		"Field_D":      "field_d",
+		"ID":           "id",
+		"XMLHTTPReq":   "xmlhttp_req",
+	} {
+		if s := ToSnakeCase(name); s != expected {
+			t.Errorf("%s: expected %q got %q", name, expected, s)
+		}
+	}
+}
+
+func TestToCamelCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"user_id":       "UserID",
+		"user_ids":      "UserIDs",
+		"http_status":   "HTTPStatus",
+		"address2_line": "Address2Line",
+		"first_name":    "FirstName",
+		"__odd__name":   "OddName",
+	} {
+		if s := ToCamelCase(name); s != expected {
+			t.Errorf("%s: expected %q got %q", name, expected, s)
+		}
+		if s := ToSnakeCase(expected); s != strings.Trim(strings.ReplaceAll(name, "__", "_"), "_") {
+			t.Errorf("%s: expected %q to round trip, got %q", name, expected, s)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(testType{}, &testType2{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := Register(42); err == nil {
+		t.Error("expected error registering int")
+	}
+}
+
+func TestUnsupportedField(t *testing.T) {
+	type chanType struct {
+		FieldA string `sql:"field_a"`
+		Events chan int
+	}
+	type internType struct {
+		Count int `sql:"count,intern"`
+	}
+
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	for _, dest := range []interface{}{&chanType{}, &internType{}} {
+		err := Scan(dest, rows)
+		if err == nil {
+			t.Errorf("expected error scanning into %T", dest)
+			continue
+		}
+		if name := reflect.TypeOf(dest).Elem().String(); !strings.Contains(err.Error(), name) {
+			t.Errorf("expected error naming %s got %q", name, err)
+		}
+	}
+
+	if err := Register(chanType{}); err == nil {
+		t.Error("expected error registering chanType")
+	}
+}
+
+func TestFieldIndexByColumn(t *testing.T) {
+	tests := []struct {
+		column string
+		index  []int
+		ok     bool
+	}{
+		{"field_a", []int{0}, true},
+		{"FIELD_C", []int{2}, true},
+		{"field_e", []int{4, 0}, true},
+		{"field_b", nil, false},
+	}
+	for _, test := range tests {
+		index, ok := FieldIndexByColumn[testType](test.column)
+		if ok != test.ok || !reflect.DeepEqual(index, test.index) {
+			t.Errorf("%s: expected %v, %t got %v, %t", test.column, test.index, test.ok, index, ok)
+		}
+	}
+}
+
+func TestScanTimeFormat(t *testing.T) {
+	type event struct {
+		Date    time.Time  `sql:"date,timeformat=2006-01-02"`
+		Expires *time.Time `sql:"expires,timeformat=2006-01-02"`
+	}
+
+	rows := testRows{}
+	rows.addValue("date", []byte("2020-03-04"))
+	rows.addValue("expires", nil)
+
+	var e event
+	if err := Scan(&e, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC); !e.Date.Equal(expected) {
+		t.Errorf("expected %s got %s", expected, e.Date)
+	}
+	if e.Expires != nil {
+		t.Errorf("expected nil got %s", e.Expires)
+	}
+
+	v, err := (&timeFormatValue{reflect.ValueOf(&e).Elem().Field(0), "2006-01-02"}).Value()
+	if err != nil || v != "2020-03-04" {
+		t.Errorf("expected 2020-03-04 got %v, %v", v, err)
+	}
+}
+
+func TestScanTimeLayouts(t *testing.T) {
+	type event struct {
+		Start time.Time  `sql:"start"`
+		End   *time.Time `sql:"end"`
+		Seen  time.Time  `sql:"seen"`
+	}
+
+	loc := time.FixedZone("X", 3600)
+	h := NewWithConfig(nil, Config{TimeLocation: loc})
+	rows := testRows{}
+	rows.addValue("start", []byte("2024-03-01 10:30:00"))
+	rows.addValue("end", "2024-03-01T11:00:00.5+00:00")
+	rows.addValue("seen", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	var e event
+	if err := h.Scan(&e, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := time.Date(2024, 3, 1, 10, 30, 0, 0, loc); !e.Start.Equal(expected) || e.Start.Location() != loc {
+		t.Errorf("expected %v got %v", expected, e.Start)
+	}
+	if expected := time.Date(2024, 3, 1, 11, 0, 0, 5e8, time.UTC); e.End == nil || !e.End.Equal(expected) || e.End.Location() != loc {
+		t.Errorf("expected %v got %v", expected, e.End)
+	}
+	if e.Seen.Location() != loc {
+		t.Errorf("expected time in %v got %v", loc, e.Seen)
+	}
+
+	h = NewWithConfig(nil, Config{TimeLayouts: []string{"02/01/2006"}})
+	rows = testRows{}
+	rows.addValue("start", "01/03/2024")
+	if err := h.Scan(&e, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !e.Start.Equal(expected) {
+		t.Errorf("expected %v got %v", expected, e.Start)
+	}
+	rows.values[0] = "2024-03-01"
+	if err := h.Scan(&e, rows); err == nil {
+		t.Error("expected error for time in another layout")
+	}
+}
+
+func TestScanSplit(t *testing.T) {
+	type tagged struct {
+		Tags  []string `sql:"tags,split=,"`
+		Path  []string `sql:"path,split=|"`
+		Empty []string `sql:"empty,split=,"`
+	}
+
+	rows := testRows{}
+	rows.addValue("tags", []byte("a,b,c"))
+	rows.addValue("path", "x|y")
+	rows.addValue("empty", "")
+
+	var r tagged
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r.Tags, []string{"a", "b", "c"}) {
+		t.Errorf("expected [a b c] got %q", r.Tags)
+	}
+	if !reflect.DeepEqual(r.Path, []string{"x", "y"}) {
+		t.Errorf("expected [x y] got %q", r.Path)
+	}
+	if r.Empty == nil || len(r.Empty) != 0 {
+		t.Errorf("expected empty slice got %#v", r.Empty)
+	}
+}
+
+func TestScanDefault(t *testing.T) {
+	type account struct {
+		Status  string    `sql:"status,default='active'"`
+		Plan    *string   `sql:"plan,default='free'"`
+		Limit   int       `sql:"limit,default=10"`
+		Tags    []string  `sql:"tags,split=;,default='a;b'"`
+		Created time.Time `sql:"created,default=CURRENT_TIMESTAMP"`
+	}
+
+	rows := testRows{}
+	rows.addValue("status", nil)
+	rows.addValue("plan", nil)
+	rows.addValue("tags", "x")
+
+	var a account
+	if err := Scan(&a, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a.Status != "active" || a.Plan == nil || *a.Plan != "free" || a.Limit != 10 {
+		t.Errorf("expected defaults got %+v", a)
+	}
+	if !reflect.DeepEqual(a.Tags, []string{"x"}) || !a.Created.IsZero() {
+		t.Errorf("expected only NULL and missing columns to get defaults got %+v", a)
+	}
+
+	rows = testRows{}
+	rows.addValue("status", "closed")
+	rows.addValue("tags", nil)
+	a = account{Limit: 5}
+	if err := Scan(&a, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a.Status != "closed" || a.Limit != 5 || !reflect.DeepEqual(a.Tags, []string{"a", "b"}) {
+		t.Errorf("unexpected result %+v", a)
+	}
+}
+
+func TestScanDefaultNilNested(t *testing.T) {
+	type Settings struct {
+		Theme string `sql:"theme,default='light'"`
+	}
+	type account struct {
+		Id        int       `sql:"id"`
+		Preferred *Settings `sql:"pref,prefix"`
+	}
+
+	rows := testRows{}
+	rows.addValue("id", "1")
+
+	var a account
+	if err := Scan(&a, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a.Preferred != nil {
+		t.Errorf("expected nil nested struct with no columns got %+v", a.Preferred)
+	}
+
+	a = account{Preferred: &Settings{}}
+	if err := Scan(&a, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a.Preferred.Theme != "light" {
+		t.Errorf("expected default in allocated nested struct got %+v", a.Preferred)
+	}
+}
+
+func TestTagOptions(t *testing.T) {
+	_, opts := parseTag("tags,split=,,pk,default=x")
+	if v, ok := opts.Value("split"); !ok || v != "," {
+		t.Errorf("expected split=, got %q, %t", v, ok)
+	}
+	if !opts.Contains("pk") {
+		t.Error("expected pk option")
+	}
+	if v, _ := opts.Value("default"); v != "x" {
+		t.Errorf("expected default=x got %q", v)
+	}
+}
+
+func TestScanStrict(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("extra", "b")
+
+	var s testType
+	err := ScanStrict(&s, rows)
+	merr, ok := err.(*MappingError)
+	if !ok {
+		t.Fatalf("expected *MappingError got %v", err)
+	}
+	if len(merr.Unmapped) != 1 || merr.Unmapped[0] != "extra" {
+		t.Errorf("expected unmapped [extra] got %v", merr.Unmapped)
+	}
+	if expected := []string{"field_c", "field_d", "field_e"}; !reflect.DeepEqual(merr.Unfilled, expected) {
+		t.Errorf("expected unfilled %v got %v", expected, merr.Unfilled)
+	}
+	if s.FieldA != "" {
+		t.Errorf("expected nothing to be scanned, got %q", s.FieldA)
+	}
+}
+
+func TestScanInvalidDest(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+
+	var s testType
+	var nilPtr *testType
+	var i int
+	for _, dest := range []interface{}{nil, s, nilPtr, &i} {
+		if err := Scan(dest, rows); err == nil {
+			t.Errorf("expected error for dest %T", dest)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustScan to panic")
+		}
+	}()
+	MustScan(s, rows)
+}
+
+func TestScanNestedPrefix(t *testing.T) {
+	type address struct {
+		City   string `sql:"city"`
+		Street string `sql:"street"`
+	}
+	type customer struct {
+		Name string  `sql:"name"`
+		Home address `sql:"home,prefix"`
+		Work address `sql:"work,prefix"`
+	}
+	if cols, expected := Columns(customer{}), "home_city, home_street, name, work_city, work_street"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+
+	rows := testRows{}
+	rows.addValue("name", "a")
+	rows.addValue("home_city", "Vilnius")
+	rows.addValue("work_street", "Plento")
+
+	var c customer
+	if err := Scan(&c, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Home.City != "Vilnius" || c.Work.Street != "Plento" {
+		t.Errorf("unexpected result %+v", c)
+	}
+
+	type invalid struct {
+		Name string `sql:"name,prefix"`
+	}
+	if err := Register(invalid{}); err == nil {
+		t.Error("expected error for prefix option on non-struct field")
+	}
+}
+
+func TestScanStructPointers(t *testing.T) {
+	type Address struct {
+		City string `sql:"city"`
+	}
+	type Audit struct {
+		Creator string `sql:"creator"`
+	}
+	type order struct {
+		Id int `sql:"id"`
+		*Audit
+		Shipping *Address `sql:"shipping,prefix"`
+		Billing  *Address `sql:"billing,prefix"`
+	}
+	if cols, expected := Columns(order{}), "billing_city, creator, id, shipping_city"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+
+	rows := testRows{}
+	rows.addValue("creator", "a")
+	rows.addValue("shipping_city", "Vilnius")
+
+	var o order
+	if err := Scan(&o, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if o.Audit == nil || o.Audit.Creator != "a" {
+		t.Errorf("expected embedded pointer to be allocated, got %+v", o.Audit)
+	}
+	if o.Shipping == nil || o.Shipping.City != "Vilnius" {
+		t.Errorf("expected nested pointer to be allocated, got %+v", o.Shipping)
+	}
+	if o.Billing != nil {
+		t.Errorf("expected nested pointer without columns to stay nil, got %+v", o.Billing)
+	}
+}
+
+type CycleA struct {
+	A string `sql:"a"`
+	*CycleB
+}
+
+type CycleB struct {
+	B string `sql:"b"`
+	*CycleA
+}
+
+type testTreeNode struct {
+	Id     int           `sql:"id"`
+	Parent *testTreeNode `sql:"parent,prefix"`
+}
+
+type Level3 struct {
+	Leaf string `sql:"leaf"`
+}
+
+type Level2 struct{ Level3 }
+
+type Level1 struct{ *Level2 }
+
+type testLevels struct {
+	Id int `sql:"id"`
+	Level1
+}
+
+func TestNestingLimits(t *testing.T) {
+	h := &Handle{}
+	_, err := h.getFieldInfo(reflect.TypeOf(CycleA{}))
+	if err == nil || !strings.Contains(err.Error(), "sqlstruct.CycleA -> sqlstruct.CycleB -> sqlstruct.CycleA") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+	if _, err := h.getFieldInfo(reflect.TypeOf(testTreeNode{})); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("expected cycle error for nested prefix, got %v", err)
+	}
+
+	finfo, err := h.getFieldInfo(reflect.TypeOf(testLevels{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if f := finfo["leaf"]; f == nil || !reflect.DeepEqual(f.index, []int{1, 0, 0, 0}) {
+		t.Errorf("unexpected field %+v", f)
+	}
+	rows := testRows{}
+	rows.addValue("leaf", "x")
+	var v testLevels
+	if err := h.Scan(&v, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.Level2 == nil || v.Leaf != "x" {
+		t.Errorf("unexpected result %+v", v)
+	}
+
+	defer func(depth int) { MaxNestingDepth = depth }(MaxNestingDepth)
+	MaxNestingDepth = 2
+	h = &Handle{}
+	if _, err := h.getFieldInfo(reflect.TypeOf(testLevels{})); err == nil || !strings.Contains(err.Error(), "more than 2 levels") {
+		t.Errorf("expected depth error, got %v", err)
+	}
+	MaxNestingDepth = 3
+	if _, err := h.getFieldInfo(reflect.TypeOf(testLevels{})); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestScanNullGeneric(t *testing.T) {
+	type event struct {
+		sql.Null[time.Time] `sql:"happened"`
+		Name                sql.Null[string] `sql:"name"`
+		Count               sql.NullInt64    `sql:"count"`
+	}
+	if cols, expected := Columns(event{}), "count, happened, name"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+
+	now := time.Now()
+	rows := testRows{}
+	rows.addValue("happened", now)
+	rows.addValue("name", nil)
+
+	var e event
+	if err := Scan(&e, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !e.Valid || !e.V.Equal(now) {
+		t.Errorf("expected %v got %+v", now, e.Null)
+	}
+	if e.Name.Valid {
+		t.Errorf("expected NULL name got %+v", e.Name)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+/*
+Package sqlstructtest provides fake result sets for testing code which scans rows
+with the sqlstruct package, without a database.
+
+Rows are built with the columns of the result, in the order the query would return
+them, and then the values of each row:
+
+	rows := sqlstructtest.NewRows("id", "name").
+		AddRow(int64(1), "gedi").
+		AddRowMap(map[string]interface{}{"name": "kamil", "id": int64(2)})
+
+	users, err := sqlstruct.SliceFromRows[User](rows)
+
+Errors can be injected into the iteration, the scanning of a given row, and the
+listing of the columns.
+*/
+package sqlstructtest
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// Rows is a fake result set. It implements sqlstruct.Rows and sqlstruct.RowsIter.
+// The methods building it return the Rows itself, so that calls can be chained.
+type Rows struct {
+	columns  []string
+	rows     [][]interface{}
+	rowErrs  map[int]error
+	scanErrs map[int]error
+	colErr   error
+	closeErr error
+	current  int
+	err      error
+	closed   bool
+}
+
+// NewRows returns an empty result set with the given columns.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns, rowErrs: make(map[int]error), scanErrs: make(map[int]error)}
+}
+
+// AddRow adds a row holding values, one for each column in order. Values are
+// normally those a driver returns, such as int64, float64, bool, []byte, string,
+// time.Time or nil for NULL, but values of any type can be given. It panics if the
+// number of values differs from the number of columns.
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("sqlstructtest: expected %d values, one for each column; got %d", len(r.columns), len(values)))
+	}
+	r.rows = append(r.rows, values)
+	return r
+}
+
+// AddRowMap adds a row holding the values of the columns in m. Columns missing from
+// m are NULL. It panics if m has a key which is not a column.
+func (r *Rows) AddRowMap(m map[string]interface{}) *Rows {
+	values := make([]interface{}, len(r.columns))
+	found := 0
+	for i, col := range r.columns {
+		if v, ok := m[col]; ok {
+			values[i] = v
+			found++
+		}
+	}
+	if found != len(m) {
+		panic(fmt.Sprintf("sqlstructtest: row has values for columns not in %v", r.columns))
+	}
+	return r.AddRow(values...)
+}
+
+// RowError makes the iteration stop with err before the row with the given index,
+// counting from 0, as if reading it from the database had failed. Next then returns
+// false and Err returns err.
+func (r *Rows) RowError(row int, err error) *Rows {
+	r.rowErrs[row] = err
+	return r
+}
+
+// ScanError makes Scan return err for the row with the given index, counting from 0.
+func (r *Rows) ScanError(row int, err error) *Rows {
+	r.scanErrs[row] = err
+	return r
+}
+
+// ColumnsError makes Columns return err.
+func (r *Rows) ColumnsError(err error) *Rows {
+	r.colErr = err
+	return r
+}
+
+// CloseError makes Close return err.
+func (r *Rows) CloseError(err error) *Rows {
+	r.closeErr = err
+	return r
+}
+
+// Closed reports whether Close has been called.
+func (r *Rows) Closed() bool {
+	return r.closed
+}
+
+// Columns returns the names of the columns.
+func (r *Rows) Columns() ([]string, error) {
+	if r.colErr != nil {
+		return nil, r.colErr
+	}
+	return r.columns, nil
+}
+
+// Next advances to the next row, returning false at the end of the rows, after an
+// injected row error or once the Rows is closed.
+func (r *Rows) Next() bool {
+	if r.closed || r.err != nil || r.current >= len(r.rows) {
+		return false
+	}
+	if err, ok := r.rowErrs[r.current]; ok {
+		r.err = err
+		return false
+	}
+	r.current++
+	return true
+}
+
+// Err returns the error injected with RowError, once the iteration has reached it.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close closes the Rows. It returns the error given to CloseError, if any.
+func (r *Rows) Close() error {
+	r.closed = true
+	return r.closeErr
+}
+
+// Scan stores the values of the current row in dest, which must hold a pointer for
+// each column. Values are stored as is in destinations of their type or of
+// interface{}, given to sql.Scanner destinations, and converted between numeric
+// types and between strings and byte slices. Pointer destinations are allocated, or
+// set to nil for NULL. Unlike database/sql, strings are not parsed into numbers or
+// times; such values should be given with the type the driver would return.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if r.closed {
+		return fmt.Errorf("sqlstructtest: Scan called on closed rows")
+	}
+	if r.current == 0 {
+		return fmt.Errorf("sqlstructtest: Scan called without calling Next")
+	}
+	row := r.current - 1
+	if err, ok := r.scanErrs[row]; ok {
+		return err
+	}
+	if len(dest) != len(r.columns) {
+		return fmt.Errorf("sqlstructtest: expected %d destination arguments in Scan, not %d", len(r.columns), len(dest))
+	}
+	for i, v := range r.rows[row] {
+		if err := assign(dest[i], v); err != nil {
+			return fmt.Errorf("sqlstructtest: scanning column %q: %w", r.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// assign stores src in the destination pointer dest.
+func assign(dest, src interface{}) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination not a pointer: %T", dest)
+	}
+	return assignValue(dv.Elem(), src)
+}
+
+// assignValue stores src in the settable value dv.
+func assignValue(dv reflect.Value, src interface{}) error {
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot store NULL in %s", dv.Type())
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+	if dv.Kind() == reflect.Ptr {
+		v := reflect.New(dv.Type().Elem())
+		if err := assignValue(v.Elem(), src); err != nil {
+			return err
+		}
+		dv.Set(v)
+		return nil
+	}
+	if s, ok := dv.Addr().Interface().(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	if convertible(sv.Type(), dv.Type()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot store %T in %s", src, dv.Type())
+}
+
+// convertible reports whether values of type from can be converted to type to
+// without changing their meaning, unlike for example integers to strings.
+func convertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	isBytes := func(t reflect.Type) bool { return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 }
+	switch {
+	case numeric(from.Kind()) && numeric(to.Kind()):
+		return true
+	case from.Kind() == reflect.String || isBytes(from):
+		return to.Kind() == reflect.String || isBytes(to)
+	}
+	return from.Kind() == to.Kind()
+}
+
+// numeric reports whether k is an integer or floating-point kind.
+func numeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstructtest
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kisielk/sqlstruct"
+)
+
+type user struct {
+	Id    int            `sql:"id"`
+	Name  string         `sql:"name"`
+	Email sql.NullString `sql:"email"`
+	Score *float32       `sql:"score"`
+}
+
+func TestRows(t *testing.T) {
+	rows := NewRows("name", "id", "email", "score").
+		AddRow([]byte("a"), int64(1), "a@example.com", 2.5).
+		AddRowMap(map[string]interface{}{"id": int64(2), "name": "b"})
+
+	users, err := sqlstruct.SliceFromRows[user](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	score := float32(2.5)
+	expected := []user{
+		{1, "a", sql.NullString{String: "a@example.com", Valid: true}, &score},
+		{Id: 2, Name: "b"},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %+v got %+v", expected, users)
+	}
+	if !rows.Closed() {
+		t.Error("expected rows to be closed")
+	}
+}
+
+func TestRowsErrors(t *testing.T) {
+	boom := errors.New("boom")
+	rows := NewRows("id").AddRow(int64(1)).AddRow(int64(2)).RowError(1, boom)
+	if _, err := sqlstruct.SliceFromRows[user](rows); err != boom {
+		t.Errorf("expected %v got %v", boom, err)
+	}
+
+	rows = NewRows("id").AddRow(int64(1)).ScanError(0, boom)
+	if _, err := sqlstruct.SliceFromRows[user](rows); !errors.Is(err, boom) {
+		t.Errorf("expected %v got %v", boom, err)
+	}
+
+	rows = NewRows("id").AddRow(int64(1)).ColumnsError(boom)
+	if _, err := sqlstruct.SliceFromRows[user](rows); !errors.Is(err, boom) {
+		t.Errorf("expected %v got %v", boom, err)
+	}
+
+	rows = NewRows("name").AddRow(int64(1))
+	rows.Next()
+	var name string
+	if err := rows.Scan(&name); err == nil || !strings.Contains(err.Error(), `column "name"`) {
+		t.Errorf("expected conversion error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unknown column")
+		}
+	}()
+	NewRows("id").AddRowMap(map[string]interface{}{"missing": 1})
+}
@@ -0,0 +1,167 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+/*
+Package sqlxcompat mirrors the scanning functions of sqlx on top of the sqlstruct
+package, so that code written for sqlx can be moved over one call at a time by
+changing its imports:
+
+	var user User
+	err := sqlxcompat.Get(db, &user, "SELECT * FROM users WHERE id = ?", 1)
+
+	var users []User
+	err = sqlxcompat.Select(db, &users, "SELECT * FROM users WHERE active = ?", true)
+
+Columns are mapped to fields by the rules and settings of sqlstruct, such as TagName
+and NameMapper, rather than those of sqlx. Set sqlstruct.TagNames to []string{"db"}
+to keep using the db tags of sqlx. Queries are run as given: unlike with the
+functions of sqlstruct, * is not replaced by the columns of the destination.
+*/
+package sqlxcompat
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/kisielk/sqlstruct"
+)
+
+// Queryer runs queries returning rows. It is implemented by *sql.DB, *sql.Tx and
+// *sql.Conn, and by the DB and Tx types of sqlx, which embed them.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Get runs query on q and scans its first row into dest, as sqlx.Get does. dest is a
+// pointer to a struct, whose fields are scanned with sqlstruct.Scan, or to a scannable
+// value, such as an int, a string or a time.Time, into which the single column of the
+// result is scanned. sql.ErrNoRows is returned if the query selects no rows.
+func Get(q Queryer, dest interface{}, query string, args ...interface{}) error {
+	return GetContext(context.Background(), q, dest, query, args...)
+}
+
+// GetContext works like Get but runs the query with ctx.
+func GetContext(ctx context.Context, q Queryer, dest interface{}, query string, args ...interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("sqlxcompat: dest must be a non-nil pointer; got %T", dest)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if scannable(v.Type().Elem()) {
+		err = rows.Scan(dest)
+	} else {
+		err = sqlstruct.Scan(dest, rows)
+	}
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Select runs query on q and appends its rows to the slice dest points to, as
+// sqlx.Select does. The elements of the slice are structs or pointers to structs, as
+// for sqlstruct.ScanAll, or scannable values into which the single column of the
+// result is scanned.
+func Select(q Queryer, dest interface{}, query string, args ...interface{}) error {
+	return SelectContext(context.Background(), q, dest, query, args...)
+}
+
+// SelectContext works like Select but runs the query with ctx.
+func SelectContext(ctx context.Context, q Queryer, dest interface{}, query string, args ...interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("sqlxcompat: dest must be a non-nil pointer to a slice; got %T", dest)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	if !scannable(elemType) {
+		return sqlstruct.ScanAll(dest, rows)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := reflect.New(elemType)
+		if err := rows.Scan(e.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, e.Elem()))
+	}
+	return rows.Err()
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scannable reports whether a value of type typ is scanned from a single column
+// rather than mapped to the columns of its fields.
+func scannable(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() != reflect.Struct || typ == timeType || reflect.PointerTo(typ).Implements(scannerType)
+}
+
+// RowScanner wraps rows whose results are scanned into values of T, in the manner
+// of the Rows type of sqlx:
+//
+//	rows, err := db.QueryContext(ctx, "SELECT id, name FROM users")
+//	...
+//	r := sqlxcompat.NewRowScanner[User](rows)
+//	defer r.Close()
+//	for r.Next() {
+//		var u User
+//		if err := r.StructScan(&u); err != nil {
+//			...
+//		}
+//	}
+//	err = r.Err()
+//
+// Rows are scanned into T with a sqlstruct.Plan, which maps the columns to the fields
+// once for the whole result. The methods of the wrapped rows, such as Scan, are
+// available as well.
+type RowScanner[T any] struct {
+	sqlstruct.RowsIter
+	plan *sqlstruct.Plan[T]
+}
+
+// NewRowScanner returns a RowScanner for rows, which may be a *sql.Rows.
+func NewRowScanner[T any](rows sqlstruct.RowsIter) *RowScanner[T] {
+	return &RowScanner[T]{RowsIter: rows}
+}
+
+// StructScan scans the current row into dest, which is a *T or a pointer to another
+// struct type, scanned with sqlstruct.Scan.
+func (r *RowScanner[T]) StructScan(dest interface{}) error {
+	d, ok := dest.(*T)
+	if !ok || d == nil {
+		return sqlstruct.Scan(dest, r.RowsIter)
+	}
+	if r.plan == nil {
+		plan, err := sqlstruct.PlanFor[T](r.RowsIter)
+		if err != nil {
+			return err
+		}
+		r.plan = plan
+	}
+	return r.plan.Scan(d)
+}
@@ -0,0 +1,147 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlxcompat
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+type user struct {
+	Id   int64  `sql:"id"`
+	Name string `sql:"name"`
+}
+
+// fakeDriver returns the same result, set by the tests, for every query.
+type fakeDriver struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt(c), nil }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{columns: s.d.columns, rows: s.d.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newDB(t *testing.T, columns []string, rows ...[]driver.Value) *sql.DB {
+	db := sql.OpenDB(connector{&fakeDriver{columns, rows}})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type connector struct{ d *fakeDriver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.d }
+
+func TestGet(t *testing.T) {
+	db := newDB(t, []string{"id", "name"}, []driver.Value{int64(1), "gedi"})
+	var u user
+	if err := Get(db, &u, "SELECT id, name FROM users WHERE id = ?", 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u != (user{1, "gedi"}) {
+		t.Errorf("unexpected result %v", u)
+	}
+
+	db = newDB(t, []string{"count"}, []driver.Value{int64(3)})
+	var n int
+	if err := Get(db, &n, "SELECT count(*) FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 got %d", n)
+	}
+
+	db = newDB(t, []string{"id", "name"})
+	if err := Get(db, &u, "SELECT id, name FROM users"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows got %v", err)
+	}
+	if err := Get(db, u, "SELECT id, name FROM users"); err == nil {
+		t.Error("expected error for non-pointer destination")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	db := newDB(t, []string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+	var users []*user
+	if err := Select(db, &users, "SELECT id, name FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(users) != 2 || *users[1] != (user{2, "b"}) {
+		t.Errorf("unexpected result %v", users)
+	}
+
+	var ids []int64
+	if err := Select(db, &ids, "SELECT id FROM users"); err == nil {
+		t.Error("expected error scanning two columns into a scalar")
+	}
+	db = newDB(t, []string{"name"}, []driver.Value{"a"}, []driver.Value{nil})
+	var names []sql.NullString
+	if err := Select(db, &names, "SELECT name FROM users"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(names) != 2 || names[0].String != "a" || names[1].Valid {
+		t.Errorf("unexpected result %v", names)
+	}
+}
+
+func TestRowScanner(t *testing.T) {
+	db := newDB(t, []string{"id", "name"}, []driver.Value{int64(1), "a"}, []driver.Value{int64(2), "b"})
+	rows, err := db.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r := NewRowScanner[user](rows)
+	defer r.Close()
+
+	var got []user
+	for r.Next() {
+		var u user
+		if err := r.StructScan(&u); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got = append(got, u)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != 2 || got[0] != (user{1, "a"}) || got[1] != (user{2, "b"}) {
+		t.Errorf("unexpected result %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestStatementCache(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	h := New(sqldb)
+	h.StatementCacheSize = 2
+
+	for _, id := range []string{"1", "2", "1", "3", "1"} {
+		var users []testUser
+		if err := h.Query(&users, "SELECT * FROM users WHERE id = "+id); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(users) != 1 {
+			t.Fatalf("unexpected result %v", users)
+		}
+	}
+	expected := []string{
+		"SELECT id, name FROM users WHERE id = 1",
+		"SELECT id, name FROM users WHERE id = 2",
+		"SELECT id, name FROM users WHERE id = 3",
+	}
+	if !reflect.DeepEqual(fake.prepared, expected) {
+		t.Errorf("expected %q to be prepared, got %q", expected, fake.prepared)
+	}
+	if fake.closed != 1 {
+		t.Errorf("expected the least recently used statement to be closed, got %d closed", fake.closed)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fake.closed != 3 {
+		t.Errorf("expected all statements to be closed, got %d closed", fake.closed)
+	}
+}
+
+func TestStatementCacheCloseConcurrent(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	h := New(sqldb)
+	h.StatementCacheSize = 2
+
+	done := make(chan error)
+	go func() {
+		_, err := QueryContext[testUser](context.Background(), "SELECT * FROM users", WithHandle(h))
+		done <- err
+	}()
+	h.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	h.Close()
+}
+
+func TestStatementCacheInUse(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	c := newStmtCache(sqldb, 1)
+	ctx := context.Background()
+
+	cs, err := c.get(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.ExecContext(ctx, "SELECT 2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fake.closed != 0 {
+		t.Errorf("expected statement in use not to be closed")
+	}
+	c.put(cs)
+	if fake.closed != 1 {
+		t.Errorf("expected released statement to be closed, got %d closed", fake.closed)
+	}
+}
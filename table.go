@@ -0,0 +1,91 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import "reflect"
+
+// Tabler is implemented by struct types that name the table they are stored in.
+type Tabler interface {
+	TableName() string
+}
+
+// Table is embedded in struct types to name their table with a tag, as an alternative
+// to implementing Tabler:
+//
+//	type User struct {
+//		sqlstruct.Table `sql:"users"`
+//		Id   int    `sql:"id"`
+//		Name string `sql:"name"`
+//	}
+//
+// The name is used as given, without applying NameMapper. Table has no columns.
+type Table struct{}
+
+var tableType = reflect.TypeOf(Table{})
+
+// tableName returns the name of the table for typ under the package-level configuration.
+func tableName(typ reflect.Type) string {
+	return defaultHandle.tableName(typ)
+}
+
+// quotedTable returns the name of the table for typ quoted by the Quoter of h. The
+// schema of a qualified name is quoted separately.
+func (h *Handle) quotedTable(typ reflect.Type) string {
+	schema, name := splitTable(h.tableName(typ))
+	if schema == "" {
+		return h.quote(name)
+	}
+	return h.quote(schema) + "." + h.quote(name)
+}
+
+// tableName returns the name of the table for typ. Types implementing Tabler or
+// embedding a tagged Table supply their own name, otherwise the type name is converted
+// with the NameMapper of h.
+func (h *Handle) tableName(typ reflect.Type) string {
+	if t, ok := reflect.Zero(typ).Interface().(Tabler); ok {
+		return t.TableName()
+	}
+	if t, ok := reflect.New(typ).Interface().(Tabler); ok {
+		return t.TableName()
+	}
+	if typ.Kind() == reflect.Struct {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.Anonymous && f.Type == tableType {
+				if name, _ := parseTag(lookupTag(f.Tag, h.tagNames())); name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return h.mapperFor(typ)(typ.Name())
+}
+
+// Namer is implemented by struct types which map their field names to column names
+// themselves, instead of using NameMapper, for example for legacy tables named
+// differently from the rest of the database:
+//
+//	func (LegacyUser) MapName(name string) string { return strings.ToUpper(name) }
+//
+// Like NameMapper, MapName is also applied to the column names given in tags, and to
+// the table name of types which do not implement Tabler. Embedded structs are mapped
+// by their own types, but note that an embedded Namer is promoted to the struct
+// embedding it.
+type Namer interface {
+	MapName(name string) string
+}
+
+// mapperFor returns the function mapping the field names of typ to column names.
+func (h *Handle) mapperFor(typ reflect.Type) func(string) string {
+	if typ != nil {
+		if n, ok := reflect.Zero(typ).Interface().(Namer); ok {
+			return n.MapName
+		}
+		if n, ok := reflect.New(typ).Interface().(Namer); ok {
+			return n.MapName
+		}
+	}
+	return h.nameMapper()
+}
@@ -0,0 +1,144 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// tagOptions is the string following the column name in a struct field's tag,
+// e.g. "pk" in `sql:"id,pk"`. Options are either flags, such as "pk", or key=value
+// pairs, such as "timeformat=2006-01-02".
+type tagOptions string
+
+// optionChecks holds, for the tag options which only apply to some field types, a
+// function returning an error describing the requirement if a field of type typ
+// cannot have the option with the given value. Options which are not listed may be
+// given on any field. New options restricted to some types are added here.
+var optionChecks = map[string]func(typ reflect.Type, value string) error{
+	"array": func(typ reflect.Type, _ string) error {
+		if typ.Kind() != reflect.Slice || typ.Elem().Kind() == reflect.Uint8 {
+			return errors.New("requires a slice field")
+		}
+		return nil
+	},
+	"intern": func(typ reflect.Type, _ string) error {
+		if elemType(typ).Kind() != reflect.String {
+			return errors.New("requires a string or *string field")
+		}
+		return nil
+	},
+	"raw": func(typ reflect.Type, _ string) error {
+		if typ.Kind() != reflect.Slice || typ.Elem().Kind() != reflect.Uint8 {
+			return errors.New("requires a []byte field")
+		}
+		return nil
+	},
+	"timeformat": func(typ reflect.Type, _ string) error {
+		if typ != timeType && typ != reflect.PointerTo(timeType) {
+			return errors.New("requires a time.Time field")
+		}
+		return nil
+	},
+	"version": func(typ reflect.Type, _ string) error {
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+		return errors.New("requires an integer field")
+	},
+	"prefix": func(typ reflect.Type, _ string) error {
+		if structType(typ) == nil {
+			return errors.New("requires a struct or pointer to struct field")
+		}
+		return nil
+	},
+	"split": func(typ reflect.Type, sep string) error {
+		if typ = elemType(typ); sep == "" || typ.Kind() != reflect.Slice || typ.Elem().Kind() != reflect.String {
+			return errors.New("requires a separator and a []string field")
+		}
+		return nil
+	},
+}
+
+// elemType returns the element type of typ if it is a pointer, and typ otherwise.
+func elemType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+	return typ
+}
+
+// check returns an error if a field of type typ cannot have the options o.
+func (o tagOptions) check(typ reflect.Type) error {
+	var err error
+	o.each(func(name, value string) bool {
+		if check, ok := optionChecks[name]; ok {
+			if cerr := check(typ, value); cerr != nil {
+				err = fmt.Errorf("%s option %v; got %s", name, cerr, typ)
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// parseTag splits a struct field's tag into its column name and options.
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.Index(tag, ","); i != -1 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// each calls fn with the name and value of each option until fn returns false.
+// Flags have an empty value. A value may consist of a single comma, as in "split=,".
+func (o tagOptions) each(fn func(name, value string) bool) {
+	s := string(o)
+	for s != "" {
+		var opt string
+		comma := false
+		if i := strings.Index(s, ","); i != -1 {
+			opt, s, comma = s[:i], s[i+1:], true
+		} else {
+			opt, s = s, ""
+		}
+		name, value, hasValue := strings.Cut(opt, "=")
+		if hasValue && value == "" && comma {
+			value = ","
+			s = strings.TrimPrefix(s, ",")
+		}
+		if !fn(name, value) {
+			return
+		}
+	}
+}
+
+// Contains reports whether the comma-separated list of options contains name.
+func (o tagOptions) Contains(name string) bool {
+	found := false
+	o.each(func(n, _ string) bool {
+		found = n == name
+		return !found
+	})
+	return found
+}
+
+// Value returns the value of the option name=value and whether it is present.
+func (o tagOptions) Value(name string) (string, bool) {
+	var value string
+	found := false
+	o.each(func(n, v string) bool {
+		if n == name {
+			value, found = v, true
+		}
+		return !found
+	})
+	return value, found
+}
@@ -0,0 +1,32 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import "context"
+
+// StatementTracer traces the statements executed by this package, for example as
+// spans of a distributed trace. Implementations must be safe for concurrent use. See
+// the otel subpackage for an OpenTelemetry implementation.
+type StatementTracer interface {
+	// StartStatement is called before a statement is sent to the database. kind is
+	// "query" or "exec", name is the name given with QueryName, if any, and query is
+	// the final SQL text. The returned context is used to execute the statement, and
+	// the returned function is called once it has completed, including the scanning
+	// of its rows, with the number of rows read or affected and the error, if any.
+	StartStatement(ctx context.Context, kind, name, query string, args []interface{}) (context.Context, func(rows int64, err error))
+}
+
+// Tracer, if set, traces the statements executed by this package.
+var Tracer StatementTracer
+
+// startStatement starts tracing a statement with Tracer. The returned function must be
+// called when the statement has completed.
+func startStatement(ctx context.Context, kind, query string, args []interface{}) (context.Context, func(int64, error)) {
+	t := Tracer
+	if t == nil {
+		return ctx, func(int64, error) {}
+	}
+	return t.StartStatement(ctx, kind, queryName(ctx), query, args)
+}
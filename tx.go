@@ -0,0 +1,113 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Tx is a transaction begun by WithTx. Its methods run queries and statements within
+// the transaction. A Tx is also a Queryer, so it can be given to the generic query
+// functions with QueryOn or the Executor option:
+//
+//	users, err := sqlstruct.QueryOn[User](tx, "SELECT * FROM users")
+type Tx struct {
+	tx *sql.Tx
+	h  *Handle
+}
+
+var _ Queryer = (*Tx)(nil)
+
+// WithTx begins a transaction on db and calls fn with it. The transaction is
+// committed if fn returns nil and rolled back otherwise. If fn panics, the transaction
+// is rolled back and the panic is propagated. For example:
+//
+//	err := sqlstruct.WithTx(ctx, db, func(tx *sqlstruct.Tx) error {
+//		var from Account
+//		if err := tx.QueryRow(ctx, &from, "SELECT * FROM accounts WHERE id = ?", id); err != nil {
+//			return err
+//		}
+//		from.Balance -= amount
+//		_, err := tx.Update(ctx, &from)
+//		return err
+//	})
+func WithTx(ctx context.Context, db *sql.DB, fn func(*Tx) error) error {
+	return defaultHandle.withTx(ctx, db, fn)
+}
+
+// WithTx works like the package-level WithTx on the database of h, using the
+// configuration of h.
+func (h *Handle) WithTx(ctx context.Context, fn func(*Tx) error) error {
+	if h.db == nil {
+		return errors.New("sqlstruct: no database set")
+	}
+	return h.withTx(ctx, h.db, fn)
+}
+
+func (h *Handle) withTx(ctx context.Context, db *sql.DB, fn func(*Tx) error) (err error) {
+	sqltx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			sqltx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(&Tx{sqltx, h}); err != nil {
+		if rerr := sqltx.Rollback(); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+		return err
+	}
+	return sqltx.Commit()
+}
+
+// Tx returns the underlying *sql.Tx.
+func (tx *Tx) Tx() *sql.Tx {
+	return tx.tx
+}
+
+// ExecContext executes a statement within the transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query within the transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(ctx, query, args...)
+}
+
+// Query works like Handle.QueryContext within the transaction, storing the rows
+// selected by query in the slice pointed to by dest.
+func (tx *Tx) Query(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return tx.h.QueryContext(ctx, dest, query, withExecutor(tx.tx, args)...)
+}
+
+// QueryRow works like Handle.QueryRowContext within the transaction, storing the
+// first row selected by query in the struct pointed to by dest.
+func (tx *Tx) QueryRow(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return tx.h.QueryRowContext(ctx, dest, query, withExecutor(tx.tx, args)...)
+}
+
+// Insert works like the package-level Insert within the transaction.
+func (tx *Tx) Insert(ctx context.Context, v interface{}, opts ...Option) (sql.Result, error) {
+	return Insert(ctx, v, tx.options(opts)...)
+}
+
+// Update works like the package-level Update within the transaction.
+func (tx *Tx) Update(ctx context.Context, v interface{}, opts ...Option) (int64, error) {
+	return Update(ctx, v, tx.options(opts)...)
+}
+
+// options returns opts with the options running calls within the transaction.
+func (tx *Tx) options(opts []Option) []Option {
+	return append(opts[:len(opts):len(opts)], Executor(tx.tx), func(o *options) {
+		o.handle = tx.h
+	})
+}
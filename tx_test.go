@@ -0,0 +1,71 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWithTx(t *testing.T) {
+	sqldb, fake := newFakeDB(t)
+	fake.setRows([]string{"id", "name"}, []driver.Value{int64(1), "a"})
+	ctx := context.Background()
+
+	err := WithTx(ctx, sqldb, func(tx *Tx) error {
+		var u testUser
+		if err := tx.QueryRow(ctx, &u, "SELECT * FROM users WHERE id = ?", 1); err != nil {
+			return err
+		}
+		u.Name = "b"
+		if _, err := tx.Update(ctx, &u); err != nil {
+			return err
+		}
+		_, err := QueryOn[testUser](tx, "SELECT * FROM users")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"SELECT id, name FROM users WHERE id = ? LIMIT 1",
+		"UPDATE users SET name = ? WHERE id = ?",
+		"SELECT id, name FROM users",
+		"COMMIT",
+	}
+	if !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected %q got %q", expected, fake.queries)
+	}
+
+	fake.queries = nil
+	boom := errors.New("boom")
+	err = WithTx(ctx, sqldb, func(tx *Tx) error {
+		if _, err := tx.Insert(ctx, testUser{2, "c"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if err != boom {
+		t.Errorf("expected %v got %v", boom, err)
+	}
+	if expected := []string{"INSERT INTO users (id, name) VALUES (?, ?)", "ROLLBACK"}; !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected %q got %q", expected, fake.queries)
+	}
+
+	fake.queries = nil
+	func() {
+		defer func() {
+			if recover() != boom {
+				t.Error("expected panic to be propagated")
+			}
+		}()
+		WithTx(ctx, sqldb, func(tx *Tx) error { panic(boom) })
+	}()
+	if expected := []string{"ROLLBACK"}; !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected %q got %q", expected, fake.queries)
+	}
+}
@@ -0,0 +1,769 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MaxParams is the largest number of bind parameters placed in a single generated
+// statement. Larger operations are split into several statements. The default is
+// low enough to be accepted by every common driver.
+var MaxParams = 999
+
+// Execer is the interface used to execute generated statements.
+// It is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// structOf returns the struct value held by v, following pointers. The struct is
+// addressable so that its fields can be converted by field.value.
+func structOf(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("sqlstruct: expected struct or pointer to struct; got %T", v)
+	}
+	if !rv.CanAddr() {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+	return rv, nil
+}
+
+// placeholders returns n comma-separated bind parameters.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Insert inserts v into its table on the database set with SetDatabase, or the
+// Executor given among opts. v must be a struct or a pointer to one. Every mapped
+// field is inserted except those tagged with the "auto" option, which are left for
+// the database to generate:
+//
+//	type User struct {
+//		Id   int    `sql:"id,pk,auto"`
+//		Name string `sql:"name"`
+//	}
+//
+//	res, err := sqlstruct.Insert(ctx, &User{Name: "gedi"})
+//
+// This executes INSERT INTO user (name) VALUES (?). See Tabler for naming the table.
+//
+// Fields tagged with the "readonly" option, such as timestamps maintained by the
+// database, are never inserted, and fields tagged with the "omitempty" option are
+// skipped when they hold their zero value.
+//
+// With the Returning option the values generated by the database are read back into
+// v, which must then be a pointer:
+//
+//	user := &User{Name: "gedi"}
+//	_, err := sqlstruct.Insert(ctx, user, sqlstruct.Returning())
+//
+// For Postgres and SQLite a RETURNING clause selects the auto and read-only columns,
+// and for SQLServer an OUTPUT clause. Other dialects such as MySQL only set a single
+// auto-generated integer primary key, from the LastInsertId of the result.
+func Insert[T any](ctx context.Context, v T, opts ...Option) (sql.Result, error) {
+	o := applyOptions(opts)
+	rv, err := savedStruct(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	cols, args := insertValues(fields, rv, false, true)
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("sqlstruct: %s has no columns to insert", rv.Type())
+	}
+	query := insertStatement(h.quotedTable(rv.Type()), h.quoteAll(cols))
+	if o.returning {
+		if reflect.ValueOf(v).Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("sqlstruct: Insert with Returning requires a pointer to %s", rv.Type())
+		}
+		return o.insertReturning(ctx, query, args, fields, rv)
+	}
+	return o.exec(ctx, query, args)
+}
+
+// insertReturning runs the INSERT statement query and sets the fields of rv generated
+// by the database to their inserted values. See Insert.
+func (o options) insertReturning(ctx context.Context, query string, args []interface{}, fields fieldInfo, rv reflect.Value) (sql.Result, error) {
+	var cols []string
+	var dests []interface{}
+	for _, name := range fields.names() {
+		if f := fields[name]; (f.auto || f.readOnly) && !f.writeOnly {
+			cols = append(cols, name)
+			dests = append(dests, f.dest(rv, false))
+		}
+	}
+	if len(cols) == 0 {
+		return o.exec(ctx, query, args)
+	}
+
+	cols = o.handleOf().quoteAll(cols)
+	switch o.dialectOf() {
+	case Postgres, SQLite:
+		query += " RETURNING " + strings.Join(cols, ", ")
+	case SQLServer:
+		for i, col := range cols {
+			cols[i] = "INSERTED." + col
+		}
+		query = strings.Replace(query, ") VALUES (", ") OUTPUT "+strings.Join(cols, ", ")+" VALUES (", 1)
+	default:
+		res, err := o.exec(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return res, setInsertID(res, fields, rv)
+	}
+
+	o.primary = true
+	err := o.run(ctx, query, args, func(ctx context.Context, rows *sql.Rows) (int64, error) {
+		return firstRow(rows, func() error { return rows.Scan(dests...) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// setInsertID sets the auto-generated integer primary key of rv, if it has one, to the
+// LastInsertId of res.
+func setInsertID(res sql.Result, fields fieldInfo, rv reflect.Value) error {
+	pks := fields.primaryKeys()
+	if len(pks) != 1 || !pks[0].auto {
+		return nil
+	}
+	fv, err := rv.FieldByIndexErr(pks[0].index)
+	if err != nil {
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("sqlstruct: cannot read inserted id: %w", err)
+	}
+	if fv.CanInt() {
+		fv.SetInt(id)
+	} else {
+		fv.SetUint(uint64(id))
+	}
+	return nil
+}
+
+// insertValues returns the columns inserted for the struct rv and their values.
+// Read-only and auto-generated columns are skipped, except for primary keys if pk is
+// true. If omitEmpty is true, so are zero fields tagged with the "omitempty" option.
+func insertValues(fields fieldInfo, rv reflect.Value, pk, omitEmpty bool) ([]string, []interface{}) {
+	var cols []string
+	var args []interface{}
+	for _, name := range fields.names() {
+		f := fields[name]
+		if f.readOnly || f.auto && !(pk && f.pk) || omitEmpty && f.omitEmpty && f.zero(rv) {
+			continue
+		}
+		cols = append(cols, name)
+		args = append(args, f.value(rv))
+	}
+	return cols, args
+}
+
+// insertStatement returns an INSERT statement for a single row of cols.
+func insertStatement(table string, cols []string) string {
+	return "INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES (" + placeholders(len(cols)) + ")"
+}
+
+// txBeginner is implemented by Queryers which can begin transactions, such as
+// *sql.DB and *sql.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// BatchInsert inserts items into their table using multi-row INSERT statements and
+// returns the total number of rows affected. Columns are chosen as for Insert, except
+// that the "omitempty" option is ignored so that all rows have the same columns. The
+// rows are split into as many statements as needed to stay within MaxParams, or the
+// limit given with ParamLimit. With the InTransaction option the statements run in a
+// single transaction which is rolled back if any of them fails.
+func BatchInsert[T any](ctx context.Context, items []T, opts ...Option) (int64, error) {
+	o := applyOptions(opts)
+	if len(items) == 0 {
+		return 0, nil
+	}
+	h := o.handleOf()
+	limit := o.params
+	if limit <= 0 {
+		limit = MaxParams
+	}
+
+	var table string
+	var cols []string
+	var rows [][]interface{}
+	for _, item := range items {
+		rv, err := savedStruct(ctx, item)
+		if err != nil {
+			return 0, err
+		}
+		fields, err := h.getFieldInfo(rv.Type())
+		if err != nil {
+			return 0, err
+		}
+		var args []interface{}
+		cols, args = insertValues(fields, rv, false, false)
+		rows = append(rows, args)
+		if table == "" {
+			table = h.quotedTable(rv.Type())
+		}
+	}
+	if len(cols) == 0 {
+		return 0, fmt.Errorf("sqlstruct: %T has no columns to insert", items[0])
+	}
+	cols = h.quoteAll(cols)
+
+	if o.tx {
+		q, err := o.queryer()
+		if err != nil {
+			return 0, err
+		}
+		if b, ok := q.(txBeginner); ok {
+			tx, err := b.BeginTx(ctx, nil)
+			if err != nil {
+				return 0, err
+			}
+			o.executor = tx
+			n, err := batchInsert(ctx, o, table, cols, rows, limit)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			return n, tx.Commit()
+		}
+	}
+	return batchInsert(ctx, o, table, cols, rows, limit)
+}
+
+// batchInsert executes the INSERT statements of BatchInsert.
+func batchInsert(ctx context.Context, o options, table string, cols []string, rows [][]interface{}, limit int) (int64, error) {
+	per := limit / len(cols)
+	if per < 1 {
+		per = 1
+	}
+	row := "(" + placeholders(len(cols)) + ")"
+	prefix := "INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES "
+
+	var total int64
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > per {
+			n = per
+		}
+		args := make([]interface{}, 0, n*len(cols))
+		for _, r := range rows[:n] {
+			args = append(args, r...)
+		}
+		query := prefix + strings.TrimSuffix(strings.Repeat(row+", ", n), ", ")
+
+		res, err := o.exec(ctx, query, args)
+		if err != nil {
+			return total, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		rows = rows[n:]
+	}
+	return total, nil
+}
+
+// Upsert inserts v into its table, or updates the existing row if one with the same
+// primary key exists. v must be a struct or a pointer to one with at least one
+// primary key field. The columns updated on conflict are the inserted columns other
+// than the primary key, or those given with UpdateColumns, which must name columns
+// that Update could set.
+//
+// The statement depends on the dialect of the Handle, or the one given with
+// WithDialect: ON CONFLICT ... DO UPDATE for Postgres and SQLite, and ON DUPLICATE
+// KEY UPDATE for MySQL. Other dialects are not supported.
+func Upsert[T any](ctx context.Context, v T, opts ...Option) (sql.Result, error) {
+	o := applyOptions(opts)
+	rv, err := savedStruct(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	keys := fields.primaryKeys()
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("sqlstruct: Upsert requires a primary key field in %s", rv.Type())
+	}
+
+	cols, args := insertValues(fields, rv, true, true)
+	only := make(map[string]bool, len(o.columns))
+	for _, col := range o.columns {
+		f, ok := fields[strings.ToLower(col)]
+		if !ok || f.pk || f.readOnly || f.version {
+			return nil, fmt.Errorf("sqlstruct: cannot update column %q of %s", col, rv.Type())
+		}
+		only[f.name] = true
+	}
+	var update []string
+	for _, col := range cols {
+		if fields[col].pk || len(only) > 0 && !only[col] {
+			continue
+		}
+		update = append(update, col)
+	}
+
+	query := insertStatement(h.quotedTable(rv.Type()), h.quoteAll(cols))
+	switch d := o.dialectOf(); d {
+	case Postgres, SQLite:
+		pks := make([]string, len(keys))
+		for i, f := range keys {
+			pks[i] = h.quote(f.name)
+		}
+		query += " ON CONFLICT (" + strings.Join(pks, ", ") + ") DO "
+		if len(update) == 0 {
+			query += "NOTHING"
+			break
+		}
+		for i, col := range update {
+			update[i] = h.quote(col) + " = excluded." + h.quote(col)
+		}
+		query += "UPDATE SET " + strings.Join(update, ", ")
+	case MySQL:
+		if len(update) == 0 {
+			update = append(update, keys[0].name)
+		}
+		for i, col := range update {
+			update[i] = h.quote(col) + " = VALUES(" + h.quote(col) + ")"
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(update, ", ")
+	default:
+		return nil, fmt.Errorf("sqlstruct: Upsert is not supported for dialect %s", d)
+	}
+	return o.exec(ctx, query, args)
+}
+
+// Update updates the row of v's table whose primary key matches the fields of v tagged
+// with the "pk" option, and returns the number of rows affected. v must be a struct or
+// a pointer to one with at least one primary key field. All other fields except those
+// tagged with the "auto" or "readonly" options, or zero fields tagged with "omitempty",
+// are set, unless UpdateColumns is given:
+//
+//	n, err := sqlstruct.Update(ctx, &user, sqlstruct.UpdateColumns("name"))
+//
+// This executes UPDATE user SET name = ? WHERE id = ?.
+//
+// A field tagged with the "version" option is used for optimistic locking. The update
+// only applies if the column still holds the value of the field, and increments it:
+//
+//	type User struct {
+//		Id      int    `sql:"id,pk"`
+//		Name    string `sql:"name"`
+//		Version int    `sql:"version,version"`
+//	}
+//
+// This executes UPDATE user SET name = ?, version = version + 1 WHERE id = ? AND
+// version = ?. If no row is updated because another update came first, Update returns
+// ErrStaleVersion. Otherwise the field is incremented as well if v is a pointer.
+func Update[T any](ctx context.Context, v T, opts ...Option) (int64, error) {
+	o := applyOptions(opts)
+	rv, err := savedStruct(ctx, v)
+	if err != nil {
+		return 0, err
+	}
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(rv.Type())
+	if err != nil {
+		return 0, err
+	}
+	keys := fields.primaryKeys()
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("sqlstruct: Update requires a primary key field in %s", rv.Type())
+	}
+
+	only := make(map[string]bool, len(o.columns))
+	for _, col := range o.columns {
+		f, ok := fields[strings.ToLower(col)]
+		if !ok || f.pk || f.readOnly || f.version {
+			return 0, fmt.Errorf("sqlstruct: cannot update column %q of %s", col, rv.Type())
+		}
+		only[f.name] = true
+	}
+
+	var set []string
+	var args []interface{}
+	for _, name := range fields.names() {
+		f := fields[name]
+		if f.pk || f.readOnly || f.version || len(only) > 0 && !only[name] || len(only) == 0 && (f.auto || f.omitEmpty && f.zero(rv)) {
+			continue
+		}
+		set = append(set, h.quote(name)+" = ?")
+		args = append(args, f.value(rv))
+	}
+	if len(set) == 0 {
+		return 0, fmt.Errorf("sqlstruct: %s has no columns to update", rv.Type())
+	}
+
+	where := make([]string, len(keys))
+	for i, f := range keys {
+		where[i] = h.quote(f.name) + " = ?"
+		args = append(args, f.value(rv))
+	}
+	version := fields.versionField()
+	if version != nil {
+		col := h.quote(version.name)
+		set = append(set, col+" = "+col+" + 1")
+		where = append(where, col+" = ?")
+		args = append(args, version.value(rv))
+	}
+
+	query := "UPDATE " + h.quotedTable(rv.Type()) + " SET " + strings.Join(set, ", ") + " WHERE " + strings.Join(where, " AND ")
+	res, err := o.exec(ctx, query, args)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || version == nil {
+		return n, err
+	}
+	if n == 0 {
+		return 0, ErrStaleVersion
+	}
+	if fv, err := rv.FieldByIndexErr(version.index); err == nil {
+		if fv.CanInt() {
+			fv.SetInt(fv.Int() + 1)
+		} else {
+			fv.SetUint(fv.Uint() + 1)
+		}
+	}
+	return n, nil
+}
+
+// ErrStaleVersion is returned by Update when the row was not updated because its
+// version column no longer matches the version field of the struct, meaning that the
+// row was changed or deleted since it was read.
+var ErrStaleVersion = errors.New("sqlstruct: row was modified concurrently")
+
+// Save inserts v if its auto-generated primary key field is zero, and updates its row
+// otherwise. It returns the number of rows inserted or updated. For example:
+//
+//	user := &User{Name: "gedi"}
+//	_, err := sqlstruct.Save(ctx, user) // inserts the user and sets user.Id
+//	user.Name = "kamil"
+//	_, err = sqlstruct.Save(ctx, user) // updates the row of user.Id
+//
+// T must have a single primary key field tagged with the "auto" option. Rows are
+// inserted as by Insert with the Returning option, so that the generated key is set
+// in v, and updated as by Update. Options are passed on to both.
+func Save[T any](ctx context.Context, v *T, opts ...Option) (int64, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if v == nil {
+		return 0, fmt.Errorf("sqlstruct: Save requires a non-nil pointer to %s", typ)
+	}
+	fields, err := applyOptions(opts).handleOf().getFieldInfo(typ)
+	if err != nil {
+		return 0, err
+	}
+	pks := fields.primaryKeys()
+	if len(pks) != 1 || !pks[0].auto {
+		return 0, fmt.Errorf("sqlstruct: Save requires %s to have a single auto primary key field", typ)
+	}
+	if !pks[0].zero(reflect.ValueOf(v).Elem()) {
+		return Update(ctx, v, opts...)
+	}
+	res, err := Insert(ctx, v, append(opts[:len(opts):len(opts)], Returning())...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete deletes the row of T's table with the given primary key and returns the
+// number of rows affected. key is either the value of T's single primary key field or
+// a T, or pointer to T, whose primary key fields identify the row:
+//
+//	n, err := sqlstruct.Delete[User](ctx, 42)
+//
+// This executes DELETE FROM user WHERE id = ?.
+//
+// If T has a field tagged with the "softdelete" option, rows are marked as deleted
+// instead of being removed:
+//
+//	type User struct {
+//		Id        int        `sql:"id,pk"`
+//		Name      string     `sql:"name"`
+//		DeletedAt *time.Time `sql:"deleted_at,softdelete"`
+//	}
+//
+// Deleting a User then executes UPDATE user SET deleted_at = CURRENT_TIMESTAMP WHERE
+// id = ? AND deleted_at IS NULL. The column must be NULL for rows which are not
+// deleted. Count, Exists, BuildSelect, Load and queries selecting FROM {table} leave
+// out soft-deleted rows, but queries naming the table must filter them themselves.
+// With the Unscoped option the rows are deleted for good. DeleteWhere and DeleteAll
+// delete rows in the same way.
+func Delete[T any](ctx context.Context, key interface{}, opts ...Option) (int64, error) {
+	o := applyOptions(opts)
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("sqlstruct: Delete requires a struct type; got %s", typ)
+	}
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return 0, err
+	}
+	keys := fields.primaryKeys()
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("sqlstruct: Delete requires a primary key field in %s", typ)
+	}
+
+	where := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	switch key.(type) {
+	case T, *T:
+		rv, err := structOf(key)
+		if err != nil {
+			return 0, err
+		}
+		for i, f := range keys {
+			where[i] = h.quote(f.name) + " = ?"
+			args[i] = f.value(rv)
+		}
+	default:
+		if len(keys) != 1 {
+			return 0, fmt.Errorf("sqlstruct: %s has a composite primary key; pass a %s to Delete", typ, typ)
+		}
+		where[0] = h.quote(keys[0].name) + " = ?"
+		args[0] = key
+	}
+	return deleteWhere(ctx, o, fields, h.quotedTable(typ), where, args)
+}
+
+// DeleteWhere deletes the rows of T's table matching every non-zero field of filter
+// and returns the number of rows affected. For example:
+//
+//	n, err := sqlstruct.DeleteWhere(ctx, User{Name: "gedi"})
+//
+// This executes DELETE FROM user WHERE name = ?. To guard against deleting the whole
+// table by mistake, DeleteWhere returns an error if every field of filter is zero.
+func DeleteWhere[T any](ctx context.Context, filter T, opts ...Option) (int64, error) {
+	o := applyOptions(opts)
+	rv, err := structOf(filter)
+	if err != nil {
+		return 0, err
+	}
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(rv.Type())
+	if err != nil {
+		return 0, err
+	}
+
+	where, args := h.whereFields(fields, rv)
+	if len(where) == 0 {
+		return 0, fmt.Errorf("sqlstruct: DeleteWhere filter for %s has no non-zero fields", rv.Type())
+	}
+	return deleteWhere(ctx, o, fields, h.quotedTable(rv.Type()), where, args)
+}
+
+// WhereFilter returns a condition matching every non-zero field of filter, joined
+// with AND, and the arguments for its placeholders, for example:
+//
+//	where, args := sqlstruct.WhereFilter(User{Name: "gedi", Active: true})
+//	users, err := sqlstruct.Query[User]("SELECT * FROM users WHERE "+where, args...)
+//
+// This selects the users with WHERE active = ? AND name = ?, with the arguments in the
+// same order. The result can also be passed to the Where option of BuildSelect. If
+// every field of filter is zero, the condition is 1 = 1, which matches all rows.
+// Like Columns, WhereFilter panics if T cannot be mapped to columns.
+func WhereFilter[T any](filter T) (string, []interface{}) {
+	rv, err := structOf(filter)
+	if err != nil {
+		panic(err)
+	}
+	fields, err := defaultHandle.getFieldInfo(rv.Type())
+	if err != nil {
+		panic(err)
+	}
+	where, args := defaultHandle.whereFields(fields, rv)
+	if len(where) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(where, " AND "), args
+}
+
+// whereFields returns a condition and an argument for each non-zero field of rv.
+func (h *Handle) whereFields(fields fieldInfo, rv reflect.Value) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	for _, name := range fields.names() {
+		f := fields[name]
+		if f.zero(rv) {
+			continue
+		}
+		where = append(where, h.quote(name)+" = ?")
+		args = append(args, f.value(rv))
+	}
+	return where, args
+}
+
+// deleteWhere deletes the rows of table matching all of the conditions in where,
+// marking them as deleted if fields has a soft delete field and o is not unscoped.
+func deleteWhere(ctx context.Context, o options, fields fieldInfo, table string, where []string, args []interface{}) (int64, error) {
+	query := o.deletePrefix(fields, table) + " WHERE " + strings.Join(where, " AND ")
+	if live := o.handleOf().notDeleted(fields); live != "" && !o.unscoped {
+		query += " AND " + live
+	}
+	res, err := o.exec(ctx, query, args)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// deletePrefix returns the start of the statement deleting rows of table, up to the
+// WHERE clause. See Delete.
+func (o options) deletePrefix(fields fieldInfo, table string) string {
+	if f := fields.softDeleteField(); f != nil && !o.unscoped {
+		return "UPDATE " + table + " SET " + o.handleOf().quote(f.name) + " = CURRENT_TIMESTAMP"
+	}
+	return "DELETE FROM " + table
+}
+
+// DeleteAll deletes the rows of T's table whose primary key is in pks and returns
+// the total number of rows affected. T must have exactly one field tagged with the
+// "pk" option, for example:
+//
+//	type User struct {
+//		Id   int    `sql:"id,pk"`
+//		Name string `sql:"name"`
+//	}
+//
+//	n, err := sqlstruct.DeleteAll[User](db, []int{1, 2, 3})
+//
+// The keys are split into as many DELETE ... WHERE pk IN (...) statements as needed
+// to stay within MaxParams, or the limit given with ParamLimit. Soft-deleted rows are
+// handled as described for Delete. The statements are run on db with the other
+// options, such as WithDialect and Timeout, handled as by Delete.
+func DeleteAll[T any, K any](db Execer, pks []K, opts ...Option) (int64, error) {
+	return DeleteAllContext[T](context.Background(), db, pks, opts...)
+}
+
+// DeleteAllContext works like DeleteAll but runs the statements with ctx.
+func DeleteAllContext[T any, K any](ctx context.Context, db Execer, pks []K, opts ...Option) (int64, error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("sqlstruct: DeleteAll requires a struct type; got %s", typ)
+	}
+	o := applyOptions(opts)
+	h := o.handleOf()
+	fields, err := h.getFieldInfo(typ)
+	if err != nil {
+		return 0, err
+	}
+	keys := fields.primaryKeys()
+	if len(keys) != 1 {
+		return 0, fmt.Errorf("sqlstruct: DeleteAll requires exactly one primary key field in %s; found %d", typ, len(keys))
+	}
+	limit := o.params
+	if limit <= 0 {
+		limit = MaxParams
+	}
+
+	prefix := o.deletePrefix(fields, h.quotedTable(typ)) + " WHERE " + h.quote(keys[0].name) + " IN ("
+	var total int64
+	for len(pks) > 0 {
+		n := len(pks)
+		if n > limit {
+			n = limit
+		}
+		args := make([]interface{}, n)
+		for i, pk := range pks[:n] {
+			args[i] = pk
+		}
+		query := prefix + placeholders(n) + ")"
+		if live := h.notDeleted(fields); live != "" && !o.unscoped {
+			query += " AND " + live
+		}
+
+		res, err := o.execOn(ctx, db, query, args)
+		if err != nil {
+			return total, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		pks = pks[n:]
+	}
+	return total, nil
+}
+
+// BuildInsertSelect returns an INSERT ... SELECT statement copying the rows of Src's
+// table matching where into Dst's table, along with args. Only the columns which Dst
+// and Src have in common are copied. An empty where copies all rows. For example:
+//
+//	query, args := sqlstruct.BuildInsertSelect[ArchivedOrder, Order]("created < ?", cutoff)
+//	_, err := db.Exec(query, args...)
+//
+// BuildInsertSelect panics if either type cannot be mapped to columns or if they
+// have no columns in common.
+func BuildInsertSelect[Dst, Src any](where string, args ...interface{}) (string, []interface{}) {
+	dst := reflect.TypeOf((*Dst)(nil)).Elem()
+	src := reflect.TypeOf((*Src)(nil)).Elem()
+	dstFields, err := getFieldInfo(dst)
+	if err != nil {
+		panic(err)
+	}
+	srcFields, err := getFieldInfo(src)
+	if err != nil {
+		panic(err)
+	}
+
+	var cols []string
+	for _, name := range dstFields.names() {
+		if dstFields[name].readOnly {
+			continue
+		}
+		if f, ok := srcFields[name]; ok && !f.writeOnly {
+			cols = append(cols, name)
+		}
+	}
+	if len(cols) == 0 {
+		panic(fmt.Errorf("sqlstruct: %s and %s have no columns in common", dst, src))
+	}
+
+	list := strings.Join(defaultHandle.quoteAll(cols), ", ")
+	query := "INSERT INTO " + defaultHandle.quotedTable(dst) + " (" + list + ") SELECT " + list + " FROM " + defaultHandle.quotedTable(src)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return query, args
+}
@@ -0,0 +1,535 @@
+// Copyright 2012 Kamil Kisiel. All rights reserved.
+// Use of this source code is governed by the MIT
+// license which can be found in the LICENSE file.
+package sqlstruct
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	Id   int    `sql:"id,pk"`
+	Name string `sql:"name"`
+}
+
+func (testUser) TableName() string { return "users" }
+
+func TestDeleteAll(t *testing.T) {
+	db, fake := newFakeDB(t)
+	defer func(n int) { MaxParams = n }(MaxParams)
+	MaxParams = 2
+
+	n, err := DeleteAll[testUser](db, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 rows affected got %d", n)
+	}
+
+	expected := []string{
+		"DELETE FROM users WHERE id IN (?, ?)",
+		"DELETE FROM users WHERE id IN (?)",
+	}
+	if len(fake.queries) != len(expected) {
+		t.Fatalf("expected %d queries got %q", len(expected), fake.queries)
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if len(fake.args[1]) != 1 || fake.args[1][0] != int64(3) {
+		t.Errorf("expected args [3] got %v", fake.args[1])
+	}
+
+	if _, err := DeleteAll[testType](db, []int{1}); err == nil {
+		t.Error("expected error for type without primary key")
+	}
+
+	fake.queries = nil
+	n, err = DeleteAllContext[testUser](context.Background(), db, []int{1, 2, 3}, ParamLimit(3), WithDialect(Postgres))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "DELETE FROM users WHERE id IN ($1, $2, $3)"; len(fake.queries) != 1 || fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DeleteAllContext[testUser](ctx, db, []int{1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+}
+
+type testArchivedUser struct {
+	Id        int    `sql:"id,pk"`
+	Name      string `sql:"name"`
+	Archived  bool   `sql:"archived"`
+	Reference string `sql:"-"`
+}
+
+func (testArchivedUser) TableName() string { return "archived_users" }
+
+func TestBuildInsertSelect(t *testing.T) {
+	query, args := BuildInsertSelect[testArchivedUser, testUser]("id < ?", 10)
+	expected := "INSERT INTO archived_users (id, name) SELECT id, name FROM users WHERE id < ?"
+	if query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+	if len(args) != 1 || args[0] != 10 {
+		t.Errorf("expected args [10] got %v", args)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	fake := setFakeDatabase(t)
+
+	type post struct {
+		Id    int64    `sql:"id,pk,auto"`
+		Title string   `sql:"title"`
+		Tags  []string `sql:"tags,split=;"`
+	}
+
+	if _, err := Insert(context.Background(), &post{Id: 7, Title: "hello", Tags: []string{"a", "b"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "INSERT INTO post (tags, title) VALUES (?, ?)"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	if args := fake.args[0]; len(args) != 2 || args[0] != "a;b" || args[1] != "hello" {
+		t.Errorf("expected args [a;b hello] got %v", args)
+	}
+
+	if _, err := Insert(context.Background(), 1); err == nil {
+		t.Error("expected error for non-struct value")
+	}
+}
+
+func TestInsertReturning(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	type post struct {
+		Id      int64  `sql:"id,pk,auto"`
+		Title   string `sql:"title"`
+		Created string `sql:"created,readonly"`
+	}
+
+	fake.setRows([]string{"created", "id"}, []driver.Value{"today", int64(7)})
+	p := &post{Title: "hello"}
+	if _, err := Insert(ctx, p, Returning(), WithDialect(Postgres)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Id != 7 || p.Created != "today" {
+		t.Errorf("expected generated values to be set got %+v", p)
+	}
+
+	fake.lastID = 8
+	p = &post{Title: "hello"}
+	if _, err := Insert(ctx, p, Returning(), WithDialect(MySQL)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Id != 8 {
+		t.Errorf("expected id 8 got %d", p.Id)
+	}
+
+	if _, err := Insert(ctx, p, Returning(), WithDialect(SQLServer)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"INSERT INTO post (title) VALUES ($1) RETURNING created, id",
+		"INSERT INTO post (title) VALUES (?)",
+		"INSERT INTO post (title) OUTPUT INSERTED.created, INSERTED.id VALUES (@p1)",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+
+	if _, err := Insert(ctx, post{}, Returning()); err == nil {
+		t.Error("expected error for struct passed by value")
+	}
+}
+
+func TestSave(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	type post struct {
+		Id    int64  `sql:"id,pk,auto"`
+		Title string `sql:"title"`
+	}
+
+	fake.lastID = 3
+	fake.affected = 1
+	p := &post{Title: "hello"}
+	if _, err := Save(ctx, p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Id != 3 {
+		t.Errorf("expected id 3 got %d", p.Id)
+	}
+	p.Title = "bye"
+	n, err := Save(ctx, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 row affected got %d", n)
+	}
+
+	expected := []string{
+		"INSERT INTO post (title) VALUES (?)",
+		"UPDATE post SET title = ? WHERE id = ?",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if args := fake.args[1]; len(args) != 2 || args[0] != "bye" || args[1] != int64(3) {
+		t.Errorf("expected args [bye 3] got %v", args)
+	}
+
+	if _, err := Save(ctx, &testUser{Name: "a"}); err == nil {
+		t.Error("expected error for primary key without the auto option")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	fake := setFakeDatabase(t)
+
+	n, err := Update(context.Background(), testArchivedUser{Id: 1, Name: "a", Archived: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 row affected got %d", n)
+	}
+	if _, err := Update(context.Background(), testArchivedUser{Id: 1, Name: "b"}, UpdateColumns("name")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"UPDATE archived_users SET archived = ?, name = ? WHERE id = ?",
+		"UPDATE archived_users SET name = ? WHERE id = ?",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if args := fake.args[1]; len(args) != 2 || args[0] != "b" || args[1] != int64(1) {
+		t.Errorf("expected args [b 1] got %v", args)
+	}
+
+	if _, err := Update(context.Background(), testUser{}, UpdateColumns("missing")); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	if _, err := Update(context.Background(), testType{}); err == nil {
+		t.Error("expected error for type without primary key")
+	}
+}
+
+func TestUpdateVersion(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	type doc struct {
+		Id      int    `sql:"id,pk"`
+		Body    string `sql:"body"`
+		Version int    `sql:"version,version"`
+	}
+
+	d := &doc{Id: 1, Body: "a", Version: 3}
+	if _, err := Update(ctx, d); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "UPDATE doc SET body = ?, version = version + 1 WHERE id = ? AND version = ?"; fake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, fake.queries[0])
+	}
+	if args := fake.args[0]; !reflect.DeepEqual(args, []interface{}{"a", int64(1), int64(3)}) {
+		t.Errorf("expected args [a 1 3] got %v", args)
+	}
+	if d.Version != 4 {
+		t.Errorf("expected version 4 got %d", d.Version)
+	}
+
+	fake.affected = 0
+	if _, err := Update(ctx, d); err != ErrStaleVersion {
+		t.Errorf("expected ErrStaleVersion got %v", err)
+	}
+	if d.Version != 4 {
+		t.Errorf("expected version to be unchanged got %d", d.Version)
+	}
+	if _, err := Update(ctx, d, UpdateColumns("version")); err == nil {
+		t.Error("expected error for updating the version column")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	if _, err := Delete[testUser](ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := Delete[testUser](ctx, &testUser{Id: 2}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := DeleteWhere(ctx, testArchivedUser{Name: "a", Archived: true}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"DELETE FROM users WHERE id = ?",
+		"DELETE FROM users WHERE id = ?",
+		"DELETE FROM archived_users WHERE archived = ? AND name = ?",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if args := fake.args[1]; len(args) != 1 || args[0] != int64(2) {
+		t.Errorf("expected args [2] got %v", args)
+	}
+
+	if _, err := DeleteWhere(ctx, testArchivedUser{}); err == nil {
+		t.Error("expected error for zero filter")
+	}
+	if _, err := Delete[testType](ctx, 1); err == nil {
+		t.Error("expected error for type without primary key")
+	}
+}
+
+func TestWhereFilter(t *testing.T) {
+	where, args := WhereFilter(testArchivedUser{Id: 3, Archived: true, Reference: "x"})
+	if expected := "archived = ? AND id = ?"; where != expected {
+		t.Errorf("expected %q got %q", expected, where)
+	}
+	if !reflect.DeepEqual(args, []interface{}{true, 3}) {
+		t.Errorf("expected args [true 3] got %v", args)
+	}
+
+	where, args = WhereFilter(testArchivedUser{})
+	if where != "1 = 1" || len(args) != 0 {
+		t.Errorf("expected 1 = 1 with no args got %q %v", where, args)
+	}
+}
+
+type testNote struct {
+	Id        int        `sql:"id,pk"`
+	Text      string     `sql:"text"`
+	DeletedAt *time.Time `sql:"deleted_at,softdelete"`
+}
+
+func (testNote) TableName() string { return "notes" }
+
+func TestSoftDelete(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	fake.setRows([]string{"count"}, []driver.Value{int64(1)})
+
+	if _, err := Delete[testNote](ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := DeleteWhere(ctx, testNote{Text: "a"}, Unscoped()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := Count[testNote](ctx, "text = ? OR id = ?", "a", 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := Count[testNote](ctx, "", Unscoped()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL",
+		"DELETE FROM notes WHERE text = ?",
+		"SELECT COUNT(*) FROM notes WHERE deleted_at IS NULL AND (text = ? OR id = ?)",
+		"SELECT COUNT(*) FROM notes",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+
+	db, dbFake := newFakeDB(t)
+	if _, err := DeleteAll[testNote](db, []int{2, 3}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE id IN (?, ?) AND deleted_at IS NULL"; dbFake.queries[0] != q {
+		t.Errorf("expected %q got %q", q, dbFake.queries[0])
+	}
+	if _, err := DeleteAll[testNote](db, []int{2, 3}, Unscoped()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if q := "DELETE FROM notes WHERE id IN (?, ?)"; dbFake.queries[1] != q {
+		t.Errorf("expected %q got %q", q, dbFake.queries[1])
+	}
+
+	query, _ := BuildSelect[testNote](Where("text = ?", "a"))
+	if expected := "SELECT deleted_at, id, text FROM notes WHERE deleted_at IS NULL AND (text = ?)"; query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+	query, _ = BuildSelect[testNote](WithDeleted())
+	if expected := "SELECT deleted_at, id, text FROM notes"; query != expected {
+		t.Errorf("expected %q got %q", expected, query)
+	}
+}
+
+func TestSoftDeleteQuery(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	fake.setRows([]string{"id"})
+
+	queries := []struct {
+		query    string
+		opts     []interface{}
+		expected string
+	}{
+		{"SELECT * FROM {table} WHERE text = ?", nil,
+			"SELECT deleted_at, id, text FROM (SELECT * FROM notes WHERE deleted_at IS NULL) AS notes WHERE text = ?"},
+		{"SELECT * FROM {table} n JOIN {table} AS m ON m.id = n.id", nil,
+			"SELECT deleted_at, id, text FROM (SELECT * FROM notes WHERE deleted_at IS NULL) n JOIN (SELECT * FROM notes WHERE deleted_at IS NULL) AS m ON m.id = n.id"},
+		{"DELETE FROM {table} WHERE id = ? RETURNING *", nil,
+			"DELETE FROM notes WHERE id = ? RETURNING deleted_at, id, text"},
+		{"SELECT * FROM {table}", []interface{}{Unscoped()},
+			"SELECT deleted_at, id, text FROM notes"},
+	}
+	for i, q := range queries {
+		if _, err := QueryContext[testNote](ctx, q.query, append([]interface{}{"a"}, q.opts...)...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fake.queries[i] != q.expected {
+			t.Errorf("expected %q got %q", q.expected, fake.queries[i])
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+	user := testArchivedUser{Id: 1, Name: "a"}
+
+	tests := []struct {
+		opts     []Option
+		expected string
+	}{
+		{[]Option{WithDialect(Postgres)}, "INSERT INTO archived_users (archived, id, name) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET archived = excluded.archived, name = excluded.name"},
+		{[]Option{WithDialect(SQLite), UpdateColumns("name")}, "INSERT INTO archived_users (archived, id, name) VALUES (?, ?, ?) ON CONFLICT (id) DO UPDATE SET name = excluded.name"},
+		{[]Option{WithDialect(MySQL)}, "INSERT INTO archived_users (archived, id, name) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE archived = VALUES(archived), name = VALUES(name)"},
+	}
+	for i, test := range tests {
+		if _, err := Upsert(ctx, user, test.opts...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fake.queries[i] != test.expected {
+			t.Errorf("expected %q got %q", test.expected, fake.queries[i])
+		}
+	}
+
+	if _, err := Upsert(ctx, user); err == nil {
+		t.Error("expected error for Generic dialect")
+	}
+	for _, col := range []string{"nmae", "id"} {
+		if _, err := Upsert(ctx, user, WithDialect(Postgres), UpdateColumns(col)); err == nil {
+			t.Errorf("expected error for UpdateColumns(%q)", col)
+		}
+	}
+}
+
+func TestBatchInsert(t *testing.T) {
+	fake := setFakeDatabase(t)
+	users := []testUser{{1, "a"}, {2, "b"}, {3, "c"}}
+
+	n, err := BatchInsert(context.Background(), users, ParamLimit(4), InTransaction())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 rows affected got %d", n)
+	}
+
+	expected := []string{
+		"INSERT INTO users (id, name) VALUES (?, ?), (?, ?)",
+		"INSERT INTO users (id, name) VALUES (?, ?)",
+		"COMMIT",
+	}
+	if len(fake.queries) != len(expected) {
+		t.Fatalf("expected %d statements got %q", len(expected), fake.queries)
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+	if args := fake.args[1]; len(args) != 2 || args[0] != int64(3) || args[1] != "c" {
+		t.Errorf("expected args [3 c] got %v", args)
+	}
+}
+
+func TestPrimaryKeyColumns(t *testing.T) {
+	type membership struct {
+		UserId  int `sql:"user_id,pk"`
+		GroupId int `sql:"group_id,pk"`
+		Id      int `sql:"id,auto"`
+	}
+	if cols := PrimaryKeyColumns[membership](); !reflect.DeepEqual(cols, []string{"group_id", "user_id"}) {
+		t.Errorf("expected [group_id user_id] got %v", cols)
+	}
+	if cols := PrimaryKeyColumns[testType](); cols != nil {
+		t.Errorf("expected no primary key columns got %v", cols)
+	}
+}
+
+func TestWriteOptions(t *testing.T) {
+	fake := setFakeDatabase(t)
+	ctx := context.Background()
+
+	type account struct {
+		Id       int    `sql:"id,pk"`
+		Email    string `sql:"email,omitempty"`
+		Created  string `sql:"created,readonly"`
+		Password string `sql:"password,writeonly"`
+	}
+	if cols, expected := Columns(account{}), "created, email, id"; cols != expected {
+		t.Errorf("expected %q got %q", expected, cols)
+	}
+
+	if _, err := Insert(ctx, account{Id: 1, Created: "now", Password: "x"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := Update(ctx, account{Id: 1, Email: "a@b"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"INSERT INTO account (id, password) VALUES (?, ?)",
+		"UPDATE account SET email = ?, password = ? WHERE id = ?",
+	}
+	for i, q := range expected {
+		if fake.queries[i] != q {
+			t.Errorf("expected %q got %q", q, fake.queries[i])
+		}
+	}
+
+	rows := testRows{}
+	rows.addValue("password", "secret")
+	var a account
+	if err := Scan(&a, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a.Password != "" {
+		t.Errorf("expected writeonly field not to be scanned, got %q", a.Password)
+	}
+}